@@ -0,0 +1,507 @@
+package controllers
+
+import (
+	"dorm-service/data"
+	"dorm-service/models"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// studentStatuses are the statuses a student may move their own application into.
+var studentStatuses = map[string]bool{
+	models.StatusConfirmed: true,
+	models.StatusDeclined:  true,
+	models.StatusWithdrawn: true,
+}
+
+// actor returns the id and role of the logged in user.
+func actor(c *gin.Context) (string, string) {
+	uid, _ := c.Get("uid")
+	userType, _ := c.Get("user_type")
+	id, _ := uid.(string)
+	role, _ := userType.(string)
+	return id, role
+}
+
+func rankedBefore(a, b *models.Student) bool {
+	if a.GPA == b.GPA {
+		return a.Year > b.Year
+	}
+	return a.GPA > b.GPA
+}
+
+// rankApplications sorts applications in ranking order. Students with a recent
+// disciplinary sanction rank after those without, the more severe the sanction the
+// lower; otherwise rankedBefore decides.
+func rankApplications(apps []*models.Application, severities map[primitive.ObjectID]int) {
+	sort.SliceStable(apps, func(i, j int) bool {
+		si, sj := severities[apps[i].StudentId], severities[apps[j].StudentId]
+		if si != sj {
+			return si < sj
+		}
+		return rankedBefore(apps[i].Student, apps[j].Student)
+	})
+}
+
+func (dc *DormController) changeStatus(c *gin.Context, app *models.Application, to string, reason string) error {
+	changedBy, role := actor(c)
+	return dc.transition(app, to, reason, changedBy, role)
+}
+
+// transition moves an application to a new status on behalf of changedBy.
+func (dc *DormController) transition(app *models.Application, to string, reason string, changedBy string, role string) error {
+	err := dc.repo.UpdateApplicationStatus(app.Id, models.StatusChange{
+		From:          app.Status,
+		To:            to,
+		ChangedBy:     changedBy,
+		ChangedByRole: role,
+		ChangedAt:     time.Now(),
+		Reason:        reason,
+	})
+	if err != nil {
+		return err
+	}
+
+	// A place that is given up can go to the next student on the waiting list.
+	if (app.Status == models.StatusAccepted || app.Status == models.StatusConfirmed) &&
+		(to == models.StatusDeclined || to == models.StatusWithdrawn) {
+		if _, err := dc.processApplications(app.SelectionId.Hex(), "system", "SYSTEM"); err != nil {
+			dc.logger.Printf("could not promote waitlisted applications: %v", err)
+		}
+	}
+	return nil
+}
+
+// applicationQuery turns an application filter into a database query. Within a
+// selection an application is also incomplete when it lacks a document the
+// selection requires.
+func (dc *DormController) applicationQuery(filter models.ApplicationFilter) (bson.M, error) {
+	query := bson.M{}
+	if filter.SelectionId != "" {
+		id, err := primitive.ObjectIDFromHex(filter.SelectionId)
+		if err != nil {
+			return nil, errors.New("invalid selection ID")
+		}
+		query["selectionId"] = id
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.Year != nil {
+		query["student.year"] = *filter.Year
+	}
+	if filter.Scholarship != nil {
+		query["student.scholarship"] = *filter.Scholarship
+	}
+	if filter.Incomplete {
+		incomplete := bson.A{bson.M{"student": nil}, bson.M{"academic": nil}}
+		if filter.SelectionId != "" {
+			selection, err := dc.repo.GetSelection(filter.SelectionId)
+			if err != nil {
+				return nil, err
+			}
+			for _, docType := range selection.RequiredDocuments {
+				incomplete = append(incomplete, bson.M{"documents": bson.M{"$not": bson.M{"$elemMatch": bson.M{
+					"type":   docType,
+					"status": bson.M{"$ne": models.DocumentRejected},
+				}}}})
+			}
+		}
+		query["$or"] = incomplete
+	}
+	return query, nil
+}
+
+func statusErrorCode(err error) int {
+	if errors.Is(err, data.ErrInvalidTransition) {
+		return http.StatusBadRequest
+	}
+	return http.StatusConflict
+}
+
+// processApplications runs placement for a selection. The reviewed and waitlisted
+// applications are ranked and, from the top, every student gets the building they
+// ranked highest that still has free beds; students left without a place are
+// waitlisted. Because all buildings rank students the same way this is a stable
+// matching, and a student never holds more than one place: those already holding a
+// place in another selection stay on the waiting list.
+//
+// A selection that follows a renewal campaign is for the next academic year, so it
+// offers the full capacity of its buildings less the renewed places, no matter who
+// lives there now.
+func (dc *DormController) processApplications(selectionId string, changedBy string, role string) ([]*models.Application, error) {
+	selection, err := dc.repo.GetSelection(selectionId)
+	if err != nil {
+		return nil, err
+	}
+	renewals, renewing, err := dc.repo.SelectionRenewals(selection.Id)
+	if err != nil {
+		return nil, err
+	}
+	free := map[primitive.ObjectID]int{}
+	for _, buildingId := range selection.Buildings() {
+		building, err := dc.repo.GetBuilding(buildingId.Hex())
+		if err != nil {
+			return nil, err
+		}
+		for _, room := range building.Rooms {
+			free[buildingId] += room.Capacity
+			if !renewing {
+				free[buildingId] -= room.Occupancy()
+			}
+		}
+	}
+	renewed := map[primitive.ObjectID]bool{}
+	for _, renewal := range renewals {
+		free[renewal.BuildingId]--
+		renewed[renewal.StudentId] = true
+	}
+	apps, err := dc.repo.GetApplicationsBySelection(selection.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []*models.Application
+	var candidateIds []primitive.ObjectID
+	for _, app := range apps {
+		switch app.Status {
+		case models.StatusAccepted, models.StatusConfirmed:
+			// Placed students are already counted as room occupants, unless
+			// occupancy is ignored for the next year.
+			if app.Placement == nil || renewing {
+				free[app.PlacedBuilding(selection)]--
+			}
+		case models.StatusUnderReview, models.StatusWaitlisted:
+			// Applications are only ranked once every required document was accepted.
+			if app.Student != nil && len(app.MissingDocuments(selection, true)) == 0 {
+				candidates = append(candidates, app)
+				candidateIds = append(candidateIds, app.StudentId)
+			}
+		}
+	}
+	holding, err := dc.repo.StudentsHoldingPlaces(candidateIds, selection.Id)
+	if err != nil {
+		return nil, err
+	}
+	severities, err := dc.sanctionSeverities(candidateIds)
+	if err != nil {
+		return nil, err
+	}
+
+	rankApplications(candidates, severities)
+
+	for _, app := range candidates {
+		to := models.StatusWaitlisted
+		reason := "no free places left"
+		var placedIn *primitive.ObjectID
+		if holding[app.StudentId] {
+			reason = "already holds a place in another selection"
+		} else if renewed[app.StudentId] {
+			reason = "already renewed their place"
+		} else {
+			for _, buildingId := range app.BuildingChoices(selection) {
+				if free[buildingId] > 0 {
+					id := buildingId
+					placedIn = &id
+					break
+				}
+			}
+		}
+		if placedIn != nil {
+			to = models.StatusAccepted
+			reason = "placed by ranking"
+			free[*placedIn]--
+		}
+		if app.Status == to {
+			continue
+		}
+		if err := dc.transition(app, to, reason, changedBy, role); err != nil {
+			return nil, err
+		}
+		app.Status = to
+		if placedIn != nil {
+			if err := dc.repo.SetAcceptedBuilding(app.Id, *placedIn); err != nil {
+				return nil, err
+			}
+			app.AcceptedBuildingId = placedIn
+		}
+	}
+
+	return candidates, nil
+}
+
+func (dc *DormController) GetApplicationById() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		app, err := dc.repo.GetApplicationById(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, app)
+	}
+}
+
+// UpdateApplicationStatus lets a reviewer move any application through the lifecycle.
+func (dc *DormController) UpdateApplicationStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.StatusChangeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		app, err := dc.repo.GetApplicationById(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := dc.changeStatus(c, app, req.Status, req.Reason); err != nil {
+			c.JSON(statusErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Application status updated"})
+	}
+}
+
+// BulkUpdateApplicationStatus moves many applications to one status at once, for
+// example rejecting every incomplete application of a selection under review. Each
+// application goes through the usual transition and gets its own history entry;
+// applications that cannot make the transition are skipped and reported.
+func (dc *DormController) BulkUpdateApplicationStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.BulkStatusRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		query, err := dc.applicationQuery(req.Filter)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if len(req.Ids) > 0 {
+			ids := make([]primitive.ObjectID, 0, len(req.Ids))
+			for _, id := range req.Ids {
+				appId, err := primitive.ObjectIDFromHex(id)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "invalid application ID " + id})
+					return
+				}
+				ids = append(ids, appId)
+			}
+			query["_id"] = bson.M{"$in": ids}
+		} else if req.Filter.SelectionId == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a selection or a list of applications is required"})
+			return
+		}
+
+		apps, _, err := dc.repo.FindApplications(query, bson.D{{Key: "createdAt", Value: 1}}, 0, 0)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+
+		result := models.BulkStatusResult{Matched: len(apps), Updated: []primitive.ObjectID{}, Skipped: []models.BulkSkip{}}
+		for _, app := range apps {
+			if err := dc.changeStatus(c, app, req.Status, req.Reason); err != nil {
+				result.Skipped = append(result.Skipped, models.BulkSkip{Id: app.Id, Status: app.Status, Reason: err.Error()})
+				continue
+			}
+			result.Updated = append(result.Updated, app.Id)
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// UpdateMyApplicationStatus lets a student confirm, decline or withdraw their application
+// for the selection given in the url.
+func (dc *DormController) UpdateMyApplicationStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.StatusChangeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if !studentStatuses[req.Status] {
+			c.JSON(http.StatusForbidden, gin.H{"error": "students can only confirm, decline or withdraw an application"})
+			return
+		}
+
+		studentId, _ := actor(c)
+		app, err := dc.repo.GetApplication(studentId, c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := dc.changeStatus(c, app, req.Status, req.Reason); err != nil {
+			c.JSON(statusErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Application status updated"})
+	}
+}
+
+// ProcessSelection runs placement for all reviewed applications of a selection.
+func (dc *DormController) ProcessSelection() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		changedBy, role := actor(c)
+		apps, err := dc.processApplications(c.Param("id"), changedBy, role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, apps)
+	}
+}
+
+// FileAppeal lets a rejected student appeal within the selection's appeal window.
+func (dc *DormController) FileAppeal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var appeal models.Appeal
+		if err := c.ShouldBindJSON(&appeal); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(appeal); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		studentId, _ := actor(c)
+		app, err := dc.repo.GetApplication(studentId, c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if app.Status != models.StatusRejected {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "only rejected applications can be appealed"})
+			return
+		}
+
+		selection, err := dc.repo.GetSelection(app.SelectionId.Hex())
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		rejectedAt := app.UpdatedAt
+		if change := app.LastChangeTo(models.StatusRejected); change != nil {
+			rejectedAt = change.ChangedAt
+		}
+		if time.Since(rejectedAt) > selection.AppealWindow() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the appeal window for this application has closed"})
+			return
+		}
+
+		appeal.ApplicationId = app.Id
+		appeal.SelectionId = app.SelectionId
+		appeal.StudentId = app.StudentId
+		err = dc.repo.InsertAppeal(&appeal)
+		if err == data.ErrDuplicateAppeal {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"Appeal filed": appeal})
+	}
+}
+
+func (dc *DormController) GetAppeals() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appeals, err := dc.repo.GetAppeals(c.Query("status"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, appeals)
+	}
+}
+
+// ResolveAppeal closes an appeal. An upheld appeal sends the application back
+// to review and, if requested, re-runs placement for its selection.
+func (dc *DormController) ResolveAppeal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.AppealResolution
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		appeal, err := dc.repo.GetAppeal(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		if appeal.Status != models.AppealPending {
+			c.JSON(http.StatusConflict, gin.H{"error": "appeal " + appeal.Id.Hex() + " is not pending"})
+			return
+		}
+
+		// The application is sent back to review before the appeal is closed. A failure
+		// in between leaves a pending appeal whose application is already under review,
+		// and resolving it again only closes the appeal.
+		if req.Upheld {
+			app, err := dc.repo.GetApplicationById(appeal.ApplicationId.Hex())
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			if !appeal.ReopensReview(app) {
+				dc.logger.Printf("application %s is already under review, closing appeal %s", app.Id.Hex(), appeal.Id.Hex())
+			} else if err := dc.changeStatus(c, app, models.StatusUnderReview, "appeal upheld: "+req.Resolution); err != nil {
+				c.JSON(statusErrorCode(err), gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		status := models.AppealDismissed
+		if req.Upheld {
+			status = models.AppealUpheld
+		}
+		resolvedBy, role := actor(c)
+		if err := dc.repo.ResolveAppeal(appeal.Id, status, resolvedBy, req.Resolution); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+
+		if !req.Upheld {
+			c.JSON(http.StatusOK, gin.H{"message": "Appeal dismissed"})
+			return
+		}
+
+		if req.RerunPlacement {
+			apps, err := dc.processApplications(appeal.SelectionId.Hex(), resolvedBy, role)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"message": "Appeal upheld", "placement": apps})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Appeal upheld"})
+	}
+}
@@ -0,0 +1,525 @@
+package controllers
+
+import (
+	"context"
+	"dorm-service/data"
+	"dorm-service/models"
+	"dorm-service/payments"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func depositErrorCode(err error) int {
+	switch {
+	case errors.Is(err, data.ErrDepositNotFound), errors.Is(err, data.ErrInspectionNotFound),
+		errors.Is(err, data.ErrSettlementNotFound), errors.Is(err, data.ErrStudentNotInRoom),
+		errors.Is(err, data.ErrRoomNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, data.ErrDepositExists), errors.Is(err, data.ErrDepositAlreadyTaken):
+		return http.StatusConflict
+	case errors.Is(err, data.ErrInspectionItem):
+		return http.StatusBadRequest
+	case errors.Is(err, payments.ErrPaymentDeclined), errors.Is(err, data.ErrDepositRequired):
+		return http.StatusPaymentRequired
+	}
+	return http.StatusInternalServerError
+}
+
+// studentParam resolves the student a request is about: students always act for
+// themselves, admins name the student.
+func studentParam(c *gin.Context, requested string) (primitive.ObjectID, error) {
+	uid, role := actor(c)
+	if role == "STUDENT" || requested == "" {
+		requested = uid
+	}
+	studentId, err := primitive.ObjectIDFromHex(requested)
+	if err != nil {
+		return primitive.NilObjectID, fmt.Errorf("invalid student ID")
+	}
+	return studentId, nil
+}
+
+// TakeDeposit charges the security deposit for the room the student lives in.
+func (dc *DormController) TakeDeposit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.DepositRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		studentId, err := studentParam(c, req.StudentId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		building, room, err := dc.repo.FindStudentRoom(studentId)
+		if err != nil {
+			c.JSON(depositErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		if _, err := dc.repo.GetHeldDeposit(studentId); err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": data.ErrDepositExists.Error()})
+			return
+		}
+		roomTypes, err := dc.repo.GetRoomTypes()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		amount := models.RoundMoney(building.DepositAmount(room, roomTypes.ById()))
+		if amount <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "no deposit is set for this room"})
+			return
+		}
+
+		recordedBy, _ := actor(c)
+		deposit, err := dc.chargeDeposit(studentId, building, room, amount, req.Token, recordedBy)
+		if err != nil {
+			c.JSON(depositErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"Deposit taken": deposit})
+	}
+}
+
+// chargeDeposit charges the security deposit for a room and records it as held. The
+// payment is refunded if the deposit cannot be recorded.
+func (dc *DormController) chargeDeposit(studentId primitive.ObjectID, building *models.Building, room *models.Room, amount float64, token string, recordedBy string) (*models.Deposit, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	charge, err := dc.payments.Charge(ctx, payments.ChargeRequest{
+		PayerId:     studentId.Hex(),
+		Amount:      amount,
+		Description: fmt.Sprintf("Security deposit, %s room #%d", building.Name, room.Room_Number),
+		Token:       token,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	deposit := models.Deposit{
+		StudentId:   studentId,
+		BuildingId:  building.Id,
+		RoomNumber:  room.Room_Number,
+		Amount:      amount,
+		Provider:    charge.Provider,
+		ProviderRef: charge.Reference,
+		RecordedBy:  recordedBy,
+		PaidAt:      charge.ChargedAt,
+	}
+	if err := dc.repo.InsertDeposit(&deposit); err != nil {
+		if refundErr := dc.payments.Refund(ctx, charge.Reference, amount); refundErr != nil {
+			dc.logger.Printf("could not refund deposit payment %s: %v", charge.Reference, refundErr)
+		}
+		return nil, err
+	}
+	return &deposit, nil
+}
+
+// requireDeposit makes sure a student checking into a room has a security deposit
+// held, charging it with token if it was not paid yet. It returns the deposit it
+// charged, if any, so it can be returned when the check-in fails.
+func (dc *DormController) requireDeposit(studentId primitive.ObjectID, buildingId primitive.ObjectID, roomNumber int, token string, recordedBy string) (*models.Deposit, error) {
+	if _, err := dc.repo.GetHeldDeposit(studentId); err == nil {
+		return nil, nil
+	} else if err != data.ErrDepositNotFound {
+		return nil, err
+	}
+
+	building, err := dc.repo.GetBuilding(buildingId.Hex())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", data.ErrRoomNotFound, err)
+	}
+	var room *models.Room
+	for _, r := range building.Rooms {
+		if r.Room_Number == roomNumber {
+			room = r
+		}
+	}
+	if room == nil {
+		return nil, fmt.Errorf("%w: room #%d", data.ErrRoomNotFound, roomNumber)
+	}
+	roomTypes, err := dc.repo.GetRoomTypes()
+	if err != nil {
+		return nil, err
+	}
+	amount := models.RoundMoney(building.DepositAmount(room, roomTypes.ById()))
+	if amount <= 0 {
+		return nil, nil
+	}
+	if token == "" {
+		return nil, data.ErrDepositRequired
+	}
+	return dc.chargeDeposit(studentId, building, room, amount, token, recordedBy)
+}
+
+// returnDeposit refunds and removes a deposit charged for a check-in that failed.
+func (dc *DormController) returnDeposit(deposit *models.Deposit) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := dc.payments.Refund(ctx, deposit.ProviderRef, deposit.Amount); err != nil {
+		dc.logger.Printf("could not refund deposit payment %s: %v", deposit.ProviderRef, err)
+		return
+	}
+	if err := dc.repo.DeleteDeposit(deposit.Id); err != nil {
+		dc.logger.Printf("could not remove refunded deposit %s: %v", deposit.Id.Hex(), err)
+	}
+}
+
+func (dc *DormController) GetDeposits() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := bson.M{}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+		if studentId := c.Query("studentId"); studentId != "" {
+			id, err := primitive.ObjectIDFromHex(studentId)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid student ID"})
+				return
+			}
+			filter["studentId"] = id
+		}
+		deposits, err := dc.repo.GetDeposits(filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, deposits)
+	}
+}
+
+func (dc *DormController) GetMyDeposits() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		studentId, err := studentParam(c, "")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		deposits, err := dc.repo.GetDeposits(bson.M{"studentId": studentId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, deposits)
+	}
+}
+
+// GetRoomChecklist returns the items an inspection of the room has to cover.
+func (dc *DormController) GetRoomChecklist() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roomNumber, err := strconv.Atoi(c.Param("number"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room number"})
+			return
+		}
+		room, err := dc.repo.GetRoom(roomNumber, c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, room.Checklist())
+	}
+}
+
+// InsertInspection records a check-in or check-out inspection of the room the
+// student lives in. Every item of the room's checklist has to be inspected.
+func (dc *DormController) InsertInspection() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.InspectionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		studentId, err := primitive.ObjectIDFromHex(req.StudentId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid student ID"})
+			return
+		}
+
+		building, room, err := dc.repo.FindStudentRoom(studentId)
+		if err != nil {
+			c.JSON(depositErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		inspection := models.Inspection{
+			StudentId:  studentId,
+			BuildingId: building.Id,
+			RoomNumber: room.Room_Number,
+			Type:       req.Type,
+			Items:      req.Items,
+			Notes:      req.Notes,
+		}
+		var missing []string
+		for _, name := range room.Checklist() {
+			if inspection.Item(name) == nil {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "inspection does not cover the whole checklist", "missing": missing})
+			return
+		}
+		if req.Type == models.InspectionCheckIn {
+			// Damage found on check-in is the dorm's, not the resident's.
+			for i := range inspection.Items {
+				inspection.Items[i].DamageCharge = 0
+			}
+		}
+
+		inspection.InspectedBy, _ = actor(c)
+		if err := dc.repo.InsertInspection(&inspection); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"Inspection recorded": inspection})
+	}
+}
+
+// UploadInspectionPhoto attaches a photo to one checklist item of an inspection.
+// The form carries the item name in "item" and the image in "photo".
+func (dc *DormController) UploadInspectionPhoto() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inspection, err := dc.repo.GetInspection(c.Param("id"))
+		if err != nil {
+			c.JSON(depositErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		item := c.PostForm("item")
+		if inspection.Item(item) == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": data.ErrInspectionItem.Error()})
+			return
+		}
+
+		filename, status, err := savePhoto(c, "inspections", inspection.Id.Hex())
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		publicPath := models.InspectionPhotoPath(inspection.Id, filename)
+		if err := dc.repo.AddInspectionPhoto(inspection.Id, item, publicPath); err != nil {
+			c.JSON(depositErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"photo": publicPath})
+	}
+}
+
+func (dc *DormController) GetInspections() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		studentId, err := studentParam(c, c.Query("studentId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		filter := bson.M{"studentId": studentId}
+		if inspectionType := c.Query("type"); inspectionType != "" {
+			filter["type"] = inspectionType
+		}
+		inspections, err := dc.repo.GetInspections(filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, inspections)
+	}
+}
+
+func (dc *DormController) GetInspection() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inspection, err := dc.repo.GetInspection(c.Param("id"))
+		if err != nil {
+			c.JSON(depositErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		if uid, role := actor(c); role == "STUDENT" && inspection.StudentId.Hex() != uid {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+		c.JSON(http.StatusOK, inspection)
+	}
+}
+
+// GetInspectionPhoto serves a photo of an inspection. Students only see photos of
+// their own inspections.
+func (dc *DormController) GetInspectionPhoto() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		inspection, err := dc.repo.GetInspection(c.Param("id"))
+		if err != nil {
+			c.JSON(depositErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		if uid, role := actor(c); role == "STUDENT" && inspection.StudentId.Hex() != uid {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+		var photos []string
+		for _, item := range inspection.Items {
+			photos = append(photos, item.Photos...)
+		}
+		servePhoto(c, "inspections", photos, c.Param("name"))
+	}
+}
+
+// SettleDeposit settles a leaving student's deposit against their check-out
+// inspection, refunds what is left and produces the settlement statement.
+func (dc *DormController) SettleDeposit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		studentId, err := primitive.ObjectIDFromHex(c.Param("studentId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid student ID"})
+			return
+		}
+		deposit, err := dc.repo.GetHeldDeposit(studentId)
+		if err != nil {
+			c.JSON(depositErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		checkOut, err := dc.repo.GetLatestInspection(bson.M{
+			"studentId":   studentId,
+			"type":        models.InspectionCheckOut,
+			"inspectedAt": bson.M{"$gte": deposit.PaidAt},
+		})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a check-out inspection is required before the deposit can be settled"})
+			return
+		}
+		checkIn, err := dc.repo.GetLatestInspection(bson.M{
+			"studentId":   studentId,
+			"type":        models.InspectionCheckIn,
+			"buildingId":  checkOut.BuildingId,
+			"roomNumber":  checkOut.RoomNumber,
+			"inspectedAt": bson.M{"$lte": checkOut.InspectedAt},
+		})
+		if err != nil && err != data.ErrInspectionNotFound {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+
+		settlement := models.NewSettlement(deposit, checkIn, checkOut)
+		settlement.SettledBy, _ = actor(c)
+		settlement.SettledAt = time.Now()
+		settlement.Status = models.SettlementCompleted
+		if settlement.Refund > 0 {
+			settlement.Status = models.SettlementPending
+		}
+
+		// The settlement is recorded before any money goes out, and only completed
+		// once the refund went through.
+		if err := dc.repo.SetDepositStatus(deposit.Id, models.DepositHeld, models.DepositSettled); err != nil {
+			c.JSON(depositErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		if err := dc.repo.InsertSettlement(settlement); err != nil {
+			if revertErr := dc.repo.SetDepositStatus(deposit.Id, models.DepositSettled, models.DepositHeld); revertErr != nil {
+				dc.logger.Printf("could not reopen deposit %s: %v", deposit.Id.Hex(), revertErr)
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		if settlement.Refund > 0 {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := dc.payments.Refund(ctx, deposit.ProviderRef, settlement.Refund); err != nil {
+				if deleteErr := dc.repo.DeletePendingSettlement(settlement.Id); deleteErr != nil {
+					dc.logger.Printf("could not remove pending settlement %s: %v", settlement.Id.Hex(), deleteErr)
+				} else if revertErr := dc.repo.SetDepositStatus(deposit.Id, models.DepositSettled, models.DepositHeld); revertErr != nil {
+					dc.logger.Printf("could not reopen deposit %s: %v", deposit.Id.Hex(), revertErr)
+				}
+				c.JSON(http.StatusBadGateway, gin.H{"error": "refund failed: " + err.Error()})
+				return
+			}
+			settlement.Status = models.SettlementCompleted
+			settlement.RefundRef = deposit.ProviderRef
+			if err := dc.repo.CompleteSettlement(settlement.Id, settlement.RefundRef); err != nil {
+				dc.logger.Printf("refund %s went out but settlement %s could not be completed: %v", deposit.ProviderRef, settlement.Id.Hex(), err)
+			}
+		}
+
+		content := fmt.Sprintf("Your deposit of %.2f has been settled. Damage deducted: %.2f. Refunded: %.2f.",
+			settlement.DepositAmount, settlement.Deducted, settlement.Refund)
+		if settlement.AmountOwed > 0 {
+			content += fmt.Sprintf(" Damage not covered by the deposit: %.2f.", settlement.AmountOwed)
+		}
+		if err := dc.repo.Notify(studentId, "Deposit settled", content); err != nil {
+			dc.logger.Printf("could not notify student %s: %v", studentId.Hex(), err)
+		}
+
+		c.JSON(http.StatusOK, settlement)
+	}
+}
+
+func (dc *DormController) GetSettlements() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		studentId, err := studentParam(c, c.Query("studentId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		settlements, err := dc.repo.GetSettlements(bson.M{"studentId": studentId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, settlements)
+	}
+}
+
+// GetSettlementStatement returns a settlement as a plain text statement.
+func (dc *DormController) GetSettlementStatement() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		settlement, err := dc.repo.GetSettlement(c.Param("id"))
+		if err != nil {
+			c.JSON(depositErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		if uid, role := actor(c); role == "STUDENT" && settlement.StudentId.Hex() != uid {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+
+		building, err := dc.repo.GetBuilding(settlement.BuildingId.Hex())
+		buildingName := settlement.BuildingId.Hex()
+		if err == nil {
+			buildingName = building.Name
+		}
+
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "DEPOSIT SETTLEMENT STATEMENT\n\n")
+		fmt.Fprintf(&sb, "Statement:  %s\n", settlement.Id.Hex())
+		fmt.Fprintf(&sb, "Student:    %s\n", settlement.StudentId.Hex())
+		fmt.Fprintf(&sb, "Room:       %s, room #%d\n", buildingName, settlement.RoomNumber)
+		fmt.Fprintf(&sb, "Settled on: %s\n\n", settlement.SettledAt.Format("02-01-2006"))
+		fmt.Fprintf(&sb, "Deposit paid: %.2f\n", settlement.DepositAmount)
+		for _, d := range settlement.Deductions {
+			condition := d.Condition
+			if d.CheckInCondition != "" {
+				condition = d.CheckInCondition + " -> " + d.Condition
+			}
+			fmt.Fprintf(&sb, "  Damage, %s (%s): %.2f\n", d.Item, condition, d.Amount)
+		}
+		fmt.Fprintf(&sb, "Deducted:     %.2f\n", settlement.Deducted)
+		fmt.Fprintf(&sb, "Refunded:     %.2f\n", settlement.Refund)
+		if settlement.AmountOwed > 0 {
+			fmt.Fprintf(&sb, "Still owed:   %.2f\n", settlement.AmountOwed)
+		}
+
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=settlement-%s.txt", settlement.Id.Hex()))
+		c.String(http.StatusOK, sb.String())
+	}
+}
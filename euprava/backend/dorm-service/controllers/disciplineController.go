@@ -0,0 +1,340 @@
+package controllers
+
+import (
+	"context"
+	"dorm-service/data"
+	"dorm-service/models"
+	"dorm-service/payments"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func incidentErrorCode(err error) int {
+	switch {
+	case errors.Is(err, data.ErrIncidentNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, data.ErrInvalidIncidentTransition), errors.Is(err, data.ErrFineNotDue):
+		return http.StatusConflict
+	case errors.Is(err, payments.ErrPaymentDeclined):
+		return http.StatusPaymentRequired
+	}
+	return http.StatusInternalServerError
+}
+
+// sanctionSeverities returns, for each of the students, the severity of the worst
+// sanction on their record within SanctionWindow.
+func (dc *DormController) sanctionSeverities(studentIds []primitive.ObjectID) (map[primitive.ObjectID]int, error) {
+	sanctioned, err := dc.repo.GetSanctions(studentIds, time.Now().Add(-models.SanctionWindow))
+	if err != nil {
+		return nil, err
+	}
+	severities := map[primitive.ObjectID]int{}
+	for _, incident := range sanctioned {
+		if severity := models.SanctionSeverity[incident.Sanction.Level]; severity > severities[incident.StudentId] {
+			severities[incident.StudentId] = severity
+		}
+	}
+	return severities, nil
+}
+
+// incidentForActor loads an incident and checks that a student only sees their own.
+func (dc *DormController) incidentForActor(c *gin.Context) (*models.Incident, bool) {
+	incident, err := dc.repo.GetIncident(c.Param("id"))
+	if err != nil {
+		c.JSON(incidentErrorCode(err), gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if uid, role := actor(c); role == "STUDENT" && incident.StudentId.Hex() != uid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return nil, false
+	}
+	return incident, true
+}
+
+func (dc *DormController) changeIncidentStatus(c *gin.Context, incident *models.Incident, to string, reason string, set bson.M) error {
+	changedBy, role := actor(c)
+	return dc.repo.UpdateIncidentStatus(incident.Id, models.StatusChange{
+		From:          incident.Status,
+		To:            to,
+		ChangedBy:     changedBy,
+		ChangedByRole: role,
+		ChangedAt:     time.Now(),
+		Reason:        reason,
+	}, set)
+}
+
+func (dc *DormController) notifyIncident(incident *models.Incident, title string, content string) {
+	if err := dc.repo.Notify(incident.StudentId, title, content); err != nil {
+		dc.logger.Printf("could not notify student %s: %v", incident.StudentId.Hex(), err)
+	}
+}
+
+// ReportIncident files a house-rule violation against a resident.
+func (dc *DormController) ReportIncident() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.IncidentRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.OccurredAt.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the incident cannot be in the future"})
+			return
+		}
+		studentId, err := primitive.ObjectIDFromHex(req.StudentId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid student ID"})
+			return
+		}
+		building, room, err := dc.repo.FindStudentRoom(studentId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the student does not live in a dorm room"})
+			return
+		}
+
+		reportedBy, role := actor(c)
+		incident := models.Incident{
+			StudentId:      studentId,
+			BuildingId:     building.Id,
+			RoomNumber:     room.Room_Number,
+			Rule:           req.Rule,
+			Description:    req.Description,
+			OccurredAt:     req.OccurredAt,
+			ReportedBy:     reportedBy,
+			ReportedByName: actorName(c),
+			ReporterRole:   role,
+		}
+		for _, s := range *room.Students {
+			if s != nil && s.ID == studentId {
+				incident.StudentName = s.FullName()
+			}
+		}
+		if err := dc.repo.InsertIncident(&incident); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		dc.notifyIncident(&incident, "Incident reported",
+			fmt.Sprintf("An incident (%s) on %s was reported against you. You can submit your statement before the hearing.",
+				incident.Rule, incident.OccurredAt.Format("02-01-2006")))
+		c.JSON(http.StatusOK, gin.H{"Incident reported": incident})
+	}
+}
+
+func (dc *DormController) GetIncidents() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := bson.M{}
+		if studentId := c.Query("studentId"); studentId != "" {
+			id, err := primitive.ObjectIDFromHex(studentId)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid student ID"})
+				return
+			}
+			filter["studentId"] = id
+		}
+		if buildingId := c.Query("buildingId"); buildingId != "" {
+			id, err := primitive.ObjectIDFromHex(buildingId)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid building ID"})
+				return
+			}
+			filter["buildingId"] = id
+		}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+		incidents, err := dc.repo.GetIncidents(filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, incidents)
+	}
+}
+
+func (dc *DormController) GetMyIncidents() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		studentId, err := studentParam(c, "")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		incidents, err := dc.repo.GetIncidents(bson.M{"studentId": studentId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, incidents)
+	}
+}
+
+func (dc *DormController) GetIncident() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		incident, ok := dc.incidentForActor(c)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, incident)
+	}
+}
+
+// SubmitStatement lets the resident give their side of the story before a decision.
+func (dc *DormController) SubmitStatement() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.StatementRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		incident, ok := dc.incidentForActor(c)
+		if !ok {
+			return
+		}
+		if err := dc.repo.SetIncidentStatement(incident.Id, req.Statement); err != nil {
+			c.JSON(incidentErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Statement submitted"})
+	}
+}
+
+// ScheduleHearing sets or moves the hearing of an incident and invites the resident.
+func (dc *DormController) ScheduleHearing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var hearing models.Hearing
+		if err := c.ShouldBindJSON(&hearing); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(hearing); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !hearing.At.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the hearing has to be in the future"})
+			return
+		}
+		incident, err := dc.repo.GetIncident(c.Param("id"))
+		if err != nil {
+			c.JSON(incidentErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		hearing.ScheduledBy, _ = actor(c)
+		when := hearing.At.In(time.Local).Format("02-01-2006 15:04")
+		if err := dc.changeIncidentStatus(c, incident, models.IncidentHearingScheduled, "hearing on "+when, bson.M{"hearing": hearing}); err != nil {
+			c.JSON(incidentErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		dc.notifyIncident(incident, "Disciplinary hearing",
+			fmt.Sprintf("The hearing about the incident (%s) is on %s at %s.", incident.Rule, when, hearing.Location))
+		c.JSON(http.StatusOK, gin.H{"message": "Hearing scheduled"})
+	}
+}
+
+// DecideIncident closes an incident with a sanction or dismisses it. Sanctions can
+// only be imposed after a hearing; a report can be dismissed at any time.
+func (dc *DormController) DecideIncident() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.IncidentDecision
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !req.Dismiss && req.Level == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a sanction level is required"})
+			return
+		}
+		if !req.Dismiss && req.Level == models.SanctionFine && req.FineAmount <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a fine needs an amount"})
+			return
+		}
+		incident, err := dc.repo.GetIncident(c.Param("id"))
+		if err != nil {
+			c.JSON(incidentErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		now := time.Now()
+		decidedBy, _ := actor(c)
+		to := models.IncidentDismissed
+		set := bson.M{"decision": req.Reasoning, "decidedBy": decidedBy, "decidedAt": now}
+		content := fmt.Sprintf("The incident (%s) was dismissed. %s", incident.Rule, req.Reasoning)
+		if !req.Dismiss {
+			to = models.IncidentSanctioned
+			sanction := models.Sanction{Level: req.Level, ImposedAt: now}
+			if req.Level == models.SanctionFine {
+				sanction.FineAmount = models.RoundMoney(req.FineAmount)
+			}
+			set["sanction"] = sanction
+			content = fmt.Sprintf("A sanction (%s) was imposed for the incident (%s). %s", req.Level, incident.Rule, req.Reasoning)
+			if sanction.FineAmount > 0 {
+				content += fmt.Sprintf(" The fine of %.2f can be paid in the app.", sanction.FineAmount)
+			}
+		}
+		if err := dc.changeIncidentStatus(c, incident, to, req.Reasoning, set); err != nil {
+			c.JSON(incidentErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		dc.notifyIncident(incident, "Disciplinary decision", content)
+		c.JSON(http.StatusOK, gin.H{"message": "Incident " + to})
+	}
+}
+
+// PayFine charges the fine of a sanctioned incident through the payment provider.
+func (dc *DormController) PayFine() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.FinePaymentRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		incident, ok := dc.incidentForActor(c)
+		if !ok {
+			return
+		}
+		if incident.Sanction == nil || incident.Sanction.FineAmount <= 0 || incident.Sanction.FinePaidAt != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": data.ErrFineNotDue.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		amount := incident.Sanction.FineAmount
+		charge, err := dc.payments.Charge(ctx, payments.ChargeRequest{
+			PayerId:     incident.StudentId.Hex(),
+			Amount:      amount,
+			Description: "Dorm fine: " + incident.Rule,
+			Token:       req.Token,
+		})
+		if err != nil {
+			c.JSON(incidentErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		if err := dc.repo.RecordFinePayment(incident.Id, charge.Reference); err != nil {
+			if refundErr := dc.payments.Refund(ctx, charge.Reference, amount); refundErr != nil {
+				dc.logger.Printf("could not refund payment %s: %v", charge.Reference, refundErr)
+			}
+			c.JSON(incidentErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Fine paid", "reference": charge.Reference})
+	}
+}
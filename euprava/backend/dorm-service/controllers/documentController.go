@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"dorm-service/data"
+	"dorm-service/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func documentErrorCode(err error) int {
+	switch {
+	case errors.Is(err, data.ErrDocumentNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, data.ErrDocumentsClosed), errors.Is(err, data.ErrDocumentReviewed):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// checkRequiredDocuments makes sure a selection only requires known document types,
+// each of them once.
+func checkRequiredDocuments(selection *models.Selection) error {
+	seen := map[string]bool{}
+	for _, docType := range selection.RequiredDocuments {
+		if !models.DocumentTypes[docType] {
+			return fmt.Errorf("unknown document type %s", docType)
+		}
+		if seen[docType] {
+			return fmt.Errorf("document type %s is required more than once", docType)
+		}
+		seen[docType] = true
+	}
+	return nil
+}
+
+// applicationForActor loads the application in the url. Students can only reach
+// their own applications.
+func (dc *DormController) applicationForActor(c *gin.Context) (*models.Application, error) {
+	app, err := dc.repo.GetApplicationById(c.Param("id"))
+	if err != nil {
+		return nil, err
+	}
+	if uid, role := actor(c); role == "STUDENT" && app.StudentId.Hex() != uid {
+		return nil, fmt.Errorf("no application found with id: %s", c.Param("id"))
+	}
+	return app, nil
+}
+
+// documentParam returns the document of the application named in the url.
+func documentParam(c *gin.Context, app *models.Application) (*models.SupportingDocument, error) {
+	docId, err := primitive.ObjectIDFromHex(c.Param("docId"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid document ID", data.ErrDocumentNotFound)
+	}
+	doc := app.Document(docId)
+	if doc == nil {
+		return nil, data.ErrDocumentNotFound
+	}
+	return doc, nil
+}
+
+// UploadApplicationDocument attaches a supporting document to an application. The
+// file is sent in the "document" form field and its kind in the "type" field; only
+// PDFs and JPEG or PNG images are accepted.
+func (dc *DormController) UploadApplicationDocument() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		docType := c.PostForm("type")
+		if !models.DocumentTypes[docType] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown document type"})
+			return
+		}
+		app, err := dc.applicationForActor(c)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if app.Status != models.StatusPending && app.Status != models.StatusUnderReview {
+			c.JSON(http.StatusConflict, gin.H{"error": data.ErrDocumentsClosed.Error()})
+			return
+		}
+
+		saved, status, err := saveUpload(c, "document", DocumentDir(), app.Id.Hex(),
+			maxDocumentSize, documentExtensions, "a PDF, JPEG or PNG file")
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		doc := &models.SupportingDocument{
+			Type:        docType,
+			FileName:    filepath.Base(saved.header.Filename),
+			Path:        saved.filename,
+			ContentType: saved.contentType,
+			Size:        saved.header.Size,
+		}
+		if err := dc.repo.AddApplicationDocument(app.Id, doc); err != nil {
+			os.Remove(filepath.Join(DocumentDir(), saved.filename))
+			c.JSON(documentErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"Document uploaded": doc})
+	}
+}
+
+// GetApplicationDocument sends the file of a supporting document.
+func (dc *DormController) GetApplicationDocument() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		app, err := dc.applicationForActor(c)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		doc, err := documentParam(c, app)
+		if err != nil {
+			c.JSON(documentErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Content-Type", doc.ContentType)
+		c.FileAttachment(filepath.Join(DocumentDir(), doc.Path), doc.FileName)
+	}
+}
+
+// DeleteApplicationDocument removes a document that has not been reviewed yet, for
+// example one uploaded by mistake.
+func (dc *DormController) DeleteApplicationDocument() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		app, err := dc.applicationForActor(c)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		doc, err := documentParam(c, app)
+		if err != nil {
+			c.JSON(documentErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		if err := dc.repo.RemoveApplicationDocument(app.Id, doc.Id); err != nil {
+			c.JSON(documentErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		if err := os.Remove(filepath.Join(DocumentDir(), doc.Path)); err != nil {
+			dc.logger.Printf("could not remove document file %s: %v", doc.Path, err)
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Document removed"})
+	}
+}
+
+// ReviewApplicationDocument lets a reviewer accept or reject a supporting document.
+// The student is told when a document is rejected so they can upload a new one.
+func (dc *DormController) ReviewApplicationDocument() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.DocumentReview
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if !req.Accepted && req.Note == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "a note is required when rejecting a document"})
+			return
+		}
+		app, err := dc.repo.GetApplicationById(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		doc, err := documentParam(c, app)
+		if err != nil {
+			c.JSON(documentErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		status := models.DocumentAccepted
+		if !req.Accepted {
+			status = models.DocumentRejected
+		}
+		if err := dc.repo.ReviewApplicationDocument(app.Id, doc.Id, status, actorName(c), req.Note); err != nil {
+			c.JSON(documentErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		if !req.Accepted {
+			content := fmt.Sprintf("Your document %s (%s) was rejected: %s. Please upload a new one.", doc.FileName, doc.Type, req.Note)
+			if err := dc.repo.Notify(app.StudentId, "Document rejected", content); err != nil {
+				dc.logger.Printf("could not notify student %s: %v", app.StudentId.Hex(), err)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Document " + status})
+	}
+}
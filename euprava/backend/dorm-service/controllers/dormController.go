@@ -3,29 +3,36 @@ package controllers
 import (
 	"dorm-service/data"
 	"dorm-service/models"
+	"dorm-service/payments"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
 	"sort"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type DormController struct {
-	logger *log.Logger
-	repo   *data.DormRepo
+	logger   *log.Logger
+	repo     *data.DormRepo
+	payments payments.Provider
 }
 
 var validate = validator.New()
 
-func NewDormController(l *log.Logger, r *data.DormRepo) *DormController {
-	return &DormController{l, r}
+var ErrNotEnrolled = errors.New("student is not currently enrolled")
+
+func NewDormController(l *log.Logger, r *data.DormRepo, p payments.Provider) *DormController {
+	return &DormController{l, r, p}
 }
 func (dc DormController) GetStudentByID(studentId string) (*models.Student, error) {
 
@@ -50,33 +57,115 @@ func (dc DormController) GetStudentByID(studentId string) (*models.Student, erro
 	return returnedStudent, nil
 
 }
-func validateSelectionPeriod(date1, date2 string) error {
+func universityServiceURL() string {
+	host := os.Getenv("UNIVERSITY_SERVICE_HOST")
+	port := os.Getenv("UNIVERSITY_SERVICE_PORT")
+	if host == "" {
+		host = "university-service"
+	}
+	if port == "" {
+		port = "8088"
+	}
+	return fmt.Sprintf("http://%s:%s", host, port)
+}
 
-	startDate, err := time.Parse("02-01-2006", date1)
+// GetAcademicRecord fetches the authoritative academic data for a student from university-service.
+func (dc DormController) GetAcademicRecord(studentId string) (*models.AcademicRecord, error) {
+
+	uniUrl := fmt.Sprintf("%s/students/%v", universityServiceURL(), studentId)
+	uniResponse, err := http.Get(uniUrl)
 	if err != nil {
-		return fmt.Errorf("error parsing start date: %s", err.Error())
+		dc.logger.Printf("Error making GET request for academic record: %v", err)
+		return nil, fmt.Errorf("error making GET request for academic record: %v", err)
 	}
-	endDate, err := time.Parse("02-01-2006", date2)
-	if err != nil {
-		return fmt.Errorf("error parsing end date: %s", err.Error())
+	defer uniResponse.Body.Close()
+
+	if uniResponse.StatusCode == http.StatusNotFound {
+		return nil, ErrNotEnrolled
 	}
+	if uniResponse.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(uniResponse.Body)
+		dc.logger.Println("error: ", string(body))
+		return nil, fmt.Errorf("uni service returned error: %s", string(body))
+	}
+	var record models.AcademicRecord
+	if err := json.NewDecoder(uniResponse.Body).Decode(&record); err != nil {
+		dc.logger.Printf("error parsing uni response body: %v\n", err)
+		return nil, fmt.Errorf("error parsing uni response body")
+	}
+	record.FetchedAt = time.Now()
+	return &record, nil
+}
 
-	if startDate.Before(time.Now()) || endDate.Before(time.Now()) {
+// validateSelectionPeriod checks the selection window and its time zone, filling in
+// the default zone when none is given.
+func validateSelectionPeriod(selection *models.Selection) error {
+	if selection.StartDate.IsZero() || selection.EndDate.IsZero() {
+		return fmt.Errorf("start and end date are required")
+	}
+	if selection.TimeZone == "" {
+		selection.TimeZone = models.DefaultTimeZone
+	}
+	if _, err := time.LoadLocation(selection.TimeZone); err != nil {
+		return fmt.Errorf("unknown time zone %s", selection.TimeZone)
+	}
+
+	if selection.StartDate.Before(time.Now()) || selection.EndDate.Before(time.Now()) {
 		return fmt.Errorf("you cannot make a selection period in the past")
 	}
-	if !startDate.Before(endDate) {
+	if !selection.StartDate.Before(selection.EndDate) {
 		return fmt.Errorf("end date date must be after start date")
 	}
 
-	duration := endDate.Sub(startDate)
-	dayDifference := int(duration.Hours() / 24)
-	if dayDifference < 14 {
+	if selection.EndDate.Sub(selection.StartDate) < 14*24*time.Hour {
 		return fmt.Errorf("the selection period must be at least two weeks")
 	}
 
 	return nil
 }
 
+// checkSelectionBuildings makes sure the selection's building is part of its
+// building list and that every listed building exists. A list with only the
+// selection's building is dropped, so single-building selections look as before.
+func (dc *DormController) checkSelectionBuildings(selection *models.Selection) error {
+	if len(selection.BuildingIds) == 0 {
+		return nil
+	}
+	buildings := []primitive.ObjectID{selection.BuildingId}
+	seen := map[primitive.ObjectID]bool{selection.BuildingId: true}
+	for _, id := range selection.BuildingIds {
+		if seen[id] {
+			continue
+		}
+		if _, err := dc.repo.GetBuilding(id.Hex()); err != nil {
+			return fmt.Errorf("building %s not found", id.Hex())
+		}
+		seen[id] = true
+		buildings = append(buildings, id)
+	}
+	selection.BuildingIds = nil
+	if len(buildings) > 1 {
+		selection.BuildingIds = buildings
+	}
+	return nil
+}
+
+// checkBuildingPreferences makes sure an applicant only ranks buildings of the
+// selection and ranks each of them once.
+func checkBuildingPreferences(selection *models.Selection, prefs []primitive.ObjectID) error {
+	seen := map[primitive.ObjectID]bool{}
+	for _, id := range prefs {
+		if !selection.Covers(id) {
+			return fmt.Errorf("building %s is not part of this selection", id.Hex())
+		}
+		if seen[id] {
+			return fmt.Errorf("building %s is ranked more than once", id.Hex())
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
 func (dc *DormController) GetSelection() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := c.Param("id")
@@ -100,7 +189,8 @@ func (dc *DormController) InsertSelection() gin.HandlerFunc {
 	return func(c *gin.Context) {
 
 		var selection models.Selection
-		buildingid := c.Param("buildingId")
+		// The selection routes share the :id wildcard; here it names the building.
+		buildingid := c.Param("id")
 
 		buildingObjectId, err := primitive.ObjectIDFromHex(buildingid)
 		selection.BuildingId = buildingObjectId
@@ -115,11 +205,19 @@ func (dc *DormController) InsertSelection() gin.HandlerFunc {
 			return
 		}
 
-		err = validateSelectionPeriod(selection.StartDate, selection.EndDate)
+		err = validateSelectionPeriod(&selection)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error:": err.Error()})
 			return
 		}
+		if err := dc.checkSelectionBuildings(&selection); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := checkRequiredDocuments(&selection); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
 		overlapErr := dc.repo.CheckSelectionOverlap(selection, false)
 		if overlapErr != nil {
@@ -157,11 +255,19 @@ func (dc *DormController) UpdateSelection() gin.HandlerFunc {
 			return
 		}
 
-		err = validateSelectionPeriod(selection.StartDate, selection.EndDate)
+		err = validateSelectionPeriod(&selection)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error:": err.Error()})
 			return
 		}
+		if err := dc.checkSelectionBuildings(&selection); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := checkRequiredDocuments(&selection); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
 		overlapErr := dc.repo.CheckSelectionOverlap(selection, true)
 		if overlapErr != nil {
@@ -202,8 +308,53 @@ func (dc *DormController) InsertApplication() gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error: student/selection id not found. Student ": studentId})
 			return
 		}
+		studentObjectId, err := primitive.ObjectIDFromHex(studentId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid student ID"})
+			return
+		}
 		var application models.Application
 
+		var req models.ApplicationRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+				return
+			}
+		}
+		if err := dc.checkRoomTypes(req.RoomTypePreferences...); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		application.RoomTypePreferences = req.RoomTypePreferences
+
+		selection, err := dc.repo.GetSelection(selectionId)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if !selection.IsOpen(time.Now()) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "applications for this selection are accepted only between " + selection.Period()})
+			return
+		}
+		if err := checkBuildingPreferences(selection, req.BuildingPreferences); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		application.BuildingPreferences = req.BuildingPreferences
+
+		renewals, _, err := dc.repo.SelectionRenewals(selection.Id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		for _, renewal := range renewals {
+			if renewal.StudentId == studentObjectId {
+				c.JSON(http.StatusConflict, gin.H{"error": "you have already renewed your place for this year"})
+				return
+			}
+		}
+
 		student, err := dc.GetStudentByID(studentId)
 		if student == nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Student not found"})
@@ -213,8 +364,29 @@ func (dc *DormController) InsertApplication() gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		academic, err := dc.GetAcademicRecord(studentId)
+		if err == nil && !academic.Enrolled {
+			err = ErrNotEnrolled
+		}
+		if err == ErrNotEnrolled {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		academic.ApplyTo(student)
+
 		application.Student = student
-		app, err := dc.repo.InsertApp(application, selectionId)
+		application.StudentId = studentObjectId
+		application.Academic = academic
+		submittedBy, role := actor(c)
+		app, err := dc.repo.InsertApp(application, selectionId, submittedBy, role)
+		if err == data.ErrDuplicateApplication {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
 			return
@@ -224,34 +396,92 @@ func (dc *DormController) InsertApplication() gin.HandlerFunc {
 	}
 }
 
+// GetAllApplications lists applications for review a page at a time. They can be
+// filtered by selectionId, status, year, scholarship and incomplete, and ordered by
+// score with ?sort=score (best first) or ?sort=-score.
 func (dc *DormController) GetAllApplications() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		var filter models.ApplicationFilter
+		if err := c.ShouldBindQuery(&filter); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid filter"})
+			return
+		}
+		query, err := dc.applicationQuery(filter)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		order := bson.D{{Key: "createdAt", Value: 1}}
+		switch c.Query("sort") {
+		case "", "createdAt":
+		case "score":
+			order = bson.D{{Key: "student.gpa", Value: -1}, {Key: "student.year", Value: -1}}
+		case "-score":
+			order = bson.D{{Key: "student.gpa", Value: 1}, {Key: "student.year", Value: 1}}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be score, -score or createdAt"})
+			return
+		}
+
+		page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+		if err != nil || page < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid page"})
+			return
+		}
+		pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", strconv.Itoa(models.DefaultPageSize)))
+		if err != nil || pageSize < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid page size"})
+			return
+		}
+		if pageSize > models.MaxPageSize {
+			pageSize = models.MaxPageSize
+		}
 
-		apps, err := dc.repo.GetAllApplications()
+		apps, total, err := dc.repo.FindApplications(query, order, int64((page-1)*pageSize), int64(pageSize))
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
 			return
 		}
-		c.JSON(http.StatusOK, apps)
+		c.JSON(http.StatusOK, models.ApplicationPage{Items: apps, Total: total, Page: page, PageSize: pageSize})
 	}
 }
+
+// GetApplication returns the logged in student's application for the selection
+// given in the selectionId query parameter, or all of their applications if it is omitted.
+// Applications of selections with a published ranking list show the student's position.
 func (dc *DormController) GetApplication() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id, exists := c.Get("uid")
-		sid, exists1 := c.Get("sid")
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "student id not found in token"})
+			return
+		}
 		studentId := id.(string)
-		selectionId := sid.(string)
 
-		if !exists || !exists1 {
-			c.JSON(http.StatusNotFound, gin.H{"error: student/selection id not found in token": studentId})
+		selectionId := c.Query("selectionId")
+		if selectionId == "" {
+			studentObjectId, err := primitive.ObjectIDFromHex(studentId)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid student ID"})
+				return
+			}
+			apps, err := dc.repo.GetApplicationsByStudent(studentObjectId)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+				return
+			}
+			dc.attachRankings(apps...)
+			c.JSON(http.StatusOK, apps)
 			return
 		}
 
 		app, err := dc.repo.GetApplication(studentId, selectionId)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
 		}
+		dc.attachRankings(app)
 		c.JSON(http.StatusOK, app)
 
 	}
@@ -284,15 +514,19 @@ func (dc *DormController) DeleteApplication() gin.HandlerFunc {
 // }
 
 func (dc *DormController) RankStudents(selection models.Selection) ([]models.Student, error) {
+	apps, err := dc.repo.GetApplicationsBySelection(selection.Id)
+	if err != nil {
+		return nil, err
+	}
 	var studentsRanked []models.Student
-	for _, app := range selection.Applications {
+	for _, app := range apps {
+		if app.Student == nil {
+			continue
+		}
 		studentsRanked = append(studentsRanked, *app.Student)
 	}
 	sort.Slice(studentsRanked, func(i, j int) bool {
-		if studentsRanked[i].GPA == studentsRanked[j].GPA {
-			return studentsRanked[i].Year > studentsRanked[j].Year
-		}
-		return studentsRanked[i].GPA > studentsRanked[j].GPA
+		return rankedBefore(&studentsRanked[i], &studentsRanked[j])
 	})
 	return studentsRanked, nil
 
@@ -318,60 +552,62 @@ func (dc *DormController) RankStudents(selection models.Selection) ([]models.Stu
 
 // }
 
-func (dc *DormController) AssignStudents(rankedStudents []models.Student, buildingId string) error {
+// AssignStudents places the ranked applicants into free rooms of a building,
+// honouring their room type preferences, and records each placement on the application.
+// Applicants who already live in a room are skipped and returned.
+func (dc *DormController) AssignStudents(rankedApps []*models.Application, buildingId string) ([]*models.Application, error) {
 	// Convert buildingId to ObjectID
 	buildingObjectId, err := primitive.ObjectIDFromHex(buildingId)
 	if err != nil {
-		return fmt.Errorf("invalid building ID: %v", err)
+		return nil, fmt.Errorf("invalid building ID: %v", err)
 	}
 	building, err := dc.GetBuildingLocal(buildingObjectId.Hex())
 	if err != nil {
-		return fmt.Errorf(err.Error())
+		return nil, fmt.Errorf(err.Error())
 	}
+	roomTypes, err := dc.repo.GetRoomTypes()
+	if err != nil {
+		return nil, err
+	}
+	types := roomTypes.ById()
 
-	// Iterate through ranked students and assign them to rooms
-	studentIndex := 0
+	free := make(map[int]int, len(building.Rooms))
 	for _, room := range building.Rooms {
-		// Check room capacity and fill it
-		for room.Students == nil || len(*room.Students) < room.Capacity {
-			if studentIndex >= len(rankedStudents) {
-				break // No more students to assign
-			}
-
-			// Add student to room
-			if room.Students == nil {
-				room.Students = &models.Students{}
-			}
-
-			student := &rankedStudents[studentIndex]
-			*room.Students = append(*room.Students, student)
-
-			// Increment student index
-			studentIndex++
+		free[room.Room_Number] = room.Capacity - room.Occupancy()
+	}
 
-			// If room is full, break and move to the next room
-			if len(*room.Students) >= room.Capacity {
-				break
-			}
+	var skipped []*models.Application
+	// Iterate through ranked applicants and assign them to rooms
+	for i, app := range rankedApps {
+		room, rank := pickRoom(&building, types, app.RoomTypePreferences, free)
+		if room == nil {
+			return skipped, fmt.Errorf("not all students could be assigned to rooms, remaining: %d", len(rankedApps)-i)
 		}
 
-		err := dc.EditRoomLocal(room.Room_Number, buildingId, *room)
+		err := dc.repo.AddStudentToRoom(buildingObjectId, room.Room_Number, app.Student)
+		if errors.Is(err, data.ErrStudentHasRoom) {
+			skipped = append(skipped, app)
+			continue
+		}
 		if err != nil {
-			return fmt.Errorf("failed to update room %d: %v", room.Room_Number, err)
+			return skipped, fmt.Errorf("failed to update room %d: %v", room.Room_Number, err)
 		}
+		free[room.Room_Number]--
 
-		// Break if all students have been assigned
-		if studentIndex >= len(rankedStudents) {
-			break
+		app.Placement = &models.RoomAssignment{
+			BuildingId:     buildingObjectId,
+			RoomNumber:     room.Room_Number,
+			RoomTypeId:     room.RoomTypeId,
+			MonthlyPrice:   building.RoomPrice(room, types),
+			AssignedAt:     time.Now(),
+			PreferenceRank: rank,
+		}
+		if err := dc.repo.SetApplicationPlacement(app.Id, app.Placement); err != nil {
+			return skipped, err
 		}
 	}
 
-	//If not all students could be assigned, handle that case
-	if studentIndex < len(rankedStudents) {
-		return fmt.Errorf("not all students could be assigned to rooms, remaining: %d", len(rankedStudents)-studentIndex)
-	}
-
-	return nil
+	return skipped, nil
 }
 
 func (dc *DormController) InsertBuilding() gin.HandlerFunc {
@@ -387,6 +623,8 @@ func (dc *DormController) InsertBuilding() gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
 			return
 		}
+		// Managers are only assigned through SetBuildingManagers.
+		building.Managers = nil
 		buildingId, err := dc.repo.InsertBuilding(building)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"database error": err.Error()})
@@ -406,6 +644,15 @@ func (dc *DormController) GetBuilding() gin.HandlerFunc {
 			c.JSON(http.StatusNotFound, err.Error())
 			return
 		}
+		roomTypes, err := dc.repo.GetRoomTypes()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		types := roomTypes.ById()
+		for _, room := range building.Rooms {
+			room.EffectivePrice = building.RoomPrice(room, types)
+		}
 
 		c.JSON(http.StatusOK, building)
 
@@ -416,59 +663,191 @@ func (dc *DormController) GetBuildingLocal(buildingId string) (models.Building,
 
 	building, err := dc.repo.GetBuilding(buildingId)
 	if err != nil {
-		return *building, err
+		return models.Building{}, err
 	}
 	return *building, nil
 
 }
+
+// roomErrorCode maps repository errors about buildings and rooms to response codes.
+func roomErrorCode(err error) int {
+	switch {
+	case errors.Is(err, data.ErrBuildingNotFound), errors.Is(err, data.ErrRoomNotFound), errors.Is(err, data.ErrStudentNotInRoom):
+		return http.StatusNotFound
+	case errors.Is(err, data.ErrBuildingOccupied), errors.Is(err, data.ErrRoomConflict), errors.Is(err, data.ErrRoomFull),
+		errors.Is(err, data.ErrRoomChangedOrFull), errors.Is(err, data.ErrStudentHasRoom):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func (dc *DormController) UpdateBuilding() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		buildingId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid building ID"})
+			return
+		}
+
+		var building models.Building
+		if err := c.ShouldBindJSON(&building); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "parsing failed"})
+			return
+		}
+		if err := validate.Struct(building); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := dc.repo.UpdateBuilding(buildingId, &building); err != nil {
+			c.JSON(roomErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Building updated successfully"})
+	}
+}
+
 func (dc *DormController) DeleteBuilding() gin.HandlerFunc {
 	return func(c *gin.Context) {
 
 		buildingId := c.Param("id")
-		building, err := dc.repo.GetBuilding(buildingId)
-		if err != nil {
-			c.JSON(http.StatusNotFound, err.Error())
+		if err := dc.repo.DeleteBuilding(buildingId); err != nil {
+			c.JSON(roomErrorCode(err), gin.H{"error": err.Error()})
+			return
 		}
 
-		c.JSON(http.StatusOK, building)
+		c.JSON(http.StatusOK, gin.H{"message": "Building deleted successfully"})
 
 	}
 }
 
 func (dc *DormController) InsertRoom() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		type RoomInfo struct {
-			Capacity int `json:"capacity"`
-		}
 		buildingIdParam := c.Param("id")
-		var room RoomInfo
+		var room models.Room
 
 		if err := c.BindJSON(&room); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "parsing failed", "details": err.Error()})
 			return
 		}
+		if err := validate.Struct(room); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := dc.checkRoomTypes(room.RoomTypeId); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-		err := dc.repo.InsertRoom(room.Capacity, buildingIdParam)
+		err := dc.repo.InsertRoom(room, buildingIdParam)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.JSON(roomErrorCode(err), gin.H{"error": err.Error()})
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{"Room created": room})
 	}
 }
 
-func (dc *DormController) EditRoomLocal(roomNumber int, buildingIdString string, updatedRoom models.Room) error {
+// UpdateRoom changes a room's number, floor or capacity.
+func (dc *DormController) UpdateRoom() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		buildingId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid building ID"})
+			return
+		}
+		roomNumber, err := strconv.Atoi(c.Param("number"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room number"})
+			return
+		}
+
+		var room models.Room
+		if err := c.ShouldBindJSON(&room); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "parsing failed", "details": err.Error()})
+			return
+		}
+		if err := validate.Struct(room); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := dc.checkRoomTypes(room.RoomTypeId); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-	buildingId, err := primitive.ObjectIDFromHex(buildingIdString)
-	if err != nil {
-		return err
+		if err := dc.repo.EditRoom(roomNumber, buildingId, &room); err != nil {
+			c.JSON(roomErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Room updated successfully"})
 	}
+}
+
+func (dc *DormController) DeleteRoom() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		buildingId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid building ID"})
+			return
+		}
+		roomNumber, err := strconv.Atoi(c.Param("number"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room number"})
+			return
+		}
 
-	if err := dc.repo.EditRoom(roomNumber, buildingId, &updatedRoom); err != nil {
-		return err
+		if err := dc.repo.DeleteRoom(buildingId, roomNumber); err != nil {
+			c.JSON(roomErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Room deleted successfully"})
 	}
+}
 
-	return nil
+// MoveStudent moves a resident from their current room into another room,
+// possibly in another building. The old room is billed up to the move and the new
+// one from then on.
+func (dc *DormController) MoveStudent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.MoveStudentRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "parsing failed", "details": err.Error()})
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		studentId, err := primitive.ObjectIDFromHex(req.StudentId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid student ID"})
+			return
+		}
+		toBuildingId, err := primitive.ObjectIDFromHex(req.ToBuildingId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid building ID"})
+			return
+		}
+
+		fromBuilding, fromRoom, err := dc.repo.FindStudentRoom(studentId)
+		if err != nil {
+			c.JSON(roomErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		stayedSince := dc.residencyStart(studentId, fromBuilding.Id)
+
+		movedBy, _ := actor(c)
+		if err := dc.repo.MoveStudent(studentId, toBuildingId, req.ToRoomNumber, movedBy); err != nil {
+			c.JSON(roomErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		err = dc.moveRent(studentId, fromBuilding.Id, fromRoom.Room_Number, toBuildingId, req.ToRoomNumber, stayedSince, time.Now(), movedBy)
+		if err != nil {
+			dc.logger.Printf("could not settle rent of student %s after a move: %v", studentId.Hex(), err)
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Student moved successfully"})
+	}
 }
 
 func (dc *DormController) GetRoom() gin.HandlerFunc {
@@ -477,12 +856,13 @@ func (dc *DormController) GetRoom() gin.HandlerFunc {
 		buildingIdParam := c.Param("id")
 		roomNumber, err := strconv.Atoi(roomNumberParam)
 		if err != nil {
-			fmt.Println("Error:", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room number"})
 			return
 		}
 		room, err := dc.repo.GetRoom(roomNumber, buildingIdParam)
 		if err != nil {
 			c.JSON(http.StatusNotFound, err.Error())
+			return
 		}
 
 		c.JSON(http.StatusOK, room)
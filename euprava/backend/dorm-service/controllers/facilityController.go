@@ -0,0 +1,391 @@
+package controllers
+
+import (
+	"dorm-service/data"
+	"dorm-service/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func facilityErrorCode(err error) int {
+	switch {
+	case errors.Is(err, data.ErrFacilityNotFound), errors.Is(err, data.ErrBookingNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, data.ErrSlotFull), errors.Is(err, data.ErrBookingChanged),
+		errors.Is(err, data.ErrAlreadyBooked), errors.Is(err, data.ErrBookingLimit):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// startOfWeek returns midnight of the Monday of t's week.
+func startOfWeek(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// bookingForActor loads a booking and checks that a student only touches their own.
+func (dc *DormController) bookingForActor(c *gin.Context) (*models.Booking, bool) {
+	booking, err := dc.repo.GetBooking(c.Param("id"))
+	if err != nil {
+		c.JSON(facilityErrorCode(err), gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if uid, role := actor(c); role == "STUDENT" && booking.StudentId.Hex() != uid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return nil, false
+	}
+	return booking, true
+}
+
+func (dc *DormController) InsertFacility() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		buildingId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid building ID"})
+			return
+		}
+		var facility models.Facility
+		if err := c.ShouldBindJSON(&facility); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(facility); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if slots, err := facility.Slots(time.Now()); err != nil || len(slots) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "opening hours must fit at least one slot"})
+			return
+		}
+		if _, err := dc.repo.GetBuilding(buildingId.Hex()); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": data.ErrBuildingNotFound.Error()})
+			return
+		}
+
+		facility.BuildingId = buildingId
+		if err := dc.repo.InsertFacility(&facility); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"Facility created": facility})
+	}
+}
+
+// GetFacilities lists the bookable facilities of a building; admins can ask for all
+// of them with ?all=true.
+func (dc *DormController) GetFacilities() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		buildingId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid building ID"})
+			return
+		}
+		_, role := actor(c)
+		facilities, err := dc.repo.GetFacilities(buildingId, role == "ADMIN" && c.Query("all") == "true")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, facilities)
+	}
+}
+
+func (dc *DormController) UpdateFacility() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		facility, err := dc.repo.GetFacility(c.Param("id"))
+		if err != nil {
+			c.JSON(facilityErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		var updated models.Facility
+		if err := c.ShouldBindJSON(&updated); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(updated); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if slots, err := updated.Slots(time.Now()); err != nil || len(slots) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "opening hours must fit at least one slot"})
+			return
+		}
+		if err := dc.repo.UpdateFacility(facility.Id, &updated); err != nil {
+			c.JSON(facilityErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Facility updated successfully"})
+	}
+}
+
+// DeleteFacility takes a facility out of use. Its upcoming bookings are cancelled
+// and the residents who made them are notified; past bookings are kept.
+func (dc *DormController) DeleteFacility() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		facility, err := dc.repo.GetFacility(c.Param("id"))
+		if err != nil {
+			c.JSON(facilityErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		facility.Active = false
+		if err := dc.repo.UpdateFacility(facility.Id, facility); err != nil {
+			c.JSON(facilityErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		cancelled, err := dc.repo.CancelFacilityBookings(facility.Id, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		for _, booking := range cancelled {
+			content := fmt.Sprintf("Your booking of %s on %s was cancelled because the facility is no longer available.",
+				facility.Name, booking.Start.Format("02-01-2006 15:04"))
+			if err := dc.repo.Notify(booking.StudentId, "Booking cancelled", content); err != nil {
+				dc.logger.Printf("could not notify student %s: %v", booking.StudentId.Hex(), err)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Facility removed", "cancelledBookings": len(cancelled)})
+	}
+}
+
+// GetFacilitySlots lists the slots of a facility on a day (dd-mm-yyyy, default today)
+// with the number of free places in each.
+func (dc *DormController) GetFacilitySlots() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		facility, err := dc.repo.GetFacility(c.Param("id"))
+		if err != nil {
+			c.JSON(facilityErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		day := time.Now()
+		if value := c.Query("date"); value != "" {
+			day, err = time.ParseInLocation("02-01-2006", value, time.Local)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date, expected dd-mm-yyyy"})
+				return
+			}
+		}
+
+		slots, err := facility.Slots(day)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(slots) > 0 {
+			bookings, err := dc.repo.GetBookings(bson.M{
+				"facilityId": facility.Id,
+				"holdsSlot":  true,
+				"start":      bson.M{"$gte": slots[0].Start, "$lt": slots[len(slots)-1].End},
+			})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+				return
+			}
+			taken := map[int64]int{}
+			for _, booking := range bookings {
+				taken[booking.Start.Unix()]++
+			}
+			for i := range slots {
+				slots[i].Free -= taken[slots[i].Start.Unix()]
+			}
+		}
+		c.JSON(http.StatusOK, slots)
+	}
+}
+
+// BookFacility books a slot for the logged in student, who has to live in the
+// facility's building and be within the facility's booking limits.
+func (dc *DormController) BookFacility() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.BookingRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		facility, err := dc.repo.GetFacility(c.Param("id"))
+		if err != nil {
+			c.JSON(facilityErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		if !facility.Active {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "facility cannot be booked"})
+			return
+		}
+		studentId, err := studentParam(c, "")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		building, _, err := dc.repo.FindStudentRoom(studentId)
+		if err != nil || building.Id != facility.BuildingId {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only residents of the building can book its facilities"})
+			return
+		}
+
+		now := time.Now()
+		slot, err := facility.SlotAt(req.Start.In(time.Local))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !slot.Start.After(now) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "slot has already started"})
+			return
+		}
+
+		if facility.NoShowLimit > 0 {
+			noShows, err := dc.repo.CountBookings(bson.M{
+				"studentId": studentId,
+				"status":    models.BookingNoShow,
+				"start":     bson.M{"$gte": now.Add(-models.NoShowWindow)},
+			})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+				return
+			}
+			if int(noShows) >= facility.NoShowLimit {
+				c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("booking is blocked after %d no-shows in the last 30 days", noShows)})
+				return
+			}
+		}
+		booking := models.Booking{
+			FacilityId: facility.Id,
+			BuildingId: facility.BuildingId,
+			StudentId:  studentId,
+			Start:      slot.Start,
+			End:        slot.End,
+		}
+		if err := dc.repo.InsertBooking(&booking, facility, startOfWeek(slot.Start)); err != nil {
+			c.JSON(facilityErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"Booking created": booking})
+	}
+}
+
+func (dc *DormController) GetMyBookings() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		studentId, err := studentParam(c, "")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		filter := bson.M{"studentId": studentId}
+		if c.Query("upcoming") == "true" {
+			filter["status"] = models.BookingBooked
+			filter["end"] = bson.M{"$gt": time.Now()}
+		}
+		bookings, err := dc.repo.GetBookings(filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, bookings)
+	}
+}
+
+// GetFacilityBookings lists a facility's bookings, optionally on one day (dd-mm-yyyy).
+func (dc *DormController) GetFacilityBookings() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		facility, err := dc.repo.GetFacility(c.Param("id"))
+		if err != nil {
+			c.JSON(facilityErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		filter := bson.M{"facilityId": facility.Id}
+		if value := c.Query("date"); value != "" {
+			day, err := time.ParseInLocation("02-01-2006", value, time.Local)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date, expected dd-mm-yyyy"})
+				return
+			}
+			filter["start"] = bson.M{"$gte": day, "$lt": day.AddDate(0, 0, 1)}
+		}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+		bookings, err := dc.repo.GetBookings(filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, bookings)
+	}
+}
+
+// CancelBooking cancels a booking that has not started yet and frees its place.
+func (dc *DormController) CancelBooking() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		booking, ok := dc.bookingForActor(c)
+		if !ok {
+			return
+		}
+		if !booking.Start.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "bookings can only be cancelled before they start"})
+			return
+		}
+		if err := dc.repo.SetBookingStatus(booking.Id, models.BookingBooked, models.BookingCancelled); err != nil {
+			c.JSON(facilityErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Booking cancelled"})
+	}
+}
+
+// CheckInBooking records that the resident showed up, from shortly before the
+// booking starts until it ends. Bookings nobody checks in to become no-shows.
+func (dc *DormController) CheckInBooking() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		booking, ok := dc.bookingForActor(c)
+		if !ok {
+			return
+		}
+		now := time.Now()
+		if now.Before(booking.Start.Add(-models.CheckInEarly)) || now.After(booking.End) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "you can only check in around the time of your booking"})
+			return
+		}
+		if err := dc.repo.SetBookingStatus(booking.Id, models.BookingBooked, models.BookingAttended); err != nil {
+			c.JSON(facilityErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Checked in"})
+	}
+}
+
+// MarkBookingNoShow lets an admin record a no-show without waiting for the booking to end.
+func (dc *DormController) MarkBookingNoShow() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		booking, ok := dc.bookingForActor(c)
+		if !ok {
+			return
+		}
+		if booking.Start.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "booking has not started yet"})
+			return
+		}
+		if err := dc.repo.SetBookingStatus(booking.Id, models.BookingBooked, models.BookingNoShow); err != nil {
+			c.JSON(facilityErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Booking marked as no-show"})
+	}
+}
+
+// RunNoShowJob marks finished bookings nobody checked in to as no-shows.
+func (dc *DormController) RunNoShowJob() {
+	marked, err := dc.repo.MarkNoShows(time.Now())
+	if err != nil {
+		dc.logger.Printf("marking no-shows failed: %v", err)
+	} else if marked > 0 {
+		dc.logger.Printf("marked %d bookings as no-shows", marked)
+	}
+}
@@ -0,0 +1,520 @@
+package controllers
+
+import (
+	"context"
+	"dorm-service/data"
+	"dorm-service/models"
+	"dorm-service/payments"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// reminderInterval is how often a student is reminded about the same overdue invoice.
+const reminderInterval = 7 * 24 * time.Hour
+
+// invoiceDueDays is the number of days a student has to pay an invoice, set by DORM_INVOICE_DUE_DAYS.
+func invoiceDueDays() int {
+	days, err := strconv.Atoi(os.Getenv("DORM_INVOICE_DUE_DAYS"))
+	if err != nil || days <= 0 {
+		return 15
+	}
+	return days
+}
+
+func invoiceErrorCode(err error) int {
+	switch {
+	case errors.Is(err, data.ErrInvoiceNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, data.ErrOverpayment), errors.Is(err, data.ErrInvoiceExists):
+		return http.StatusConflict
+	case errors.Is(err, payments.ErrPaymentDeclined):
+		return http.StatusPaymentRequired
+	}
+	return http.StatusInternalServerError
+}
+
+// residencyStart returns when a student moved into a building. Students who were
+// assigned a room but have not checked in yet are billed from their assignment.
+func (dc *DormController) residencyStart(studentId primitive.ObjectID, buildingId primitive.ObjectID) time.Time {
+	if residency, err := dc.repo.GetActiveResidency(studentId); err == nil && residency.BuildingId == buildingId {
+		return residency.From
+	}
+	apps, err := dc.repo.GetApplicationsByStudent(studentId)
+	if err != nil {
+		return time.Time{}
+	}
+	var start time.Time
+	for _, app := range apps {
+		if app.Placement != nil && app.Placement.BuildingId == buildingId && app.Placement.AssignedAt.After(start) {
+			start = app.Placement.AssignedAt
+		}
+	}
+	return start
+}
+
+// invoiceDueDate is when an invoice for the period starting at periodStart is due.
+func invoiceDueDate(periodStart time.Time) time.Time {
+	dueFrom := periodStart
+	if now := time.Now(); now.After(dueFrom) {
+		dueFrom = now
+	}
+	return dueFrom.AddDate(0, 0, invoiceDueDays())
+}
+
+// proRatedInvoice prepares the invoice for a stay in a room from..to within the month
+// starting at periodStart. A zero to means the stay continues past the month. It
+// returns nil when nothing is owed.
+func proRatedInvoice(studentId primitive.ObjectID, buildingId primitive.ObjectID, roomNumber int, price float64, periodStart time.Time, from time.Time, to time.Time) *models.Invoice {
+	amount, days, daysInPeriod := models.ProRate(price, periodStart, from, to)
+	if amount <= 0 {
+		return nil
+	}
+	billedFrom := periodStart
+	if from.After(billedFrom) {
+		billedFrom = from
+	}
+	billedTo := periodStart.AddDate(0, 1, 0)
+	if !to.IsZero() && to.Before(billedTo) {
+		billedTo = to
+	}
+	return &models.Invoice{
+		StudentId:    studentId,
+		BuildingId:   buildingId,
+		RoomNumber:   roomNumber,
+		Period:       periodStart.Format(models.PeriodLayout),
+		BilledFrom:   billedFrom,
+		BilledTo:     billedTo,
+		DaysBilled:   days,
+		DaysInPeriod: daysInPeriod,
+		MonthlyPrice: price,
+		Amount:       amount,
+	}
+}
+
+// roomPrice returns the monthly price of a room.
+func (dc *DormController) roomPrice(buildingId primitive.ObjectID, roomNumber int) (float64, error) {
+	building, err := dc.repo.GetBuilding(buildingId.Hex())
+	if err != nil {
+		return 0, err
+	}
+	roomTypes, err := dc.repo.GetRoomTypes()
+	if err != nil {
+		return 0, err
+	}
+	for _, room := range building.Rooms {
+		if room.Room_Number == roomNumber {
+			return building.RoomPrice(room, roomTypes.ById()), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: room #%d", data.ErrRoomNotFound, roomNumber)
+}
+
+// periodOf returns the start of the invoice period t falls into.
+func periodOf(t time.Time) time.Time {
+	local := t.In(time.Local)
+	return time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, time.Local)
+}
+
+// settleRent bills a stay in a room up to leftAt, when the student moved out of it.
+// Invoices that already cover days after leftAt are cut down to the days actually
+// stayed and the difference is credited on the ledger. If the month of leaving was
+// not invoiced yet, the days from the start of the stay are invoiced now.
+func (dc *DormController) settleRent(studentId primitive.ObjectID, buildingId primitive.ObjectID, roomNumber int, from time.Time, leftAt time.Time, recordedBy string) error {
+	invoices, err := dc.repo.GetInvoices(bson.M{
+		"studentId":  studentId,
+		"buildingId": buildingId,
+		"roomNumber": roomNumber,
+		"billedTo":   bson.M{"$gt": leftAt},
+	})
+	if err != nil {
+		return err
+	}
+	leavingPeriod := leftAt.In(time.Local).Format(models.PeriodLayout)
+	invoiced := false
+	for _, invoice := range invoices {
+		if invoice.Period == leavingPeriod {
+			invoiced = true
+		}
+		start, err := time.ParseInLocation(models.PeriodLayout, invoice.Period, time.Local)
+		if err != nil {
+			return err
+		}
+		amount, days, _ := models.ProRate(invoice.MonthlyPrice, start, invoice.BilledFrom, leftAt)
+		billedTo := leftAt
+		if billedTo.Before(invoice.BilledFrom) {
+			billedTo = invoice.BilledFrom
+		}
+		if _, err := dc.repo.CreditInvoice(invoice, amount, billedTo, days, recordedBy); err != nil {
+			return err
+		}
+	}
+	if invoiced {
+		return nil
+	}
+
+	existing, err := dc.repo.GetInvoices(bson.M{"studentId": studentId, "buildingId": buildingId, "roomNumber": roomNumber, "period": leavingPeriod})
+	if err != nil || len(existing) > 0 {
+		return err
+	}
+	price, err := dc.roomPrice(buildingId, roomNumber)
+	if err != nil {
+		return err
+	}
+	start := periodOf(leftAt)
+	invoice := proRatedInvoice(studentId, buildingId, roomNumber, price, start, from, leftAt)
+	if invoice == nil {
+		return nil
+	}
+	invoice.DueDate = invoiceDueDate(start)
+	if err := dc.repo.InsertInvoice(invoice); err != nil && err != data.ErrInvoiceExists {
+		return err
+	}
+	return nil
+}
+
+// moveDay splits a stay at a move: the stay in the old room ends at the midnight
+// before movedAt and the one in the new room starts at movedAt, so the day of the
+// move is billed to the new room only.
+func moveDay(movedAt time.Time) (time.Time, time.Time) {
+	local := movedAt.In(time.Local)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.Local), movedAt
+}
+
+// moveRent settles the rent of the room a student left at movedAt and bills the room
+// they moved into for the rest of that month.
+func (dc *DormController) moveRent(studentId primitive.ObjectID, fromBuildingId primitive.ObjectID, fromRoomNumber int, toBuildingId primitive.ObjectID, toRoomNumber int, stayedSince time.Time, movedAt time.Time, movedBy string) error {
+	leftAt, movedAt := moveDay(movedAt)
+	if err := dc.settleRent(studentId, fromBuildingId, fromRoomNumber, stayedSince, leftAt, movedBy); err != nil {
+		return err
+	}
+	price, err := dc.roomPrice(toBuildingId, toRoomNumber)
+	if err != nil {
+		return err
+	}
+	start := periodOf(movedAt)
+	invoice := proRatedInvoice(studentId, toBuildingId, toRoomNumber, price, start, movedAt, time.Time{})
+	if invoice == nil {
+		return nil
+	}
+	invoice.DueDate = invoiceDueDate(start)
+	if err := dc.repo.InsertInvoice(invoice); err != nil && err != data.ErrInvoiceExists {
+		return err
+	}
+	return nil
+}
+
+// GenerateInvoices issues the rent invoice for the given period to every current
+// resident. Residents who moved in during the period pay a pro-rated amount.
+// Students who already have an invoice for the period are skipped.
+func (dc *DormController) GenerateInvoices(period string) (int, error) {
+	periodStart, err := time.ParseInLocation(models.PeriodLayout, period, time.Local)
+	if err != nil {
+		return 0, fmt.Errorf("invalid period, expected YYYY-MM: %v", err)
+	}
+	periodEnd := periodStart.AddDate(0, 1, 0)
+
+	buildings, err := dc.repo.GetAllBuildings()
+	if err != nil {
+		return 0, err
+	}
+	roomTypes, err := dc.repo.GetRoomTypes()
+	if err != nil {
+		return 0, err
+	}
+	types := roomTypes.ById()
+
+	dueDate := invoiceDueDate(periodStart)
+
+	issued := 0
+	for _, building := range buildings {
+		for _, room := range building.Rooms {
+			if room.Students == nil {
+				continue
+			}
+			price := building.RoomPrice(room, types)
+			for _, student := range *room.Students {
+				if student == nil {
+					continue
+				}
+				from := dc.residencyStart(student.ID, building.Id)
+				if !from.Before(periodEnd) {
+					continue
+				}
+				// Current residents are billed until the end of the month; moving out
+				// or to another room settles the invoice later.
+				invoice := proRatedInvoice(student.ID, building.Id, room.Room_Number, price, periodStart, from, time.Time{})
+				if invoice == nil {
+					continue
+				}
+				invoice.DueDate = dueDate
+				err := dc.repo.InsertInvoice(invoice)
+				if err == data.ErrInvoiceExists {
+					continue
+				}
+				if err != nil {
+					return issued, err
+				}
+				issued++
+			}
+		}
+	}
+	return issued, nil
+}
+
+// SendOverdueReminders notifies students about unpaid invoices past their due date.
+func (dc *DormController) SendOverdueReminders() (int, error) {
+	now := time.Now()
+	invoices, err := dc.repo.GetInvoicesToRemind(now, reminderInterval)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, invoice := range invoices {
+		content := fmt.Sprintf("Your dorm rent invoice for %s was due on %s. Outstanding amount: %.2f.",
+			invoice.Period, invoice.DueDate.Format("02-01-2006"), invoice.Outstanding())
+		if err := dc.repo.Notify(invoice.StudentId, "Overdue rent invoice", content); err != nil {
+			return sent, err
+		}
+		if err := dc.repo.MarkReminderSent(invoice.Id, now); err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// RunBillingJobs issues this month's invoices and sends overdue reminders. It is safe
+// to run repeatedly and is started periodically from main.
+func (dc *DormController) RunBillingJobs() {
+	period := time.Now().Format(models.PeriodLayout)
+	if issued, err := dc.GenerateInvoices(period); err != nil {
+		dc.logger.Printf("invoice generation for %s failed: %v", period, err)
+	} else if issued > 0 {
+		dc.logger.Printf("issued %d invoices for %s", issued, period)
+	}
+	if sent, err := dc.SendOverdueReminders(); err != nil {
+		dc.logger.Printf("sending overdue reminders failed: %v", err)
+	} else if sent > 0 {
+		dc.logger.Printf("sent %d overdue reminders", sent)
+	}
+}
+
+func (dc *DormController) GenerateInvoicesHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		period := c.DefaultQuery("period", time.Now().Format(models.PeriodLayout))
+		issued, err := dc.GenerateInvoices(period)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "issued": issued})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"period": period, "issued": issued})
+	}
+}
+
+func (dc *DormController) SendRemindersHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sent, err := dc.SendOverdueReminders()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "sent": sent})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"sent": sent})
+	}
+}
+
+func (dc *DormController) GetInvoices() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := bson.M{}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+		if period := c.Query("period"); period != "" {
+			filter["period"] = period
+		}
+		if studentId := c.Query("studentId"); studentId != "" {
+			id, err := primitive.ObjectIDFromHex(studentId)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid student ID"})
+				return
+			}
+			filter["studentId"] = id
+		}
+		invoices, err := dc.repo.GetInvoices(filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, invoices)
+	}
+}
+
+func (dc *DormController) GetMyInvoices() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := actor(c)
+		studentId, err := primitive.ObjectIDFromHex(uid)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid student ID"})
+			return
+		}
+		invoices, err := dc.repo.GetInvoices(bson.M{"studentId": studentId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, invoices)
+	}
+}
+
+// PayInvoice charges the payment provider and books the payment on the invoice.
+// Students can only pay their own invoices.
+func (dc *DormController) PayInvoice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.PaymentRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		invoice, err := dc.repo.GetInvoice(c.Param("id"))
+		if err != nil {
+			c.JSON(invoiceErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		uid, role := actor(c)
+		if role == "STUDENT" && invoice.StudentId.Hex() != uid {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+		amount := models.RoundMoney(req.Amount)
+		if amount > invoice.Outstanding() {
+			c.JSON(http.StatusConflict, gin.H{"error": data.ErrOverpayment.Error(), "outstanding": invoice.Outstanding()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		charge, err := dc.payments.Charge(ctx, payments.ChargeRequest{
+			PayerId:     invoice.StudentId.Hex(),
+			Amount:      amount,
+			Description: "Dorm rent " + invoice.Period,
+			Token:       req.Token,
+		})
+		if err != nil {
+			c.JSON(invoiceErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		entry := models.LedgerEntry{
+			StudentId:   invoice.StudentId,
+			Amount:      amount,
+			Provider:    charge.Provider,
+			ProviderRef: charge.Reference,
+			RecordedBy:  uid,
+			CreatedAt:   charge.ChargedAt,
+		}
+		if err := dc.repo.RecordPayment(invoice.Id, &entry); err != nil {
+			if refundErr := dc.payments.Refund(ctx, charge.Reference, amount); refundErr != nil {
+				dc.logger.Printf("could not refund payment %s: %v", charge.Reference, refundErr)
+			}
+			c.JSON(invoiceErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"Payment recorded": entry})
+	}
+}
+
+func (dc *DormController) GetMyLedger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := actor(c)
+		studentId, err := primitive.ObjectIDFromHex(uid)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid student ID"})
+			return
+		}
+		entries, err := dc.repo.GetLedger(studentId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, entries)
+	}
+}
+
+func (dc *DormController) GetStudentLedger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		studentId, err := primitive.ObjectIDFromHex(c.Param("studentId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid student ID"})
+			return
+		}
+		entries, err := dc.repo.GetLedger(studentId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, entries)
+	}
+}
+
+// GetArrears is the admin report of students with overdue rent.
+func (dc *DormController) GetArrears() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		arrears, err := dc.repo.GetArrears(time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, arrears)
+	}
+}
+
+func (dc *DormController) GetMyNotifications() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := actor(c)
+		studentId, err := primitive.ObjectIDFromHex(uid)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid student ID"})
+			return
+		}
+		notifications, err := dc.repo.GetNotifications(studentId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, notifications)
+	}
+}
+
+func (dc *DormController) MarkNotificationRead() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := actor(c)
+		studentId, err := primitive.ObjectIDFromHex(uid)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid student ID"})
+			return
+		}
+		id, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid notification ID"})
+			return
+		}
+		if err := dc.repo.MarkNotificationRead(id, studentId); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+	}
+}
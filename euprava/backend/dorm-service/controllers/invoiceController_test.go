@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"dorm-service/models"
+	"testing"
+	"time"
+)
+
+func TestMoveRentBillsEveryDayOnce(t *testing.T) {
+	stayedSince := time.Date(2026, time.February, 20, 14, 0, 0, 0, time.Local)
+	tests := []struct {
+		name    string
+		movedAt time.Time
+	}{
+		{"mid-month during the day", time.Date(2026, time.March, 14, 15, 30, 0, 0, time.Local)},
+		{"just after midnight", time.Date(2026, time.March, 14, 0, 5, 0, 0, time.Local)},
+		{"first day of the month", time.Date(2026, time.March, 1, 9, 0, 0, 0, time.Local)},
+		{"last day of the month", time.Date(2026, time.March, 31, 22, 0, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			periodStart := periodOf(tt.movedAt)
+			leftAt, arrivedAt := moveDay(tt.movedAt)
+
+			_, oldDays, daysInPeriod := models.ProRate(300, periodStart, stayedSince, leftAt)
+			_, newDays, _ := models.ProRate(450, periodStart, arrivedAt, time.Time{})
+			if oldDays+newDays != daysInPeriod {
+				t.Errorf("old room %d days + new room %d days, want %d days in the month", oldDays, newDays, daysInPeriod)
+			}
+			if newDays == 0 {
+				t.Error("the day of the move is not billed to the new room")
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"bytes"
+	"dorm-service/data"
+	"dorm-service/middleware"
+	"dorm-service/models"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	errInvalidBuildingId = errors.New("invalid building ID")
+	errInvalidStudentId  = errors.New("invalid student ID")
+)
+
+// manages reports whether the user runs every one of the buildings.
+func (dc *DormController) manages(uid string, buildingIds ...primitive.ObjectID) (bool, error) {
+	managerId, err := primitive.ObjectIDFromHex(uid)
+	if err != nil {
+		return false, nil
+	}
+	return dc.repo.ManagesBuildings(managerId, buildingIds)
+}
+
+// scopeError marks the errors of a missing resource so the scope answers 404 and
+// not 500.
+func scopeError(err error) error {
+	for _, missing := range []error{errInvalidBuildingId, errInvalidStudentId, data.ErrSelectionNotFound, data.ErrApplicationNotFound, data.ErrTicketNotFound} {
+		if errors.Is(err, missing) {
+			return middleware.NotFound(err)
+		}
+	}
+	return err
+}
+
+// BuildingScope limits a route to the building named by the url parameter.
+func (dc *DormController) BuildingScope(param string) middleware.Scope {
+	return func(c *gin.Context, uid string) (bool, error) {
+		buildingId, err := primitive.ObjectIDFromHex(c.Param(param))
+		if err != nil {
+			return false, scopeError(errInvalidBuildingId)
+		}
+		return dc.manages(uid, buildingId)
+	}
+}
+
+// BuildingQueryScope limits a listing to the building given in the buildingId query
+// parameter, which a manager has to name.
+func (dc *DormController) BuildingQueryScope() middleware.Scope {
+	return func(c *gin.Context, uid string) (bool, error) {
+		buildingId, err := primitive.ObjectIDFromHex(c.Query("buildingId"))
+		if err != nil {
+			return false, nil
+		}
+		return dc.manages(uid, buildingId)
+	}
+}
+
+// SelectionScope limits a route to a selection, named by the url parameter, whose
+// buildings are all run by the manager.
+func (dc *DormController) SelectionScope(param string) middleware.Scope {
+	return func(c *gin.Context, uid string) (bool, error) {
+		selection, err := dc.repo.GetSelection(c.Param(param))
+		if err != nil {
+			return false, scopeError(err)
+		}
+		return dc.manages(uid, selection.Buildings()...)
+	}
+}
+
+// SelectionQueryScope limits a listing to the selection given in the selectionId
+// query parameter, which a manager has to name.
+func (dc *DormController) SelectionQueryScope() middleware.Scope {
+	return func(c *gin.Context, uid string) (bool, error) {
+		if c.Query("selectionId") == "" {
+			return false, nil
+		}
+		selection, err := dc.repo.GetSelection(c.Query("selectionId"))
+		if err != nil {
+			return false, scopeError(err)
+		}
+		return dc.manages(uid, selection.Buildings()...)
+	}
+}
+
+// ApplicationScope limits a route to applications for selections the manager runs.
+func (dc *DormController) ApplicationScope() middleware.Scope {
+	return func(c *gin.Context, uid string) (bool, error) {
+		app, err := dc.repo.GetApplicationById(c.Param("id"))
+		if err != nil {
+			return false, scopeError(err)
+		}
+		selection, err := dc.repo.GetSelection(app.SelectionId.Hex())
+		if err != nil {
+			return false, scopeError(err)
+		}
+		return dc.manages(uid, selection.Buildings()...)
+	}
+}
+
+// TicketScope limits a route to tickets raised in the manager's buildings.
+func (dc *DormController) TicketScope() middleware.Scope {
+	return func(c *gin.Context, uid string) (bool, error) {
+		ticket, err := dc.repo.GetTicket(c.Param("id"))
+		if err != nil {
+			return false, scopeError(err)
+		}
+		return dc.manages(uid, ticket.BuildingId)
+	}
+}
+
+// scopedBody holds the fields of a request body that name buildings, directly or
+// through a student living in one.
+type scopedBody struct {
+	BuildingId   string               `json:"buildingId"`
+	ToBuildingId string               `json:"toBuildingId"`
+	BuildingIds  []primitive.ObjectID `json:"buildingIds"`
+	StudentId    string               `json:"studentId"`
+}
+
+// BodyScope limits a route to requests whose body only touches the manager's
+// buildings: the buildings it names and the building the named student lives in.
+// The body is put back for the handler to read.
+func (dc *DormController) BodyScope() middleware.Scope {
+	return func(c *gin.Context, uid string) (bool, error) {
+		raw, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			return false, err
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+
+		var body scopedBody
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &body); err != nil {
+				// The handler answers malformed bodies itself.
+				return true, nil
+			}
+		}
+
+		buildings := body.BuildingIds
+		for _, value := range []string{body.BuildingId, body.ToBuildingId} {
+			if value == "" {
+				continue
+			}
+			id, err := primitive.ObjectIDFromHex(value)
+			if err != nil {
+				return false, scopeError(errInvalidBuildingId)
+			}
+			buildings = append(buildings, id)
+		}
+		if body.StudentId != "" {
+			studentId, err := primitive.ObjectIDFromHex(body.StudentId)
+			if err != nil {
+				return false, scopeError(errInvalidStudentId)
+			}
+			building, _, err := dc.repo.FindStudentRoom(studentId)
+			if err == nil {
+				buildings = append(buildings, building.Id)
+			} else if !errors.Is(err, data.ErrStudentNotInRoom) {
+				return false, err
+			}
+		}
+		if len(buildings) == 0 {
+			return true, nil
+		}
+		return dc.manages(uid, buildings...)
+	}
+}
+
+// SetBuildingManagers replaces the managers of a building.
+func (dc *DormController) SetBuildingManagers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		buildingId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errInvalidBuildingId.Error()})
+			return
+		}
+		var req models.BuildingManagersRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := dc.repo.SetBuildingManagers(buildingId, req.ManagerIds); err != nil {
+			if errors.Is(err, data.ErrBuildingNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Building managers updated"})
+	}
+}
+
+// GetManagedBuildings lists the buildings the logged in manager runs.
+func (dc *DormController) GetManagedBuildings() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := actor(c)
+		managerId, err := primitive.ObjectIDFromHex(uid)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+			return
+		}
+		ids, err := dc.repo.ManagedBuildings(managerId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		buildings := []*models.Building{}
+		for _, id := range ids {
+			building, err := dc.repo.GetBuilding(id.Hex())
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+				return
+			}
+			buildings = append(buildings, building)
+		}
+		c.JSON(http.StatusOK, buildings)
+	}
+}
@@ -0,0 +1,318 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"dorm-service/data"
+	"dorm-service/models"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// parcelFlagDays is after how many days an uncollected parcel is flagged, set by DORM_PARCEL_FLAG_DAYS.
+func parcelFlagDays() int {
+	days, err := strconv.Atoi(os.Getenv("DORM_PARCEL_FLAG_DAYS"))
+	if err != nil || days <= 0 {
+		return 7
+	}
+	return days
+}
+
+func parcelErrorCode(err error) int {
+	switch {
+	case errors.Is(err, data.ErrParcelNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, data.ErrParcelNotAtDesk):
+		return http.StatusConflict
+	case errors.Is(err, data.ErrWrongPickupCode):
+		return http.StatusBadRequest
+	case errors.Is(err, data.ErrPickupCodeBlocked):
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
+
+// newPickupCode returns a random six digit code.
+func newPickupCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// hashPickupCode hashes a pickup code together with its parcel id, so the same code
+// on two parcels does not hash the same.
+func hashPickupCode(parcelId primitive.ObjectID, code string) string {
+	sum := sha256.Sum256([]byte(parcelId.Hex() + ":" + code))
+	return hex.EncodeToString(sum[:])
+}
+
+// issuePickupCode sends the student a new pickup code for the parcel and returns its hash.
+func (dc *DormController) issuePickupCode(parcel *models.Parcel, title string) (string, error) {
+	code, err := newPickupCode()
+	if err != nil {
+		return "", err
+	}
+	content := fmt.Sprintf("A parcel is waiting for you at the reception desk. Show the pickup code %s to collect it.", code)
+	if parcel.Carrier != "" {
+		content = fmt.Sprintf("A parcel from %s is waiting for you at the reception desk. Show the pickup code %s to collect it.", parcel.Carrier, code)
+	}
+	if err := dc.repo.Notify(parcel.StudentId, title, content); err != nil {
+		return "", err
+	}
+	return hashPickupCode(parcel.Id, code), nil
+}
+
+// GetRoomOccupants lists the students currently living in a room.
+func (dc *DormController) GetRoomOccupants() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roomNumber, err := strconv.Atoi(c.Param("number"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room number"})
+			return
+		}
+		room, err := dc.repo.GetRoom(roomNumber, c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		occupants := []models.Occupant{}
+		if room.Students != nil {
+			for _, s := range *room.Students {
+				if s != nil {
+					occupants = append(occupants, models.Occupant{StudentId: s.ID, Name: s.FullName()})
+				}
+			}
+		}
+		c.JSON(http.StatusOK, occupants)
+	}
+}
+
+// RegisterParcel logs a parcel at the reception desk for a resident of the given
+// room and sends the resident a one-time pickup code.
+func (dc *DormController) RegisterParcel() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.ParcelRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		buildingId, err := primitive.ObjectIDFromHex(req.BuildingId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid building ID"})
+			return
+		}
+		room, err := dc.repo.GetRoom(req.RoomNumber, req.BuildingId)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+
+		var recipient *models.Student
+		if room.Students != nil {
+			for _, s := range *room.Students {
+				if s == nil {
+					continue
+				}
+				if s.ID.Hex() == req.StudentId || (req.StudentId == "" && room.Occupancy() == 1) {
+					recipient = s
+				}
+			}
+		}
+		if recipient == nil {
+			msg := "the student does not live in this room"
+			if req.StudentId == "" {
+				msg = "pick the resident the parcel is for"
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		registeredBy, _ := actor(c)
+		parcel := models.Parcel{
+			Id:             primitive.NewObjectID(),
+			BuildingId:     buildingId,
+			RoomNumber:     room.Room_Number,
+			StudentId:      recipient.ID,
+			StudentName:    recipient.FullName(),
+			Carrier:        req.Carrier,
+			TrackingNumber: req.TrackingNumber,
+			Description:    req.Description,
+			RegisteredBy:   registeredBy,
+		}
+		parcel.PickupCodeHash, err = dc.issuePickupCode(&parcel, "Parcel at the reception desk")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := dc.repo.InsertParcel(&parcel); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"Parcel registered": parcel})
+	}
+}
+
+// GetParcels lists parcels for the reception desk. Uncollected parcels past the
+// deadline can be listed with ?flagged=true.
+func (dc *DormController) GetParcels() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := bson.M{}
+		if buildingId := c.Query("buildingId"); buildingId != "" {
+			id, err := primitive.ObjectIDFromHex(buildingId)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid building ID"})
+				return
+			}
+			filter["buildingId"] = id
+		}
+		if roomNumber := c.Query("roomNumber"); roomNumber != "" {
+			number, err := strconv.Atoi(roomNumber)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room number"})
+				return
+			}
+			filter["roomNumber"] = number
+		}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+		if flagged := c.Query("flagged"); flagged != "" {
+			filter["flagged"] = flagged == "true"
+		}
+		parcels, err := dc.repo.GetParcels(filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, parcels)
+	}
+}
+
+func (dc *DormController) GetMyParcels() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		studentId, err := studentParam(c, "")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		parcels, err := dc.repo.GetParcels(bson.M{"studentId": studentId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, parcels)
+	}
+}
+
+// HandOverParcel gives a parcel to the student who shows its pickup code.
+func (dc *DormController) HandOverParcel() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.PickupRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		parcel, err := dc.repo.GetParcel(c.Param("id"))
+		if err != nil {
+			c.JSON(parcelErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		handedOverBy, _ := actor(c)
+		if err := dc.repo.HandOverParcel(parcel.Id, hashPickupCode(parcel.Id, req.Code), handedOverBy); err != nil {
+			c.JSON(parcelErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Parcel handed over", "studentName": parcel.StudentName})
+	}
+}
+
+// ReissuePickupCode sends the student a new pickup code, e.g. after the old one was
+// lost or blocked by wrong attempts. Students can only do this for their own parcels.
+func (dc *DormController) ReissuePickupCode() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parcel, err := dc.repo.GetParcel(c.Param("id"))
+		if err != nil {
+			c.JSON(parcelErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		if uid, role := actor(c); role == "STUDENT" && parcel.StudentId.Hex() != uid {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+		if parcel.Status != models.ParcelAtDesk {
+			c.JSON(http.StatusConflict, gin.H{"error": data.ErrParcelNotAtDesk.Error()})
+			return
+		}
+		codeHash, err := dc.issuePickupCode(parcel, "New parcel pickup code")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := dc.repo.ResetPickupCode(parcel.Id, codeHash); err != nil {
+			c.JSON(parcelErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "A new pickup code was sent to the student"})
+	}
+}
+
+// ReturnParcel records that an uncollected parcel was sent back.
+func (dc *DormController) ReturnParcel() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parcel, err := dc.repo.GetParcel(c.Param("id"))
+		if err != nil {
+			c.JSON(parcelErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		returnedBy, _ := actor(c)
+		if err := dc.repo.ReturnParcel(parcel.Id, returnedBy); err != nil {
+			c.JSON(parcelErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		content := "A parcel that was waiting for you at the reception desk was not collected and has been returned to the sender."
+		if err := dc.repo.Notify(parcel.StudentId, "Parcel returned", content); err != nil {
+			dc.logger.Printf("could not notify student %s: %v", parcel.StudentId.Hex(), err)
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Parcel returned"})
+	}
+}
+
+// RunParcelJob flags parcels left at the desk for longer than parcelFlagDays and
+// reminds their students.
+func (dc *DormController) RunParcelJob() {
+	days := parcelFlagDays()
+	flagged, err := dc.repo.FlagUncollectedParcels(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		dc.logger.Printf("flagging uncollected parcels failed: %v", err)
+	}
+	for _, parcel := range flagged {
+		content := fmt.Sprintf("A parcel has been waiting for you at the reception desk for more than %d days. Please collect it.", days)
+		if err := dc.repo.Notify(parcel.StudentId, "Uncollected parcel", content); err != nil {
+			dc.logger.Printf("could not notify student %s: %v", parcel.StudentId.Hex(), err)
+		}
+	}
+	if len(flagged) > 0 {
+		dc.logger.Printf("flagged %d uncollected parcels", len(flagged))
+	}
+}
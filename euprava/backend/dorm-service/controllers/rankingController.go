@@ -0,0 +1,235 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"dorm-service/data"
+	"dorm-service/documents"
+	"dorm-service/models"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func rankingErrorCode(err error) int {
+	if errors.Is(err, data.ErrRankingNotPublished) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// selectionClosed reports whether applications for the selection can no longer be
+// submitted.
+func selectionClosed(selection *models.Selection) bool {
+	return selection.Closed(time.Now())
+}
+
+// rankingPosition returns where a student placed on a ranking, or nil.
+func rankingPosition(ranking *models.Ranking, studentId primitive.ObjectID) *models.RankingPosition {
+	entry := ranking.Entry(studentId)
+	if entry == nil {
+		return nil
+	}
+	return &models.RankingPosition{
+		Position:    entry.Position,
+		Of:          len(ranking.Entries),
+		Code:        entry.Code,
+		Outcome:     entry.Outcome,
+		PublishedAt: ranking.PublishedAt,
+	}
+}
+
+// attachRankings fills in the students' positions on the published ranking lists
+// of their applications' selections.
+func (dc *DormController) attachRankings(apps ...*models.Application) {
+	rankings := map[primitive.ObjectID]*models.Ranking{}
+	for _, app := range apps {
+		ranking, ok := rankings[app.SelectionId]
+		if !ok {
+			ranking, _ = dc.repo.GetRanking(app.SelectionId)
+			rankings[app.SelectionId] = ranking
+		}
+		if ranking != nil {
+			app.Ranking = rankingPosition(ranking, app.StudentId)
+		}
+	}
+}
+
+// PublishRanking publishes the results list of a closed selection. Every applicant
+// who did not withdraw is listed in ranking order under a pseudonymous code with
+// their score and outcome, and is told their position.
+func (dc *DormController) PublishRanking() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		selection, err := dc.repo.GetSelection(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if !selectionClosed(selection) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the ranking list can only be published after the selection closes"})
+			return
+		}
+		apps, err := dc.repo.GetApplicationsBySelection(selection.Id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+
+		var listed []*models.Application
+		var studentIds []primitive.ObjectID
+		for _, app := range apps {
+			if app.Student != nil && app.Status != models.StatusWithdrawn {
+				listed = append(listed, app)
+				studentIds = append(studentIds, app.StudentId)
+			}
+		}
+		severities, err := dc.sanctionSeverities(studentIds)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		rankApplications(listed, severities)
+
+		salt := make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		publishedBy, _ := actor(c)
+		ranking := models.Ranking{
+			SelectionId: selection.Id,
+			Salt:        hex.EncodeToString(salt),
+			Entries:     make([]models.RankingEntry, 0, len(listed)),
+			PublishedBy: publishedBy,
+			PublishedAt: time.Now(),
+		}
+		for i, app := range listed {
+			entry := models.RankingEntry{
+				Position:   i + 1,
+				Code:       ranking.StudentCode(app.StudentId),
+				StudentId:  app.StudentId,
+				GPA:        app.Student.GPA,
+				Year:       app.Student.Year,
+				Sanctioned: severities[app.StudentId] > 0,
+				Outcome:    app.Status,
+			}
+			if app.Status == models.StatusAccepted || app.Status == models.StatusConfirmed {
+				buildingId := app.PlacedBuilding(selection)
+				entry.BuildingId = &buildingId
+			}
+			ranking.Entries = append(ranking.Entries, entry)
+		}
+		if err := dc.repo.SaveRanking(&ranking); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+
+		for _, entry := range ranking.Entries {
+			content := fmt.Sprintf("The ranking list is published. You are #%d of %d under the code %s, outcome: %s.",
+				entry.Position, len(ranking.Entries), entry.Code, entry.Outcome)
+			if err := dc.repo.Notify(entry.StudentId, "Ranking list published", content); err != nil {
+				dc.logger.Printf("could not notify student %s: %v", entry.StudentId.Hex(), err)
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"Ranking published": ranking})
+	}
+}
+
+func (dc *DormController) GetRanking() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		selectionId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid selection ID"})
+			return
+		}
+		ranking, err := dc.repo.GetRanking(selectionId)
+		if err != nil {
+			c.JSON(rankingErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, ranking)
+	}
+}
+
+// ExportRanking downloads the published ranking list as CSV (the default) or, with
+// ?format=pdf, as the official PDF.
+func (dc *DormController) ExportRanking() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		selection, err := dc.repo.GetSelection(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		ranking, err := dc.repo.GetRanking(selection.Id)
+		if err != nil {
+			c.JSON(rankingErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		buildings, err := dc.repo.GetAllBuildings()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		buildingName := func(id *primitive.ObjectID) string {
+			if id == nil {
+				return ""
+			}
+			for _, b := range buildings {
+				if b.Id == *id {
+					return b.Name
+				}
+			}
+			return id.Hex()
+		}
+
+		filename := fmt.Sprintf("ranking-%s-%s", selection.Id.Hex(), ranking.PublishedAt.Format("2006-01-02"))
+		switch c.DefaultQuery("format", "csv") {
+		case "csv":
+			var buf bytes.Buffer
+			w := csv.NewWriter(&buf)
+			w.Write([]string{"position", "code", "gpa", "year", "sanctioned", "outcome", "building"})
+			for _, e := range ranking.Entries {
+				w.Write([]string{
+					strconv.Itoa(e.Position), e.Code, strconv.FormatFloat(e.GPA, 'f', 2, 64),
+					strconv.Itoa(e.Year), strconv.FormatBool(e.Sanctioned), e.Outcome, buildingName(e.BuildingId),
+				})
+			}
+			w.Flush()
+			if err := w.Error(); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			c.Header("Content-Disposition", "attachment; filename="+filename+".csv")
+			c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+		case "pdf":
+			pdf := documents.NewPDF()
+			pdf.Title("Selection results")
+			pdf.Text("Student dormitory, eUprava")
+			pdf.Text(fmt.Sprintf("Selection %s, published on %s.", selection.Period(), ranking.PublishedAt.In(selection.Location()).Format("02.01.2006.")))
+			pdf.Space()
+			for _, e := range ranking.Entries {
+				line := fmt.Sprintf("%d. %s  GPA %.2f, year %d, %s", e.Position, e.Code, e.GPA, e.Year, e.Outcome)
+				if name := buildingName(e.BuildingId); name != "" {
+					line += " - " + name
+				}
+				if e.Sanctioned {
+					line += " (disciplinary sanction)"
+				}
+				pdf.Text(line)
+			}
+			pdf.Space()
+			pdf.Text(fmt.Sprintf("%d applicants ranked. Students are listed under the code shown with their application.", len(ranking.Entries)))
+			c.Header("Content-Disposition", "attachment; filename="+filename+".pdf")
+			c.Data(http.StatusOK, "application/pdf", pdf.Bytes())
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or pdf"})
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package controllers
+
+import (
+	"dorm-service/data"
+	"dorm-service/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func renewalErrorCode(err error) int {
+	switch {
+	case errors.Is(err, data.ErrCampaignNotFound), errors.Is(err, data.ErrRenewalNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, data.ErrRenewalExists):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// renewalEligibility checks whether a resident is in good standing to renew: no
+// overdue rent, no disciplinary sanction within SanctionWindow and still enrolled
+// with at least the campaign's ESBP.
+func (dc *DormController) renewalEligibility(campaign *models.RenewalCampaign, studentId primitive.ObjectID) (*models.RenewalEligibility, *models.AcademicRecord, error) {
+	eligibility := &models.RenewalEligibility{Reasons: []string{}, MinESBP: campaign.MinESBP}
+
+	overdue, err := dc.repo.GetInvoices(bson.M{
+		"studentId": studentId,
+		"status":    bson.M{"$ne": models.InvoicePaid},
+		"dueDate":   bson.M{"$lt": time.Now()},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, invoice := range overdue {
+		eligibility.Outstanding += invoice.Outstanding()
+	}
+	eligibility.Outstanding = models.RoundMoney(eligibility.Outstanding)
+	if len(overdue) > 0 {
+		eligibility.Reasons = append(eligibility.Reasons, fmt.Sprintf("overdue rent of %.2f", eligibility.Outstanding))
+	}
+
+	sanctioned, err := dc.repo.GetSanctions([]primitive.ObjectID{studentId}, time.Now().Add(-models.SanctionWindow))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, incident := range sanctioned {
+		eligibility.Reasons = append(eligibility.Reasons, fmt.Sprintf("disciplinary sanction (%s) on %s",
+			incident.Sanction.Level, incident.Sanction.ImposedAt.Format("02-01-2006")))
+	}
+
+	academic, err := dc.GetAcademicRecord(studentId.Hex())
+	if err != nil && err != ErrNotEnrolled {
+		return nil, nil, err
+	}
+	if academic != nil {
+		eligibility.Enrolled = academic.Enrolled
+		eligibility.ESBP = academic.ESBP
+	}
+	if !eligibility.Enrolled {
+		eligibility.Reasons = append(eligibility.Reasons, "not enrolled")
+	} else if eligibility.ESBP < campaign.MinESBP {
+		eligibility.Reasons = append(eligibility.Reasons, fmt.Sprintf("%d ESBP, at least %d needed", eligibility.ESBP, campaign.MinESBP))
+	}
+
+	eligibility.Eligible = len(eligibility.Reasons) == 0
+	return eligibility, academic, nil
+}
+
+// InsertRenewalCampaign opens a renewal campaign. A campaign that precedes a
+// selection has to close before the selection starts.
+func (dc *DormController) InsertRenewalCampaign() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var campaign models.RenewalCampaign
+		if err := c.ShouldBindJSON(&campaign); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(campaign); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if campaign.SelectionId != nil {
+			selection, err := dc.repo.GetSelection(campaign.SelectionId.Hex())
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			if campaign.ClosesAt.After(selection.StartDate) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "the renewal campaign has to close before the selection starts"})
+				return
+			}
+		}
+
+		campaign.CreatedBy, _ = actor(c)
+		if err := dc.repo.InsertRenewalCampaign(&campaign); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"Renewal campaign created": campaign})
+	}
+}
+
+func (dc *DormController) GetRenewalCampaigns() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		campaigns, err := dc.repo.GetRenewalCampaigns(bson.M{})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, campaigns)
+	}
+}
+
+// GetRenewalEligibility tells a resident whether they can renew in a campaign.
+// Admins can check any student with ?studentId=.
+func (dc *DormController) GetRenewalEligibility() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		campaign, err := dc.repo.GetRenewalCampaign(c.Param("id"))
+		if err != nil {
+			c.JSON(renewalErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		studentId, err := studentParam(c, c.Query("studentId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		eligibility, _, err := dc.renewalEligibility(campaign, studentId)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, eligibility)
+	}
+}
+
+// RenewPlace keeps the resident's current place for the next academic year.
+func (dc *DormController) RenewPlace() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		campaign, err := dc.repo.GetRenewalCampaign(c.Param("id"))
+		if err != nil {
+			c.JSON(renewalErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		if !campaign.IsOpen(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the renewal campaign is not open"})
+			return
+		}
+		studentId, err := studentParam(c, "")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		building, room, err := dc.repo.FindStudentRoom(studentId)
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only current residents can renew their place"})
+			return
+		}
+
+		eligibility, academic, err := dc.renewalEligibility(campaign, studentId)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		if !eligibility.Eligible {
+			c.JSON(http.StatusForbidden, gin.H{"error": "you are not in good standing to renew", "eligibility": eligibility})
+			return
+		}
+
+		renewal := models.Renewal{
+			CampaignId: campaign.Id,
+			StudentId:  studentId,
+			BuildingId: building.Id,
+			RoomNumber: room.Room_Number,
+			Academic:   academic,
+		}
+		for _, s := range *room.Students {
+			if s != nil && s.ID == studentId {
+				renewal.StudentName = s.FullName()
+			}
+		}
+		if err := dc.repo.InsertRenewal(&renewal); err != nil {
+			c.JSON(renewalErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		content := fmt.Sprintf("Your place in room #%d of %s is renewed for the academic year %s.", room.Room_Number, building.Name, campaign.AcademicYear)
+		if err := dc.repo.Notify(studentId, "Place renewed", content); err != nil {
+			dc.logger.Printf("could not notify student %s: %v", studentId.Hex(), err)
+		}
+		c.JSON(http.StatusOK, gin.H{"Place renewed": renewal})
+	}
+}
+
+func (dc *DormController) GetCampaignRenewals() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		campaign, err := dc.repo.GetRenewalCampaign(c.Param("id"))
+		if err != nil {
+			c.JSON(renewalErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		filter := bson.M{"campaignId": campaign.Id}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+		renewals, err := dc.repo.GetRenewals(filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, renewals)
+	}
+}
+
+func (dc *DormController) GetMyRenewals() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		studentId, err := studentParam(c, "")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		renewals, err := dc.repo.GetRenewals(bson.M{"studentId": studentId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, renewals)
+	}
+}
+
+// CancelRenewal gives a renewed place back to the selection. Students can only
+// cancel their own renewal while the campaign is open.
+func (dc *DormController) CancelRenewal() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		renewal, err := dc.repo.GetRenewal(c.Param("id"))
+		if err != nil {
+			c.JSON(renewalErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		if uid, role := actor(c); role == "STUDENT" {
+			if renewal.StudentId.Hex() != uid {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+				return
+			}
+			campaign, err := dc.repo.GetRenewalCampaign(renewal.CampaignId.Hex())
+			if err != nil {
+				c.JSON(renewalErrorCode(err), gin.H{"error": err.Error()})
+				return
+			}
+			if !campaign.IsOpen(time.Now()) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "the renewal campaign is closed"})
+				return
+			}
+		}
+		if err := dc.repo.CancelRenewal(renewal.Id); err != nil {
+			c.JSON(renewalErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Renewal cancelled"})
+	}
+}
@@ -0,0 +1,322 @@
+package controllers
+
+import (
+	"bytes"
+	"dorm-service/models"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxReportMonths bounds the trend and projection reports.
+const maxReportMonths = 36
+
+type roomKey struct {
+	building primitive.ObjectID
+	number   int
+}
+
+// occupancyLayout groups the rooms of the reported buildings into report lines.
+type occupancyLayout struct {
+	groupOf map[roomKey]string
+	lines   map[string]*models.OccupancyRow
+	order   []string
+}
+
+// newOccupancyLayout builds the report lines for the buildings, with their capacity
+// and current occupancy filled in.
+func newOccupancyLayout(buildings []*models.Building, types map[primitive.ObjectID]*models.RoomType, groupBy string) *occupancyLayout {
+	layout := &occupancyLayout{groupOf: map[roomKey]string{}, lines: map[string]*models.OccupancyRow{}}
+	for _, building := range buildings {
+		for _, room := range building.Rooms {
+			key := building.Id.Hex()
+			line := &models.OccupancyRow{BuildingId: building.Id, BuildingName: building.Name}
+			switch groupBy {
+			case models.ReportByFloor:
+				floor := room.Floor
+				line.Floor = &floor
+				key += "/" + strconv.Itoa(floor)
+			case models.ReportByRoomType:
+				line.RoomType = "Unspecified"
+				if roomType, ok := types[room.RoomTypeId]; ok {
+					line.RoomType = roomType.Name
+				}
+				key += "/" + line.RoomType
+			}
+			if existing, ok := layout.lines[key]; ok {
+				line = existing
+			} else {
+				layout.lines[key] = line
+				layout.order = append(layout.order, key)
+			}
+			line.Capacity += room.Capacity
+			line.Occupied += room.Occupancy()
+			layout.groupOf[roomKey{building.Id, room.Room_Number}] = key
+		}
+	}
+	sort.SliceStable(layout.order, func(i, j int) bool {
+		a, b := layout.lines[layout.order[i]], layout.lines[layout.order[j]]
+		if a.BuildingName != b.BuildingName {
+			return a.BuildingName < b.BuildingName
+		}
+		if a.Floor != nil && b.Floor != nil && *a.Floor != *b.Floor {
+			return *a.Floor < *b.Floor
+		}
+		return a.RoomType < b.RoomType
+	})
+	return layout
+}
+
+// month returns a copy of the lines for the given month. The current occupancy is
+// kept when keepOccupied is set and cleared otherwise.
+func (l *occupancyLayout) month(month string, keepOccupied bool) map[string]*models.OccupancyRow {
+	lines := make(map[string]*models.OccupancyRow, len(l.lines))
+	for key, line := range l.lines {
+		copied := *line
+		copied.Month = month
+		if !keepOccupied {
+			copied.Occupied = 0
+		}
+		lines[key] = &copied
+	}
+	return lines
+}
+
+// add appends the lines of one month to the report, in layout order, with a total.
+func (l *occupancyLayout) add(report *models.OccupancyReport, lines map[string]*models.OccupancyRow, month string) {
+	total := &models.OccupancyRow{Month: month, BuildingName: "All buildings"}
+	for _, key := range l.order {
+		line := lines[key]
+		line.Fill()
+		report.Rows = append(report.Rows, line)
+		total.Capacity += line.Capacity
+		total.Occupied += line.Occupied
+		total.PlannedCheckOuts += line.PlannedCheckOuts
+	}
+	total.Fill()
+	report.Totals = append(report.Totals, total)
+}
+
+// reportLayout loads the buildings of the report, all of them or the one given in
+// the buildingId query parameter, and groups their rooms as asked by groupBy.
+func (dc *DormController) reportLayout(c *gin.Context) (*occupancyLayout, *models.OccupancyReport, int, error) {
+	groupBy := c.DefaultQuery("groupBy", models.ReportByBuilding)
+	switch groupBy {
+	case models.ReportByBuilding, models.ReportByFloor, models.ReportByRoomType:
+	default:
+		return nil, nil, http.StatusBadRequest, fmt.Errorf("groupBy must be building, floor or roomType")
+	}
+
+	var buildings []*models.Building
+	if buildingId := c.Query("buildingId"); buildingId != "" {
+		building, err := dc.repo.GetBuilding(buildingId)
+		if err != nil {
+			return nil, nil, http.StatusNotFound, err
+		}
+		buildings = []*models.Building{building}
+	} else {
+		all, err := dc.repo.GetAllBuildings()
+		if err != nil {
+			return nil, nil, http.StatusInternalServerError, err
+		}
+		buildings = all
+	}
+	types, err := dc.repo.GetRoomTypes()
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, err
+	}
+
+	report := &models.OccupancyReport{
+		GeneratedAt: time.Now(),
+		GroupBy:     groupBy,
+		Rows:        []*models.OccupancyRow{},
+		Totals:      []*models.OccupancyRow{},
+	}
+	return newOccupancyLayout(buildings, types.ById(), groupBy), report, http.StatusOK, nil
+}
+
+// reportScope returns the residency filter for the building asked for, if any.
+func reportScope(c *gin.Context) bson.M {
+	filter := bson.M{}
+	if buildingId, err := primitive.ObjectIDFromHex(c.Query("buildingId")); err == nil {
+		filter["buildingId"] = buildingId
+	}
+	return filter
+}
+
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+}
+
+// sendOccupancyReport answers with the report as JSON, or as CSV with ?format=csv.
+func sendOccupancyReport(c *gin.Context, report *models.OccupancyReport, name string) {
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.JSON(http.StatusOK, report)
+	case "csv":
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write([]string{"month", "building", "floor", "roomType", "capacity", "occupied", "free", "occupancyRate", "plannedCheckOuts"})
+		for _, rows := range [][]*models.OccupancyRow{report.Rows, report.Totals} {
+			for _, row := range rows {
+				floor := ""
+				if row.Floor != nil {
+					floor = strconv.Itoa(*row.Floor)
+				}
+				w.Write([]string{
+					row.Month, row.BuildingName, floor, row.RoomType,
+					strconv.Itoa(row.Capacity), strconv.Itoa(row.Occupied), strconv.Itoa(row.Free),
+					strconv.FormatFloat(row.OccupancyRate, 'f', 2, 64), strconv.Itoa(row.PlannedCheckOuts),
+				})
+			}
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		filename := fmt.Sprintf("%s-%s.csv", name, report.GeneratedAt.Format("2006-01-02"))
+		c.Header("Content-Disposition", "attachment; filename="+filename)
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", buf.Bytes())
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or csv"})
+	}
+}
+
+// GetOccupancyReport shows capacity, occupied and free beds right now.
+func (dc *DormController) GetOccupancyReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		layout, report, status, err := dc.reportLayout(c)
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		layout.add(report, layout.month("", true), "")
+		sendOccupancyReport(c, report, "occupancy")
+	}
+}
+
+// GetOccupancyTrend shows how many beds were taken at the end of every month between
+// the from and to query parameters (2006-01), the last twelve months by default.
+// Occupancy is worked out from the residency records against today's capacity.
+func (dc *DormController) GetOccupancyTrend() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := time.Now()
+		to := monthStart(now)
+		if value := c.Query("to"); value != "" {
+			parsed, err := time.ParseInLocation(models.PeriodLayout, value, time.Local)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to month"})
+				return
+			}
+			to = parsed
+		}
+		from := to.AddDate(0, -11, 0)
+		if value := c.Query("from"); value != "" {
+			parsed, err := time.ParseInLocation(models.PeriodLayout, value, time.Local)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from month"})
+				return
+			}
+			from = parsed
+		}
+		if to.Before(from) || to.After(monthStart(now)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the months have to be in order and not in the future"})
+			return
+		}
+		if from.AddDate(0, maxReportMonths, 0).Before(to) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("a trend can cover at most %d months", maxReportMonths)})
+			return
+		}
+
+		layout, report, status, err := dc.reportLayout(c)
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		filter := reportScope(c)
+		filter["from"] = bson.M{"$lt": to.AddDate(0, 1, 0)}
+		filter["$or"] = bson.A{bson.M{"to": nil}, bson.M{"to": bson.M{"$gt": from}}}
+		residencies, err := dc.repo.GetResidencies(filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+
+		for month := from; !month.After(to); month = month.AddDate(0, 1, 0) {
+			at := month.AddDate(0, 1, 0)
+			if at.After(now) {
+				at = now
+			}
+			label := month.Format(models.PeriodLayout)
+			lines := layout.month(label, false)
+			for _, residency := range residencies {
+				if residency.From.After(at) || (residency.To != nil && !residency.To.After(at)) {
+					continue
+				}
+				if key, ok := layout.groupOf[roomKey{residency.BuildingId, residency.RoomNumber}]; ok {
+					lines[key].Occupied++
+				}
+			}
+			layout.add(report, lines, label)
+		}
+		sendOccupancyReport(c, report, "occupancy-trend")
+	}
+}
+
+// GetVacancyProjection shows the beds expected to be free at the end of each of the
+// coming months, given in the months query parameter (6 by default), as residents
+// leave on their planned check-out dates.
+func (dc *DormController) GetVacancyProjection() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		months, err := strconv.Atoi(c.DefaultQuery("months", "6"))
+		if err != nil || months < 1 || months > maxReportMonths {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("months must be between 1 and %d", maxReportMonths)})
+			return
+		}
+
+		layout, report, status, err := dc.reportLayout(c)
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		first := monthStart(time.Now())
+		end := first.AddDate(0, months, 0)
+		filter := reportScope(c)
+		filter["active"] = true
+		filter["plannedTo"] = bson.M{"$lt": end}
+		leaving, err := dc.repo.GetResidencies(filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+
+		for month := first; month.Before(end); month = month.AddDate(0, 1, 0) {
+			next := month.AddDate(0, 1, 0)
+			label := month.Format(models.PeriodLayout)
+			lines := layout.month(label, true)
+			for _, residency := range leaving {
+				key, ok := layout.groupOf[roomKey{residency.BuildingId, residency.RoomNumber}]
+				if !ok || !residency.PlannedTo.Before(next) {
+					continue
+				}
+				lines[key].Occupied--
+				// Check-outs already overdue are counted in the current month.
+				if !residency.PlannedTo.Before(month) || month.Equal(first) {
+					lines[key].PlannedCheckOuts++
+				}
+			}
+			layout.add(report, lines, label)
+		}
+		sendOccupancyReport(c, report, "vacancy-projection")
+	}
+}
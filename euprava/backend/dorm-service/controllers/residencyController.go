@@ -0,0 +1,278 @@
+package controllers
+
+import (
+	"dorm-service/data"
+	"dorm-service/documents"
+	"dorm-service/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func residencyErrorCode(err error) int {
+	switch {
+	case errors.Is(err, data.ErrNotCheckedIn):
+		return http.StatusNotFound
+	case errors.Is(err, data.ErrAlreadyCheckedIn):
+		return http.StatusConflict
+	}
+	return roomErrorCode(err)
+}
+
+// residentStudent finds the student data to put into a room on check-in, taken from
+// the student's most recent application.
+func (dc *DormController) residentStudent(studentId primitive.ObjectID) (*models.Student, error) {
+	apps, err := dc.repo.GetApplicationsByStudent(studentId)
+	if err != nil {
+		return nil, err
+	}
+	var latest *models.Application
+	for _, app := range apps {
+		if app.Student != nil && (latest == nil || app.CreatedAt.After(latest.CreatedAt)) {
+			latest = app
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("%w: student has no application", data.ErrStudentNotInRoom)
+	}
+	return latest.Student, nil
+}
+
+// CheckIn records that a student moved into a room. Students assigned to the room by
+// a selection are already counted as occupants; anyone else takes a free bed. The
+// security deposit has to be held already or is paid with depositToken.
+func (dc *DormController) CheckIn() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.CheckInRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		studentId, err := primitive.ObjectIDFromHex(req.StudentId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid student ID"})
+			return
+		}
+		buildingId, err := primitive.ObjectIDFromHex(req.BuildingId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid building ID"})
+			return
+		}
+
+		student, err := dc.residentStudent(studentId)
+		if err != nil {
+			c.JSON(residencyErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		from := time.Now()
+		if req.Date != nil {
+			from = *req.Date
+		}
+		if req.PlannedTo != nil && !req.PlannedTo.After(from) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the planned check-out has to be after the check-in"})
+			return
+		}
+		checkedInBy, _ := actor(c)
+		deposit, err := dc.requireDeposit(studentId, buildingId, req.RoomNumber, req.DepositToken, checkedInBy)
+		if err != nil {
+			c.JSON(depositErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		residency := models.Residency{
+			StudentId:   studentId,
+			StudentName: student.FullName(),
+			BuildingId:  buildingId,
+			RoomNumber:  req.RoomNumber,
+			From:        from,
+			PlannedTo:   req.PlannedTo,
+			CheckedInBy: checkedInBy,
+		}
+		if err := dc.repo.CheckIn(&residency, student); err != nil {
+			if deposit != nil {
+				dc.returnDeposit(deposit)
+			}
+			c.JSON(residencyErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"Checked in": residency})
+	}
+}
+
+// SetPlannedCheckOut records when a resident is expected to leave, which the
+// vacancy projection counts on.
+func (dc *DormController) SetPlannedCheckOut() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.PlannedCheckOutRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		studentId, err := primitive.ObjectIDFromHex(req.StudentId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid student ID"})
+			return
+		}
+		if req.Date != nil && req.Date.Before(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the planned check-out cannot be in the past"})
+			return
+		}
+
+		residency, err := dc.repo.SetPlannedCheckOut(studentId, req.Date)
+		if err != nil {
+			c.JSON(residencyErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, residency)
+	}
+}
+
+// CheckOut records that a student left the dorm and frees their bed. Rent is only
+// owed up to the day of leaving.
+func (dc *DormController) CheckOut() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.CheckOutRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		studentId, err := primitive.ObjectIDFromHex(req.StudentId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid student ID"})
+			return
+		}
+
+		to := time.Now()
+		if req.Date != nil {
+			to = *req.Date
+		}
+		checkedOutBy, _ := actor(c)
+		residency, err := dc.repo.CheckOut(studentId, to, req.Reason, checkedOutBy)
+		if err != nil {
+			c.JSON(residencyErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		if err := dc.settleRent(studentId, residency.BuildingId, residency.RoomNumber, residency.From, to, checkedOutBy); err != nil {
+			dc.logger.Printf("could not settle rent of student %s after check-out: %v", studentId.Hex(), err)
+		}
+		c.JSON(http.StatusOK, gin.H{"Checked out": residency})
+	}
+}
+
+// GetStudentResidencies returns a student's residency history. Students only see their own.
+func (dc *DormController) GetStudentResidencies() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		studentId, err := studentParam(c, c.Query("studentId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		residencies, err := dc.repo.GetResidencies(bson.M{"studentId": studentId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, residencies)
+	}
+}
+
+// GetRoomResidencies returns everyone who has lived in a room.
+func (dc *DormController) GetRoomResidencies() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		buildingId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid building ID"})
+			return
+		}
+		roomNumber, err := strconv.Atoi(c.Param("number"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room number"})
+			return
+		}
+		residencies, err := dc.repo.GetResidencies(bson.M{"buildingId": buildingId, "roomNumber": roomNumber})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, residencies)
+	}
+}
+
+// GetResidencyConfirmation returns a PDF confirming where the logged in student lives
+// and has lived in the dorm.
+func (dc *DormController) GetResidencyConfirmation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		studentId, err := studentParam(c, c.Query("studentId"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		residencies, err := dc.repo.GetResidencies(bson.M{"studentId": studentId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		if len(residencies) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "student has never lived in the dorm"})
+			return
+		}
+
+		buildings := map[primitive.ObjectID]string{}
+		buildingName := func(id primitive.ObjectID) string {
+			if name, ok := buildings[id]; ok {
+				return name
+			}
+			name := id.Hex()
+			if building, err := dc.repo.GetBuilding(id.Hex()); err == nil {
+				name = building.Name + ", " + building.Address
+			}
+			buildings[id] = name
+			return name
+		}
+
+		now := time.Now()
+		pdf := documents.NewPDF()
+		pdf.Title("Residency confirmation")
+		pdf.Text("Student dormitory, eUprava")
+		pdf.Space()
+		pdf.Text(fmt.Sprintf("This confirms that %s (student id %s) has the following residency record in the student dormitory.",
+			residencies[0].StudentName, studentId.Hex()))
+		pdf.Space()
+		for _, r := range residencies {
+			to := "present"
+			if r.To != nil {
+				to = r.To.Format("02.01.2006.")
+			}
+			pdf.Heading(fmt.Sprintf("%s - %s", r.From.Format("02.01.2006."), to))
+			pdf.Text(fmt.Sprintf("%s, room %d", buildingName(r.BuildingId), r.RoomNumber))
+			if r.Reason != "" {
+				pdf.Text("Left: " + r.Reason)
+			}
+			pdf.Space()
+		}
+		pdf.Space()
+		pdf.Text(fmt.Sprintf("Issued on %s. Confirmation number %s-%d.", now.Format("02.01.2006."), studentId.Hex(), now.Unix()))
+
+		filename := fmt.Sprintf("residency-confirmation-%s.pdf", now.Format("2006-01-02"))
+		c.Header("Content-Disposition", "attachment; filename="+filename)
+		c.Data(http.StatusOK, "application/pdf", pdf.Bytes())
+	}
+}
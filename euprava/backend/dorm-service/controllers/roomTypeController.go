@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"dorm-service/data"
+	"dorm-service/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func roomTypeErrorCode(err error) int {
+	switch {
+	case errors.Is(err, data.ErrRoomTypeNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, data.ErrRoomTypeInUse):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// checkRoomTypes makes sure every given room type exists.
+func (dc *DormController) checkRoomTypes(ids ...primitive.ObjectID) error {
+	for _, id := range ids {
+		if id.IsZero() {
+			continue
+		}
+		if _, err := dc.repo.GetRoomType(id); err != nil {
+			return fmt.Errorf("room type %s: %w", id.Hex(), err)
+		}
+	}
+	return nil
+}
+
+func (dc *DormController) GetRoomTypes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roomTypes, err := dc.repo.GetRoomTypes()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, roomTypes)
+	}
+}
+
+func (dc *DormController) InsertRoomType() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var roomType models.RoomType
+		if err := c.ShouldBindJSON(&roomType); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "parsing failed", "details": err.Error()})
+			return
+		}
+		if err := validate.Struct(roomType); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := dc.repo.InsertRoomType(&roomType); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"Room type created": roomType})
+	}
+}
+
+func (dc *DormController) UpdateRoomType() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room type ID"})
+			return
+		}
+		var roomType models.RoomType
+		if err := c.ShouldBindJSON(&roomType); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "parsing failed", "details": err.Error()})
+			return
+		}
+		if err := validate.Struct(roomType); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := dc.repo.UpdateRoomType(id, &roomType); err != nil {
+			c.JSON(roomTypeErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Room type updated successfully"})
+	}
+}
+
+func (dc *DormController) DeleteRoomType() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid room type ID"})
+			return
+		}
+		if err := dc.repo.DeleteRoomType(id); err != nil {
+			c.JSON(roomTypeErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Room type deleted successfully"})
+	}
+}
+
+// pickRoom chooses a room with a free bed for an applicant. Their preferred room
+// types are tried in order; if none of them has space, the cheapest free room is
+// used so nobody ends up in a price tier they did not ask for while cheaper beds are left.
+func pickRoom(building *models.Building, types map[primitive.ObjectID]*models.RoomType, prefs []primitive.ObjectID, free map[int]int) (*models.Room, int) {
+	for rank, pref := range prefs {
+		var best *models.Room
+		for _, room := range building.Rooms {
+			if room.RoomTypeId != pref || free[room.Room_Number] <= 0 {
+				continue
+			}
+			if best == nil || building.RoomPrice(room, types) < building.RoomPrice(best, types) {
+				best = room
+			}
+		}
+		if best != nil {
+			return best, rank + 1
+		}
+	}
+
+	rooms := make([]*models.Room, 0, len(building.Rooms))
+	for _, room := range building.Rooms {
+		if free[room.Room_Number] > 0 {
+			rooms = append(rooms, room)
+		}
+	}
+	if len(rooms) == 0 {
+		return nil, 0
+	}
+	sort.SliceStable(rooms, func(i, j int) bool {
+		return building.RoomPrice(rooms[i], types) < building.RoomPrice(rooms[j], types)
+	})
+	return rooms[0], 0
+}
+
+// AssignSelection places every confirmed applicant of a selection who has no room yet,
+// in ranking order, taking their room type preferences into account.
+func (dc *DormController) AssignSelection() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		selection, err := dc.repo.GetSelection(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		apps, err := dc.repo.GetApplicationsBySelection(selection.Id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+
+		var toPlace []*models.Application
+		var studentIds []primitive.ObjectID
+		for _, app := range apps {
+			if app.Status == models.StatusConfirmed && app.Placement == nil && app.Student != nil {
+				toPlace = append(toPlace, app)
+				studentIds = append(studentIds, app.StudentId)
+			}
+		}
+		severities, err := dc.sanctionSeverities(studentIds)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		rankApplications(toPlace, severities)
+
+		// Students are placed into rooms of the building they were accepted into.
+		// Students who already live in a room keep it and are reported as skipped.
+		var skipped []*models.Application
+		for _, buildingId := range selection.Buildings() {
+			var inBuilding []*models.Application
+			for _, app := range toPlace {
+				if app.PlacedBuilding(selection) == buildingId {
+					inBuilding = append(inBuilding, app)
+				}
+			}
+			alreadyHoused, err := dc.AssignStudents(inBuilding, buildingId.Hex())
+			skipped = append(skipped, alreadyHoused...)
+			if err != nil {
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "placed": placedOnly(toPlace), "skipped": skipped})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"placed": placedOnly(toPlace), "skipped": skipped})
+	}
+}
+
+// placedOnly returns the applications that received a room.
+func placedOnly(apps []*models.Application) []*models.Application {
+	placed := []*models.Application{}
+	for _, app := range apps {
+		if app.Placement != nil {
+			placed = append(placed, app)
+		}
+	}
+	return placed
+}
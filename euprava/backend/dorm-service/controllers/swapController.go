@@ -0,0 +1,242 @@
+package controllers
+
+import (
+	"dorm-service/data"
+	"dorm-service/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func swapErrorCode(err error) int {
+	switch {
+	case errors.Is(err, data.ErrSwapNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, data.ErrSwapExists), errors.Is(err, data.ErrSwapChanged), errors.Is(err, data.ErrSwapRoomsChanged):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// swapSide describes where a resident lives for a swap proposal.
+func (dc *DormController) swapSide(studentId primitive.ObjectID) (*models.SwapSide, error) {
+	building, room, err := dc.repo.FindStudentRoom(studentId)
+	if err != nil {
+		return nil, err
+	}
+	side := &models.SwapSide{StudentId: studentId, BuildingId: building.Id, RoomNumber: room.Room_Number}
+	for _, s := range *room.Students {
+		if s != nil && s.ID == studentId {
+			side.StudentName = s.FullName()
+		}
+	}
+	return side, nil
+}
+
+func (dc *DormController) notifySwap(studentId primitive.ObjectID, title string, content string) {
+	if err := dc.repo.Notify(studentId, title, content); err != nil {
+		dc.logger.Printf("could not notify student %s: %v", studentId.Hex(), err)
+	}
+}
+
+// ProposeSwap proposes to another resident to swap rooms with them.
+func (dc *DormController) ProposeSwap() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.SwapRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		studentId, err := studentParam(c, "")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		partnerId, err := primitive.ObjectIDFromHex(req.PartnerId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid student ID"})
+			return
+		}
+		requester, err := dc.swapSide(studentId)
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only residents can swap rooms"})
+			return
+		}
+		partner, err := dc.swapSide(partnerId)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "the other student does not live in a dorm room"})
+			return
+		}
+		if requester.BuildingId == partner.BuildingId && requester.RoomNumber == partner.RoomNumber {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "you already live in the same room"})
+			return
+		}
+
+		swap := models.RoomSwap{Requester: *requester, Partner: *partner, Reason: req.Reason}
+		if err := dc.repo.InsertRoomSwap(&swap); err != nil {
+			c.JSON(swapErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		dc.notifySwap(partner.StudentId, "Room swap proposed",
+			fmt.Sprintf("%s from room #%d would like to swap rooms with you.", requester.StudentName, requester.RoomNumber))
+		c.JSON(http.StatusOK, gin.H{"Room swap proposed": swap})
+	}
+}
+
+// RespondToSwap lets the partner accept or decline a proposed swap. Accepted swaps
+// wait for an admin.
+func (dc *DormController) RespondToSwap() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.SwapResponse
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		swap, err := dc.repo.GetRoomSwap(c.Param("id"))
+		if err != nil {
+			c.JSON(swapErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		if uid, _ := actor(c); swap.Partner.StudentId.Hex() != uid {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+
+		status, answer := models.SwapAccepted, "accepted"
+		if !req.Accept {
+			status, answer = models.SwapDeclined, "declined"
+		}
+		if err := dc.repo.SetSwapStatus(swap.Id, models.SwapProposed, status, bson.M{"respondedAt": time.Now()}); err != nil {
+			c.JSON(swapErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		dc.notifySwap(swap.Requester.StudentId, "Room swap "+answer,
+			fmt.Sprintf("%s %s your room swap proposal.", swap.Partner.StudentName, answer))
+		c.JSON(http.StatusOK, gin.H{"message": "Room swap " + answer})
+	}
+}
+
+// CancelSwap withdraws a swap the requester proposed before an admin decides on it.
+func (dc *DormController) CancelSwap() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		swap, err := dc.repo.GetRoomSwap(c.Param("id"))
+		if err != nil {
+			c.JSON(swapErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		if uid, _ := actor(c); swap.Requester.StudentId.Hex() != uid {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+		if swap.Status != models.SwapProposed && swap.Status != models.SwapAccepted {
+			c.JSON(http.StatusConflict, gin.H{"error": "room swap cannot be cancelled"})
+			return
+		}
+		if err := dc.repo.SetSwapStatus(swap.Id, swap.Status, models.SwapCancelled, nil); err != nil {
+			c.JSON(swapErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		dc.notifySwap(swap.Partner.StudentId, "Room swap cancelled",
+			fmt.Sprintf("%s cancelled the room swap.", swap.Requester.StudentName))
+		c.JSON(http.StatusOK, gin.H{"message": "Room swap cancelled"})
+	}
+}
+
+// DecideSwap approves or rejects an accepted swap. Approving it moves both students.
+func (dc *DormController) DecideSwap() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.SwapDecision
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		swap, err := dc.repo.GetRoomSwap(c.Param("id"))
+		if err != nil {
+			c.JSON(swapErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		decidedBy, _ := actor(c)
+		decision := "approved"
+		if req.Approve {
+			stayedSince := map[primitive.ObjectID]time.Time{}
+			for _, side := range []models.SwapSide{swap.Requester, swap.Partner} {
+				stayedSince[side.StudentId] = dc.residencyStart(side.StudentId, side.BuildingId)
+			}
+			err = dc.repo.ExecuteRoomSwap(swap, decidedBy, req.Note)
+			if err == nil {
+				swappedAt := time.Now()
+				for _, pair := range [][2]models.SwapSide{{swap.Requester, swap.Partner}, {swap.Partner, swap.Requester}} {
+					moving, other := pair[0], pair[1]
+					if err := dc.moveRent(moving.StudentId, moving.BuildingId, moving.RoomNumber, other.BuildingId, other.RoomNumber,
+						stayedSince[moving.StudentId], swappedAt, decidedBy); err != nil {
+						dc.logger.Printf("could not settle rent of student %s after a swap: %v", moving.StudentId.Hex(), err)
+					}
+				}
+			}
+		} else {
+			decision = "rejected"
+			err = dc.repo.SetSwapStatus(swap.Id, models.SwapAccepted, models.SwapRejected,
+				bson.M{"decidedBy": decidedBy, "decidedAt": time.Now(), "decisionNote": req.Note})
+		}
+		if err != nil {
+			c.JSON(swapErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		for _, side := range []models.SwapSide{swap.Requester, swap.Partner} {
+			content := fmt.Sprintf("The room swap between %s and %s was %s.", swap.Requester.StudentName, swap.Partner.StudentName, decision)
+			if req.Note != "" {
+				content += " " + req.Note
+			}
+			dc.notifySwap(side.StudentId, "Room swap "+decision, content)
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Room swap " + decision})
+	}
+}
+
+// GetMySwaps lists the swaps the student proposed or was asked for.
+func (dc *DormController) GetMySwaps() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		studentId, err := studentParam(c, "")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		swaps, err := dc.repo.GetRoomSwaps(bson.M{"$or": bson.A{
+			bson.M{"requester.studentId": studentId},
+			bson.M{"partner.studentId": studentId},
+		}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, swaps)
+	}
+}
+
+// GetRoomSwaps lists swaps for admins, e.g. the ones waiting for approval with
+// ?status=Accepted.
+func (dc *DormController) GetRoomSwaps() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := bson.M{}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+		swaps, err := dc.repo.GetRoomSwaps(filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, swaps)
+	}
+}
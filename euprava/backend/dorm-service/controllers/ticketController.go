@@ -0,0 +1,409 @@
+package controllers
+
+import (
+	"dorm-service/data"
+	"dorm-service/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// actorName returns the full name of the logged in user.
+func actorName(c *gin.Context) string {
+	return strings.TrimSpace(c.GetString("first_name") + " " + c.GetString("last_name"))
+}
+
+func ticketErrorCode(err error) int {
+	switch {
+	case errors.Is(err, data.ErrTicketNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, data.ErrInvalidTicketTransition):
+		return http.StatusConflict
+	}
+	return roomErrorCode(err)
+}
+
+// ticketForActor loads a ticket and checks the logged in user may see it: students
+// only see tickets they reported, maintenance staff only tickets assigned to them.
+func (dc *DormController) ticketForActor(c *gin.Context) (*models.Ticket, bool) {
+	ticket, err := dc.repo.GetTicket(c.Param("id"))
+	if err != nil {
+		c.JSON(ticketErrorCode(err), gin.H{"error": err.Error()})
+		return nil, false
+	}
+	uid, role := actor(c)
+	if (role == "STUDENT" && ticket.ReportedBy != uid) || (role == "MAINTENANCE" && ticket.AssignedTo != uid) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return nil, false
+	}
+	return ticket, true
+}
+
+// changeTicketStatus moves a ticket to a new status and lets the reporter know.
+func (dc *DormController) changeTicketStatus(c *gin.Context, ticket *models.Ticket, to string, reason string, set bson.M) error {
+	changedBy, role := actor(c)
+	err := dc.repo.UpdateTicketStatus(ticket.Id, models.StatusChange{
+		From:          ticket.Status,
+		To:            to,
+		ChangedBy:     changedBy,
+		ChangedByRole: role,
+		ChangedAt:     time.Now(),
+		Reason:        reason,
+	}, set)
+	if err != nil {
+		return err
+	}
+
+	if ticket.ReporterRole == "STUDENT" && ticket.ReportedBy != changedBy {
+		if studentId, err := primitive.ObjectIDFromHex(ticket.ReportedBy); err == nil {
+			content := fmt.Sprintf("Your maintenance ticket \"%s\" is now %s.", ticket.Title, to)
+			if reason != "" {
+				content += " " + reason
+			}
+			if err := dc.repo.Notify(studentId, "Maintenance ticket update", content); err != nil {
+				dc.logger.Printf("could not notify student %s: %v", ticket.ReportedBy, err)
+			}
+		}
+	}
+	return nil
+}
+
+// InsertTicket opens a maintenance ticket. Residents report problems in the room
+// they live in; admins name the building and room, or room 0 for shared areas.
+func (dc *DormController) InsertTicket() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.TicketRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		uid, role := actor(c)
+		ticket := models.Ticket{
+			RoomNumber:   req.RoomNumber,
+			Category:     req.Category,
+			Priority:     req.Priority,
+			Title:        req.Title,
+			Description:  req.Description,
+			ReportedBy:   uid,
+			ReporterRole: role,
+		}
+
+		if role == "STUDENT" {
+			studentId, err := primitive.ObjectIDFromHex(uid)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid student ID"})
+				return
+			}
+			building, room, err := dc.repo.FindStudentRoom(studentId)
+			if err != nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": "only residents can report maintenance problems"})
+				return
+			}
+			ticket.BuildingId = building.Id
+			ticket.RoomNumber = room.Room_Number
+		} else {
+			buildingId, err := primitive.ObjectIDFromHex(req.BuildingId)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid building ID"})
+				return
+			}
+			if _, err := dc.repo.GetBuilding(req.BuildingId); err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": data.ErrBuildingNotFound.Error()})
+				return
+			}
+			if req.RoomNumber > 0 {
+				if _, err := dc.repo.GetRoom(req.RoomNumber, req.BuildingId); err != nil {
+					c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+					return
+				}
+			}
+			ticket.BuildingId = buildingId
+		}
+
+		if err := dc.repo.InsertTicket(&ticket); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"Ticket created": ticket})
+	}
+}
+
+// GetTickets lists tickets for admins and maintenance staff. Staff only see the
+// tickets assigned to them.
+func (dc *DormController) GetTickets() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := bson.M{}
+		if buildingId := c.Query("buildingId"); buildingId != "" {
+			id, err := primitive.ObjectIDFromHex(buildingId)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid building ID"})
+				return
+			}
+			filter["buildingId"] = id
+		}
+		for _, field := range []string{"status", "priority", "category", "assignedTo"} {
+			if value := c.Query(field); value != "" {
+				filter[field] = value
+			}
+		}
+		if uid, role := actor(c); role == "MAINTENANCE" {
+			filter["assignedTo"] = uid
+		}
+
+		tickets, err := dc.repo.GetTickets(filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, tickets)
+	}
+}
+
+func (dc *DormController) GetMyTickets() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid, _ := actor(c)
+		tickets, err := dc.repo.GetTickets(bson.M{"reportedBy": uid})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, tickets)
+	}
+}
+
+func (dc *DormController) GetTicket() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ticket, ok := dc.ticketForActor(c)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, ticket)
+	}
+}
+
+// AssignTicket assigns, or reassigns, a ticket to a member of the maintenance staff.
+func (dc *DormController) AssignTicket() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.TicketAssignRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ticket, err := dc.repo.GetTicket(c.Param("id"))
+		if err != nil {
+			c.JSON(ticketErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		set := bson.M{"assignedTo": req.StaffId, "assignedToName": req.StaffName}
+		if ticket.AssignedAt == nil {
+			set["assignedAt"] = time.Now()
+		}
+		reason := req.Reason
+		if reason == "" {
+			reason = "assigned to " + req.StaffName
+		}
+		if err := dc.changeTicketStatus(c, ticket, models.TicketAssigned, reason, set); err != nil {
+			c.JSON(ticketErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Ticket assigned"})
+	}
+}
+
+// UpdateTicketStatus moves a ticket through the workflow. Maintenance staff start
+// and resolve the tickets assigned to them; admins can also reopen tickets.
+func (dc *DormController) UpdateTicketStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.StatusChangeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Status == models.TicketAssigned {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "use the assign endpoint to assign a ticket"})
+			return
+		}
+		ticket, ok := dc.ticketForActor(c)
+		if !ok {
+			return
+		}
+		if _, role := actor(c); role == "MAINTENANCE" && req.Status == models.TicketOpen {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only admins can reopen tickets"})
+			return
+		}
+
+		set := bson.M{}
+		if req.Status == models.TicketResolved {
+			set["resolvedAt"] = time.Now()
+		}
+		if err := dc.changeTicketStatus(c, ticket, req.Status, req.Reason, set); err != nil {
+			c.JSON(ticketErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Ticket status updated"})
+	}
+}
+
+func (dc *DormController) AddTicketComment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var comment models.TicketComment
+		if err := c.ShouldBindJSON(&comment); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(comment); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ticket, ok := dc.ticketForActor(c)
+		if !ok {
+			return
+		}
+
+		comment.AuthorId, comment.AuthorRole = actor(c)
+		comment.AuthorName = actorName(c)
+		if err := dc.repo.AddTicketComment(ticket.Id, &comment); err != nil {
+			c.JSON(ticketErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"Comment added": comment})
+	}
+}
+
+// UploadTicketPhoto attaches a photo, sent in the "photo" form field, to a ticket.
+func (dc *DormController) UploadTicketPhoto() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ticket, ok := dc.ticketForActor(c)
+		if !ok {
+			return
+		}
+		filename, status, err := savePhoto(c, "tickets", ticket.Id.Hex())
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		publicPath := models.TicketPhotoPath(ticket.Id, filename)
+		if err := dc.repo.AddTicketPhoto(ticket.Id, publicPath); err != nil {
+			c.JSON(ticketErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"photo": publicPath})
+	}
+}
+
+// GetTicketPhoto serves a photo of a ticket to those who may see the ticket.
+func (dc *DormController) GetTicketPhoto() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ticket, ok := dc.ticketForActor(c)
+		if !ok {
+			return
+		}
+		servePhoto(c, "tickets", ticket.Photos, c.Param("name"))
+	}
+}
+
+// GetSLAReport reports, per building, how quickly tickets opened between from and
+// to (dd-mm-yyyy, default the last 30 days) were assigned and resolved.
+func (dc *DormController) GetSLAReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := time.Now()
+		to := now
+		from := now.AddDate(0, 0, -30)
+		if value := c.Query("from"); value != "" {
+			parsed, err := time.ParseInLocation("02-01-2006", value, time.Local)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date, expected dd-mm-yyyy"})
+				return
+			}
+			from = parsed
+		}
+		if value := c.Query("to"); value != "" {
+			parsed, err := time.ParseInLocation("02-01-2006", value, time.Local)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date, expected dd-mm-yyyy"})
+				return
+			}
+			to = parsed.AddDate(0, 0, 1)
+		}
+
+		filter := bson.M{"createdAt": bson.M{"$gte": from, "$lt": to}}
+		if buildingId := c.Query("buildingId"); buildingId != "" {
+			id, err := primitive.ObjectIDFromHex(buildingId)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid building ID"})
+				return
+			}
+			filter["buildingId"] = id
+		}
+		tickets, err := dc.repo.GetTickets(filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+
+		reports := map[primitive.ObjectID]*models.BuildingSLAReport{}
+		var order []primitive.ObjectID
+		for _, ticket := range tickets {
+			report, ok := reports[ticket.BuildingId]
+			if !ok {
+				report = &models.BuildingSLAReport{
+					BuildingId:   ticket.BuildingId,
+					BuildingName: ticket.BuildingId.Hex(),
+					From:         from,
+					To:           to,
+					Overall:      &models.SLAStats{},
+					ByPriority:   map[string]*models.SLAStats{},
+					ByCategory:   map[string]*models.SLAStats{},
+				}
+				if building, err := dc.repo.GetBuilding(ticket.BuildingId.Hex()); err == nil {
+					report.BuildingName = building.Name
+				}
+				reports[ticket.BuildingId] = report
+				order = append(order, ticket.BuildingId)
+			}
+			report.Overall.Add(ticket, now)
+			if report.ByPriority[ticket.Priority] == nil {
+				report.ByPriority[ticket.Priority] = &models.SLAStats{}
+			}
+			report.ByPriority[ticket.Priority].Add(ticket, now)
+			if report.ByCategory[ticket.Category] == nil {
+				report.ByCategory[ticket.Category] = &models.SLAStats{}
+			}
+			report.ByCategory[ticket.Category].Add(ticket, now)
+		}
+
+		result := []*models.BuildingSLAReport{}
+		for _, id := range order {
+			report := reports[id]
+			report.Overall.Finish()
+			for _, stats := range report.ByPriority {
+				stats.Finish()
+			}
+			for _, stats := range report.ByCategory {
+				stats.Finish()
+			}
+			result = append(result, report)
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxPhotoSize is the largest photo that can be uploaded.
+const maxPhotoSize = 10 << 20
+
+// maxDocumentSize is the largest supporting document that can be uploaded.
+const maxDocumentSize = 10 << 20
+
+var photoExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+var documentExtensions = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+}
+
+// UploadDir is where uploaded photos are stored, set by UPLOAD_DIR. Photos are only
+// served through the routes of the ticket or inspection they belong to.
+func UploadDir() string {
+	if dir := os.Getenv("UPLOAD_DIR"); dir != "" {
+		return dir
+	}
+	return "./uploads"
+}
+
+// DocumentDir is where supporting documents are stored, set by DOCUMENT_DIR.
+func DocumentDir() string {
+	if dir := os.Getenv("DOCUMENT_DIR"); dir != "" {
+		return dir
+	}
+	return "./application-documents"
+}
+
+// upload is a file that was checked and written to disk.
+type upload struct {
+	header      *multipart.FileHeader
+	filename    string
+	contentType string
+}
+
+// saveUpload stores the file sent in the given form field under dir, provided it is
+// no larger than maxSize and its content is one of the allowed types. On failure it
+// also returns the HTTP status to answer with.
+func saveUpload(c *gin.Context, field string, dir string, prefix string, maxSize int64, allowed map[string]string, allowedText string) (*upload, int, error) {
+	header, err := c.FormFile(field)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("%s is required", field)
+	}
+	if header.Size > maxSize {
+		return nil, http.StatusRequestEntityTooLarge, fmt.Errorf("%s must not be larger than %dMB", field, maxSize>>20)
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("cannot read %s", field)
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	contentType := http.DetectContentType(head[:n])
+	ext, ok := allowed[contentType]
+	if !ok {
+		return nil, http.StatusUnsupportedMediaType, fmt.Errorf("%s must be %s", field, allowedText)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("cannot read %s", field)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("cannot create uploads folder")
+	}
+	filename := fmt.Sprintf("%s_%s%s", prefix, primitive.NewObjectID().Hex(), ext)
+	dst, err := os.Create(filepath.Join(dir, filename))
+	if err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("cannot save %s", field)
+	}
+	defer dst.Close()
+	if _, err := io.Copy(dst, file); err != nil {
+		return nil, http.StatusInternalServerError, fmt.Errorf("cannot write %s", field)
+	}
+	return &upload{header: header, filename: filename, contentType: contentType}, http.StatusOK, nil
+}
+
+// savePhoto stores the image sent in the "photo" form field under UploadDir()/subdir
+// and returns its file name. On failure it also returns the HTTP status to answer with.
+func savePhoto(c *gin.Context, subdir string, prefix string) (string, int, error) {
+	saved, status, err := saveUpload(c, "photo", filepath.Join(UploadDir(), subdir), prefix,
+		maxPhotoSize, photoExtensions, "a JPEG, PNG or WebP image")
+	if err != nil {
+		return "", status, err
+	}
+	return saved.filename, http.StatusOK, nil
+}
+
+// servePhoto sends the photo called name from UploadDir()/subdir, provided it is one
+// of the photos of the ticket or inspection the request is about.
+func servePhoto(c *gin.Context, subdir string, photos []string, name string) {
+	for _, photo := range photos {
+		if path.Base(photo) == name {
+			c.File(filepath.Join(UploadDir(), subdir, name))
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "photo not found"})
+}
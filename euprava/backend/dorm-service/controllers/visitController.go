@@ -0,0 +1,297 @@
+package controllers
+
+import (
+	"dorm-service/data"
+	"dorm-service/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func visitErrorCode(err error) int {
+	switch {
+	case errors.Is(err, data.ErrVisitNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, data.ErrVisitChanged):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// visitForActor loads a visit and checks that a student only touches their own.
+func (dc *DormController) visitForActor(c *gin.Context) (*models.Visit, bool) {
+	visit, err := dc.repo.GetVisit(c.Param("id"))
+	if err != nil {
+		c.JSON(visitErrorCode(err), gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if uid, role := actor(c); role == "STUDENT" && visit.StudentId.Hex() != uid {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return nil, false
+	}
+	return visit, true
+}
+
+// RegisterVisit pre-registers a guest of the resident. Day visits are approved
+// straight away; overnight stays count against the monthly limit of the building
+// and wait for an admin to approve them.
+func (dc *DormController) RegisterVisit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.VisitRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		if err := validate.Struct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		from, to := req.From.In(time.Local), req.To.In(time.Local)
+		if !from.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "visits have to be registered before they start"})
+			return
+		}
+		if to.Sub(from) > models.MaxVisitLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("a visit can last at most %d hours", int(models.MaxVisitLength.Hours()))})
+			return
+		}
+
+		studentId, err := studentParam(c, "")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		building, room, err := dc.repo.FindStudentRoom(studentId)
+		if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only residents can register guests"})
+			return
+		}
+
+		visit := models.Visit{
+			StudentId:     studentId,
+			BuildingId:    building.Id,
+			RoomNumber:    room.Room_Number,
+			GuestName:     req.GuestName,
+			GuestDocument: req.GuestDocument,
+			From:          from,
+			To:            to,
+			Overnight:     models.IsOvernight(from, to),
+			Note:          req.Note,
+			Status:        models.VisitApproved,
+		}
+		if room.Students != nil {
+			for _, s := range *room.Students {
+				if s != nil && s.ID == studentId {
+					visit.StudentName = s.FullName()
+				}
+			}
+		}
+
+		if visit.Overnight {
+			month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.Local)
+			stays, err := dc.repo.CountVisits(bson.M{
+				"studentId": studentId,
+				"overnight": true,
+				"status":    bson.M{"$in": models.OvernightStatuses},
+				"from":      bson.M{"$gte": month, "$lt": month.AddDate(0, 1, 0)},
+			})
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+				return
+			}
+			if limit := building.MaxOvernightStaysPerMonth(); int(stays) >= limit {
+				c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("you can have at most %d overnight guests a month", limit)})
+				return
+			}
+			visit.Status = models.VisitPending
+		}
+
+		if err := dc.repo.InsertVisit(&visit); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"Visit registered": visit})
+	}
+}
+
+func (dc *DormController) GetMyVisits() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		studentId, err := studentParam(c, "")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		visits, err := dc.repo.GetVisits(bson.M{"studentId": studentId})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, visits)
+	}
+}
+
+// GetVisits lists visits for admins, e.g. the overnight stays waiting for approval
+// with ?status=Pending.
+func (dc *DormController) GetVisits() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := bson.M{}
+		if buildingId := c.Query("buildingId"); buildingId != "" {
+			id, err := primitive.ObjectIDFromHex(buildingId)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid building ID"})
+				return
+			}
+			filter["buildingId"] = id
+		}
+		if studentId := c.Query("studentId"); studentId != "" {
+			id, err := primitive.ObjectIDFromHex(studentId)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid student ID"})
+				return
+			}
+			filter["studentId"] = id
+		}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+		if overnight := c.Query("overnight"); overnight != "" {
+			filter["overnight"] = overnight == "true"
+		}
+		visits, err := dc.repo.GetVisits(filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, visits)
+	}
+}
+
+// GetExpectedGuests is the reception's list of approved guests of a building for
+// a day (?date=dd-mm-yyyy, today by default).
+func (dc *DormController) GetExpectedGuests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		buildingId, err := primitive.ObjectIDFromHex(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid building ID"})
+			return
+		}
+		now := time.Now()
+		day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+		if value := c.Query("date"); value != "" {
+			day, err = time.ParseInLocation("02-01-2006", value, time.Local)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date, expected dd-mm-yyyy"})
+				return
+			}
+		}
+
+		visits, err := dc.repo.GetVisits(bson.M{
+			"buildingId": buildingId,
+			"status":     bson.M{"$in": []string{models.VisitApproved, models.VisitArrived}},
+			"from":       bson.M{"$lt": day.AddDate(0, 0, 1)},
+			"to":         bson.M{"$gt": day},
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"database exception": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, visits)
+	}
+}
+
+// DecideVisit approves or rejects an overnight stay and tells the resident.
+func (dc *DormController) DecideVisit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.VisitDecision
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		visit, err := dc.repo.GetVisit(c.Param("id"))
+		if err != nil {
+			c.JSON(visitErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		status, decision := models.VisitApproved, "approved"
+		if !req.Approve {
+			status, decision = models.VisitRejected, "rejected"
+		}
+		decidedBy, _ := actor(c)
+		set := bson.M{"decidedBy": decidedBy, "decidedAt": time.Now(), "decisionNote": req.Note}
+		if err := dc.repo.SetVisitStatus(visit.Id, models.VisitPending, status, set); err != nil {
+			c.JSON(visitErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		content := fmt.Sprintf("The overnight stay of %s on %s was %s.", visit.GuestName, visit.From.Format("02-01-2006"), decision)
+		if req.Note != "" {
+			content += " " + req.Note
+		}
+		if err := dc.repo.Notify(visit.StudentId, "Overnight stay "+decision, content); err != nil {
+			dc.logger.Printf("could not notify student %s: %v", visit.StudentId.Hex(), err)
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Visit " + status})
+	}
+}
+
+// CancelVisit cancels a visit that has not started yet.
+func (dc *DormController) CancelVisit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		visit, ok := dc.visitForActor(c)
+		if !ok {
+			return
+		}
+		if visit.Status != models.VisitPending && visit.Status != models.VisitApproved {
+			c.JSON(http.StatusConflict, gin.H{"error": "visit cannot be cancelled"})
+			return
+		}
+		if err := dc.repo.SetVisitStatus(visit.Id, visit.Status, models.VisitCancelled, nil); err != nil {
+			c.JSON(visitErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Visit cancelled"})
+	}
+}
+
+// GuestArrived records at the reception that an expected guest came in.
+func (dc *DormController) GuestArrived() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		visit, err := dc.repo.GetVisit(c.Param("id"))
+		if err != nil {
+			c.JSON(visitErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		now := time.Now()
+		if now.After(visit.To) {
+			c.JSON(http.StatusConflict, gin.H{"error": "the visit is over"})
+			return
+		}
+		if err := dc.repo.SetVisitStatus(visit.Id, models.VisitApproved, models.VisitArrived, bson.M{"arrivedAt": now}); err != nil {
+			c.JSON(visitErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Guest arrived"})
+	}
+}
+
+// GuestLeft records at the reception that a guest left the building.
+func (dc *DormController) GuestLeft() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		visit, err := dc.repo.GetVisit(c.Param("id"))
+		if err != nil {
+			c.JSON(visitErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		if err := dc.repo.SetVisitStatus(visit.Id, models.VisitArrived, models.VisitLeft, bson.M{"leftAt": time.Now()}); err != nil {
+			c.JSON(visitErrorCode(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Guest left"})
+	}
+}
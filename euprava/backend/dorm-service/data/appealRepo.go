@@ -0,0 +1,129 @@
+package data
+
+import (
+	"context"
+	"dorm-service/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrDuplicateAppeal = errors.New("an appeal has already been filed for this application")
+
+// EnsureAppealIndexes makes sure a student can file only one appeal per application.
+func (dr *DormRepo) EnsureAppealIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	appealsCollection := OpenCollection(dr.cli, "appeals")
+	_, err := appealsCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "applicationId", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("uniq_application"),
+		},
+		{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "filedAt", Value: 1}},
+			Options: options.Index().SetName("status_filed"),
+		},
+	})
+	return err
+}
+
+func (dr *DormRepo) InsertAppeal(appeal *models.Appeal) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	appealsCollection := OpenCollection(dr.cli, "appeals")
+
+	appeal.Id = primitive.NewObjectID()
+	appeal.Status = models.AppealPending
+	appeal.FiledAt = time.Now()
+
+	_, err := appealsCollection.InsertOne(ctx, appeal)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDuplicateAppeal
+		}
+		return fmt.Errorf("error inserting appeal: %v", err)
+	}
+	return nil
+}
+
+func (dr *DormRepo) GetAppeal(id string) (*models.Appeal, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	appealObjectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid appeal ID: %v", err)
+	}
+
+	appealsCollection := OpenCollection(dr.cli, "appeals")
+
+	var appeal models.Appeal
+	err = appealsCollection.FindOne(ctx, bson.M{"_id": appealObjectId}).Decode(&appeal)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("no appeal found with id: %s", id)
+		}
+		return nil, err
+	}
+	return &appeal, nil
+}
+
+// GetAppeals returns all appeals, optionally only those in the given status, oldest first.
+func (dr *DormRepo) GetAppeals(status string) ([]*models.Appeal, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	appealsCollection := OpenCollection(dr.cli, "appeals")
+
+	filter := bson.M{}
+	if status != "" {
+		filter["status"] = status
+	}
+
+	appeals := []*models.Appeal{}
+	cursor, err := appealsCollection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "filedAt", Value: 1}}))
+	if err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	if err = cursor.All(ctx, &appeals); err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	return appeals, nil
+}
+
+// ResolveAppeal closes a pending appeal. It fails if the appeal has already been resolved.
+func (dr *DormRepo) ResolveAppeal(appealId primitive.ObjectID, status string, resolvedBy string, resolution string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	appealsCollection := OpenCollection(dr.cli, "appeals")
+
+	now := time.Now()
+	result, err := appealsCollection.UpdateOne(
+		ctx,
+		bson.M{"_id": appealId, "status": models.AppealPending},
+		bson.M{"$set": bson.M{
+			"status":     status,
+			"resolvedBy": resolvedBy,
+			"resolvedAt": now,
+			"resolution": resolution,
+		}},
+	)
+	if err != nil {
+		return fmt.Errorf("error resolving appeal: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("appeal %s is not pending", appealId.Hex())
+	}
+	return nil
+}
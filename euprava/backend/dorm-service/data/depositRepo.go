@@ -0,0 +1,326 @@
+package data
+
+import (
+	"context"
+	"dorm-service/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrDepositExists       = errors.New("student already has a deposit held")
+	ErrDepositNotFound     = errors.New("no deposit held for this student")
+	ErrInspectionNotFound  = errors.New("inspection not found")
+	ErrInspectionItem      = errors.New("inspection has no such checklist item")
+	ErrSettlementNotFound  = errors.New("settlement not found")
+	ErrDepositAlreadyTaken = errors.New("deposit is no longer held")
+	ErrDepositRequired     = errors.New("a security deposit has to be paid before check-in")
+)
+
+// EnsureDepositIndexes makes sure a student has at most one deposit held at a time
+// and creates the lookup indexes for inspections and settlements.
+func (dr *DormRepo) EnsureDepositIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	_, err := OpenCollection(dr.cli, "deposits").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "studentId", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("uniq_held_deposit").
+			SetPartialFilterExpression(bson.M{"status": models.DepositHeld}),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = OpenCollection(dr.cli, "inspections").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "studentId", Value: 1}, {Key: "type", Value: 1}, {Key: "inspectedAt", Value: -1}},
+		Options: options.Index().SetName("student_type_inspected"),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = OpenCollection(dr.cli, "settlements").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "depositId", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("uniq_deposit"),
+	})
+	return err
+}
+
+func (dr *DormRepo) InsertDeposit(deposit *models.Deposit) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	deposit.Id = primitive.NewObjectID()
+	deposit.Status = models.DepositHeld
+	_, err := OpenCollection(dr.cli, "deposits").InsertOne(ctx, deposit)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrDepositExists
+		}
+		return fmt.Errorf("error inserting deposit: %v", err)
+	}
+	return nil
+}
+
+// DeleteDeposit removes a held deposit whose payment was refunded, e.g. because the
+// check-in it was taken for failed.
+func (dr *DormRepo) DeleteDeposit(depositId primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	result, err := OpenCollection(dr.cli, "deposits").DeleteOne(ctx, bson.M{"_id": depositId, "status": models.DepositHeld})
+	if err != nil {
+		return fmt.Errorf("error deleting deposit: %v", err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrDepositAlreadyTaken
+	}
+	return nil
+}
+
+// GetHeldDeposit returns the deposit currently held for a student.
+func (dr *DormRepo) GetHeldDeposit(studentId primitive.ObjectID) (*models.Deposit, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	var deposit models.Deposit
+	err := OpenCollection(dr.cli, "deposits").
+		FindOne(ctx, bson.M{"studentId": studentId, "status": models.DepositHeld}).
+		Decode(&deposit)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrDepositNotFound
+		}
+		return nil, err
+	}
+	return &deposit, nil
+}
+
+func (dr *DormRepo) GetDeposits(filter bson.M) ([]*models.Deposit, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	deposits := []*models.Deposit{}
+	opts := options.Find().SetSort(bson.D{{Key: "paidAt", Value: -1}})
+	cursor, err := OpenCollection(dr.cli, "deposits").Find(ctx, filter, opts)
+	if err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	if err = cursor.All(ctx, &deposits); err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	return deposits, nil
+}
+
+// SetDepositStatus moves a deposit from one status to another. It fails with
+// ErrDepositAlreadyTaken if the deposit is not in the expected status, which
+// keeps a deposit from being settled twice.
+func (dr *DormRepo) SetDepositStatus(depositId primitive.ObjectID, from string, to string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"status": to}}
+	if to == models.DepositSettled {
+		update["$set"].(bson.M)["settledAt"] = time.Now()
+	} else {
+		update["$unset"] = bson.M{"settledAt": ""}
+	}
+
+	result, err := OpenCollection(dr.cli, "deposits").UpdateOne(ctx, bson.M{"_id": depositId, "status": from}, update)
+	if err != nil {
+		return fmt.Errorf("error updating deposit: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrDepositAlreadyTaken
+	}
+	return nil
+}
+
+func (dr *DormRepo) InsertInspection(inspection *models.Inspection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	inspection.Id = primitive.NewObjectID()
+	inspection.InspectedAt = time.Now()
+	for i := range inspection.Items {
+		if inspection.Items[i].Photos == nil {
+			inspection.Items[i].Photos = []string{}
+		}
+	}
+	_, err := OpenCollection(dr.cli, "inspections").InsertOne(ctx, inspection)
+	if err != nil {
+		return fmt.Errorf("error inserting inspection: %v", err)
+	}
+	return nil
+}
+
+func (dr *DormRepo) GetInspection(id string) (*models.Inspection, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	inspectionId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid inspection ID", ErrInspectionNotFound)
+	}
+
+	var inspection models.Inspection
+	err = OpenCollection(dr.cli, "inspections").FindOne(ctx, bson.M{"_id": inspectionId}).Decode(&inspection)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrInspectionNotFound
+		}
+		return nil, err
+	}
+	return &inspection, nil
+}
+
+func (dr *DormRepo) GetInspections(filter bson.M) ([]*models.Inspection, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	inspections := []*models.Inspection{}
+	opts := options.Find().SetSort(bson.D{{Key: "inspectedAt", Value: -1}})
+	cursor, err := OpenCollection(dr.cli, "inspections").Find(ctx, filter, opts)
+	if err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	if err = cursor.All(ctx, &inspections); err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	return inspections, nil
+}
+
+// GetLatestInspection returns the most recent inspection matching the filter.
+func (dr *DormRepo) GetLatestInspection(filter bson.M) (*models.Inspection, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	opts := options.FindOne().SetSort(bson.D{{Key: "inspectedAt", Value: -1}})
+
+	var inspection models.Inspection
+	err := OpenCollection(dr.cli, "inspections").FindOne(ctx, filter, opts).Decode(&inspection)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrInspectionNotFound
+		}
+		return nil, err
+	}
+	return &inspection, nil
+}
+
+// AddInspectionPhoto attaches the path of an uploaded photo to a checklist item.
+func (dr *DormRepo) AddInspectionPhoto(inspectionId primitive.ObjectID, item string, path string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	result, err := OpenCollection(dr.cli, "inspections").UpdateOne(
+		ctx,
+		bson.M{"_id": inspectionId, "items.name": item},
+		bson.M{"$push": bson.M{"items.$.photos": path}},
+	)
+	if err != nil {
+		return fmt.Errorf("error adding photo: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrInspectionItem
+	}
+	return nil
+}
+
+func (dr *DormRepo) InsertSettlement(settlement *models.Settlement) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	settlement.Id = primitive.NewObjectID()
+	_, err := OpenCollection(dr.cli, "settlements").InsertOne(ctx, settlement)
+	if err != nil {
+		return fmt.Errorf("error inserting settlement: %v", err)
+	}
+	return nil
+}
+
+// CompleteSettlement marks a pending settlement as completed once its refund went out.
+func (dr *DormRepo) CompleteSettlement(settlementId primitive.ObjectID, refundRef string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	set := bson.M{"status": models.SettlementCompleted}
+	if refundRef != "" {
+		set["refundRef"] = refundRef
+	}
+	result, err := OpenCollection(dr.cli, "settlements").UpdateOne(
+		ctx,
+		bson.M{"_id": settlementId, "status": models.SettlementPending},
+		bson.M{"$set": set},
+	)
+	if err != nil {
+		return fmt.Errorf("error completing settlement: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrSettlementNotFound
+	}
+	return nil
+}
+
+// DeletePendingSettlement removes a settlement whose refund failed, so the deposit
+// can be settled again.
+func (dr *DormRepo) DeletePendingSettlement(settlementId primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	result, err := OpenCollection(dr.cli, "settlements").DeleteOne(ctx, bson.M{"_id": settlementId, "status": models.SettlementPending})
+	if err != nil {
+		return fmt.Errorf("error deleting settlement: %v", err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrSettlementNotFound
+	}
+	return nil
+}
+
+func (dr *DormRepo) GetSettlements(filter bson.M) ([]*models.Settlement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	settlements := []*models.Settlement{}
+	opts := options.Find().SetSort(bson.D{{Key: "settledAt", Value: -1}})
+	cursor, err := OpenCollection(dr.cli, "settlements").Find(ctx, filter, opts)
+	if err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	if err = cursor.All(ctx, &settlements); err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	return settlements, nil
+}
+
+func (dr *DormRepo) GetSettlement(id string) (*models.Settlement, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	settlementId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid settlement ID", ErrSettlementNotFound)
+	}
+
+	var settlement models.Settlement
+	err = OpenCollection(dr.cli, "settlements").FindOne(ctx, bson.M{"_id": settlementId}).Decode(&settlement)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrSettlementNotFound
+		}
+		return nil, err
+	}
+	return &settlement, nil
+}
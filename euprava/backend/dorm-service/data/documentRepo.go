@@ -0,0 +1,95 @@
+package data
+
+import (
+	"context"
+	"dorm-service/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrDocumentNotFound = errors.New("document not found")
+	ErrDocumentsClosed  = errors.New("documents can only be changed while the application is pending or under review")
+	ErrDocumentReviewed = errors.New("document has already been reviewed")
+)
+
+// documentStatuses are the application statuses in which documents may be attached
+// or removed.
+var documentStatuses = bson.A{models.StatusPending, models.StatusUnderReview}
+
+// AddApplicationDocument attaches a document to an application that is still
+// pending or under review.
+func (dr *DormRepo) AddApplicationDocument(appId primitive.ObjectID, doc *models.SupportingDocument) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	doc.Id = primitive.NewObjectID()
+	doc.Status = models.DocumentPending
+	doc.UploadedAt = time.Now()
+	result, err := OpenCollection(dr.cli, "applications").UpdateOne(
+		ctx,
+		bson.M{"_id": appId, "status": bson.M{"$in": documentStatuses}},
+		bson.M{"$push": bson.M{"documents": doc}, "$set": bson.M{"updatedAt": doc.UploadedAt}},
+	)
+	if err != nil {
+		return fmt.Errorf("error adding document: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrDocumentsClosed
+	}
+	return nil
+}
+
+// RemoveApplicationDocument removes a document that has not been reviewed yet.
+func (dr *DormRepo) RemoveApplicationDocument(appId primitive.ObjectID, docId primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	result, err := OpenCollection(dr.cli, "applications").UpdateOne(
+		ctx,
+		bson.M{
+			"_id":       appId,
+			"status":    bson.M{"$in": documentStatuses},
+			"documents": bson.M{"$elemMatch": bson.M{"_id": docId, "status": models.DocumentPending}},
+		},
+		bson.M{"$pull": bson.M{"documents": bson.M{"_id": docId}}, "$set": bson.M{"updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return fmt.Errorf("error removing document: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrDocumentReviewed
+	}
+	return nil
+}
+
+// ReviewApplicationDocument records a reviewer's decision on a document. A document
+// can be reviewed again, for example when it was rejected by mistake.
+func (dr *DormRepo) ReviewApplicationDocument(appId primitive.ObjectID, docId primitive.ObjectID, status string, reviewedBy string, note string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	result, err := OpenCollection(dr.cli, "applications").UpdateOne(
+		ctx,
+		bson.M{"_id": appId, "documents._id": docId},
+		bson.M{"$set": bson.M{
+			"documents.$.status":     status,
+			"documents.$.reviewedBy": reviewedBy,
+			"documents.$.reviewedAt": now,
+			"documents.$.note":       note,
+			"updatedAt":              now,
+		}},
+	)
+	if err != nil {
+		return fmt.Errorf("error reviewing document: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrDocumentNotFound
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package data
 import (
 	"context"
 	"dorm-service/models"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -16,6 +17,18 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
+var (
+	ErrDuplicateApplication = errors.New("student has already applied to this selection")
+	ErrInvalidTransition    = errors.New("invalid application status transition")
+	ErrSelectionOverlap     = errors.New("the dates you selected overlap with an existing selection")
+	ErrBuildingNotFound     = errors.New("building not found")
+	ErrBuildingOccupied     = errors.New("building still has residents")
+	ErrRoomNotFound         = errors.New("room not found")
+	ErrRoomConflict         = errors.New("room conflict")
+	ErrSelectionNotFound    = errors.New("selection not found")
+	ErrApplicationNotFound  = errors.New("application not found")
+)
+
 type DormRepo struct {
 	cli    *mongo.Client
 	logger *log.Logger
@@ -51,6 +64,21 @@ func NewDormRepo(ctx context.Context, logger *log.Logger) (*DormRepo, error) {
 	}, nil
 }
 
+// inTransaction runs fn in a transaction, retrying it on transient errors. Mongo runs
+// as a single-node replica set (see docker-compose.yml) so that transactions work.
+func (dr *DormRepo) inTransaction(ctx context.Context, fn func(sc mongo.SessionContext) error) error {
+	session, err := dr.cli.StartSession()
+	if err != nil {
+		return fmt.Errorf("error starting session: %v", err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
 // Disconnect from database
 func (dr *DormRepo) DisconnectMongo(ctx context.Context) error {
 	err := dr.cli.Disconnect(ctx)
@@ -90,27 +118,27 @@ func OpenCollection(client *mongo.Client, collectionName string) *mongo.Collecti
 }
 
 func (dr *DormRepo) GetApplication(studentid string, selectionid string) (*models.Application, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
 
-	var app models.Application
-	selCollection := OpenCollection(dr.cli, "selections")
+	appsCollection := OpenCollection(dr.cli, "applications")
 	studentObjectId, err := primitive.ObjectIDFromHex(studentid)
-	selectionObjectid, err := primitive.ObjectIDFromHex(selectionid)
-
 	if err != nil {
-		return nil, fmt.Errorf("invalid ID: %v", err)
+		return nil, fmt.Errorf("invalid student ID: %v", err)
 	}
-
-	var selection models.Selection
-	filter := bson.M{"_id": selectionObjectid}
-	err = selCollection.FindOne(context.Background(), filter).Decode(&selection)
+	selectionObjectId, err := primitive.ObjectIDFromHex(selectionid)
 	if err != nil {
-		return nil, fmt.Errorf("selection with ID %s not found", selectionid)
+		return nil, fmt.Errorf("invalid selection ID: %v", err)
 	}
 
-	for _, app := range selection.Applications {
-		if app.Student.ID == studentObjectId {
-			return app, nil
+	var app models.Application
+	filter := bson.M{"studentId": studentObjectId, "selectionId": selectionObjectId}
+	err = appsCollection.FindOne(ctx, filter).Decode(&app)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("no application found for selection %s", selectionid)
 		}
+		return nil, err
 	}
 
 	return &app, nil
@@ -121,10 +149,16 @@ func (dr *DormRepo) GetSelection(selectionID string) (*models.Selection, error)
 	var selection models.Selection
 	selCollection := OpenCollection(dr.cli, "selections")
 	objectId, err := primitive.ObjectIDFromHex(selectionID)
+	if err != nil {
+		return nil, fmt.Errorf("%w for id: %s", ErrSelectionNotFound, selectionID)
+	}
 
 	err = selCollection.FindOne(context.Background(), bson.M{"_id": objectId}).Decode(&selection)
 	if err != nil {
-		return nil, fmt.Errorf("no selections not found for id: %s", selectionID)
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("%w for id: %s", ErrSelectionNotFound, selectionID)
+		}
+		return nil, err
 	}
 
 	return &selection, nil
@@ -157,10 +191,13 @@ func (dr *DormRepo) UpdateSelection(selectionID string, selection *models.Select
 
 	updateData := bson.M{
 		"$set": bson.M{
-			"startdate":    selection.StartDate,
-			"enddate":      selection.EndDate,
-			"buildingId":   selection.BuildingId,
-			"applications": selection.Applications,
+			"startDate":         selection.StartDate,
+			"endDate":           selection.EndDate,
+			"timeZone":          selection.TimeZone,
+			"buildingId":        selection.BuildingId,
+			"buildingIds":       selection.BuildingIds,
+			"appealWindowDays":  selection.AppealWindowDays,
+			"requiredDocuments": selection.RequiredDocuments,
 		},
 	}
 
@@ -198,164 +235,282 @@ func (dr *DormRepo) DeleteSelection(selectionID string) error {
 	return nil
 }
 
-func (dr *DormRepo) CheckSelectionOverlap(selection models.Selection, isUpdate bool) error {
-	selectionCollection := OpenCollection(dr.cli, "selections")
+// EnsureSelectionIndexes creates the indexes the selection overlap check runs on.
+func (dr *DormRepo) EnsureSelectionIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
 
-	currentStartDate, err := time.Parse("02-01-2006", selection.StartDate)
-	if err != nil {
-		return fmt.Errorf("invalid start date format: %v", err)
-	}
-	currentEndDate, err := time.Parse("02-01-2006", selection.EndDate)
-	if err != nil {
-		return fmt.Errorf("invalid end date format: %v", err)
-	}
+	_, err := OpenCollection(dr.cli, "selections").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "buildingId", Value: 1}, {Key: "startDate", Value: 1}, {Key: "endDate", Value: 1}},
+			Options: options.Index().SetName("building_period"),
+		},
+		{
+			Keys:    bson.D{{Key: "buildingIds", Value: 1}, {Key: "startDate", Value: 1}, {Key: "endDate", Value: 1}},
+			Options: options.Index().SetName("buildings_period"),
+		},
+	})
+	return err
+}
 
-	now := time.Now()
+// CheckSelectionOverlap fails with ErrSelectionOverlap if another selection for one
+// of the same buildings runs at the same time. Windows that only touch, one ending
+// when the other starts, do not overlap.
+func (dr *DormRepo) CheckSelectionOverlap(selection models.Selection, isUpdate bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
 
-	filter := bson.M{
-		"startdate":  bson.M{"$gte": now.Format("02-01-2006")},
-		"buildingId": selection.BuildingId,
+	buildings := selection.Buildings()
+	period := func(key string) bson.M {
+		return bson.M{
+			key:         bson.M{"$in": buildings},
+			"startDate": bson.M{"$lt": selection.EndDate},
+			"endDate":   bson.M{"$gt": selection.StartDate},
+		}
 	}
+	filter := bson.M{"$or": bson.A{period("buildingId"), period("buildingIds")}}
 	if isUpdate {
-		filter = bson.M{
-			"startdate":  bson.M{"$gte": now.Format("02-01-2006")},
-			"buildingId": selection.BuildingId,
-			"_id":        bson.M{"$ne": selection.Id},
-		}
+		filter["_id"] = bson.M{"$ne": selection.Id}
+	}
+
+	var other models.Selection
+	err := OpenCollection(dr.cli, "selections").FindOne(ctx, filter).Decode(&other)
+	if err == mongo.ErrNoDocuments {
+		return nil
 	}
-	cursor, err := selectionCollection.Find(context.Background(), filter)
 	if err != nil {
 		return fmt.Errorf("error querying selections: %v", err)
 	}
-	defer cursor.Close(context.Background())
+	return fmt.Errorf("%w: %s", ErrSelectionOverlap, other.Period())
+}
 
-	for cursor.Next(context.Background()) {
-		var otherSelection models.Selection
-		if err := cursor.Decode(&otherSelection); err != nil {
-			return fmt.Errorf("error decoding selection: %v", err)
-		}
+// EnsureApplicationIndexes creates the indexes the applications collection relies on.
+// The unique (studentId, selectionId) index is what prevents a student from applying twice.
+func (dr *DormRepo) EnsureApplicationIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
 
-		otherStartDate, err := time.Parse("02-01-2006", otherSelection.StartDate)
-		if err != nil {
-			return fmt.Errorf("invalid start date format in other selection: %v", err)
-		}
-		otherEndDate, err := time.Parse("02-01-2006", otherSelection.EndDate)
-		if err != nil {
-			return fmt.Errorf("invalid end date format in other selection: %v", err)
-		}
+	appsCollection := OpenCollection(dr.cli, "applications")
+	// The unique index used to cover legacy applications without a student or a
+	// selection, which all index as null and collide.
+	if _, err := appsCollection.Indexes().DropOne(ctx, "uniq_student_selection"); err != nil && !isIndexNotFound(err) {
+		return err
+	}
+	_, err := appsCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "studentId", Value: 1}, {Key: "selectionId", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("uniq_student_selection_keyed").
+				SetPartialFilterExpression(bson.M{
+					"studentId":   bson.M{"$type": "objectId"},
+					"selectionId": bson.M{"$type": "objectId"},
+				}),
+		},
+		{
+			Keys:    bson.D{{Key: "selectionId", Value: 1}, {Key: "status", Value: 1}},
+			Options: options.Index().SetName("selection_status"),
+		},
+		{
+			Keys:    bson.D{{Key: "selectionId", Value: 1}, {Key: "student.gpa", Value: -1}, {Key: "student.year", Value: -1}},
+			Options: options.Index().SetName("selection_score"),
+		},
+	})
+	return err
+}
+
+// InsertApp stores a new pending application; submittedBy and role identify who
+// submitted it, which is an admin when filing on a student's behalf.
+func (dr *DormRepo) InsertApp(app models.Application, selectionId string, submittedBy string, role string) (models.Application, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+	var errorApp models.Application
+
+	appsCollection := OpenCollection(dr.cli, "applications")
+
+	selectionObjectId, err := primitive.ObjectIDFromHex(selectionId)
+	if err != nil {
+		return errorApp, fmt.Errorf("invalid selection ID: %v", err)
+	}
 
-		if (currentStartDate.Before(otherEndDate) && currentEndDate.After(otherStartDate)) ||
-			(otherStartDate.Before(currentEndDate) && otherEndDate.After(currentStartDate) ||
-				currentStartDate.Equal(otherStartDate) || currentStartDate.Equal(otherEndDate) ||
-				currentEndDate.Equal(otherStartDate) || currentEndDate.Equal(otherEndDate)) {
+	if _, err := dr.GetSelection(selectionId); err != nil {
+		return errorApp, fmt.Errorf("failed to get selection: %v", err)
+	}
 
-			return fmt.Errorf("the date you selected overlaps with already existing ones: %s - %s", otherStartDate.String(), otherEndDate.String())
+	now := time.Now()
+	app.Id = primitive.NewObjectID()
+	app.SelectionId = selectionObjectId
+	app.Status = models.StatusPending
+	app.CreatedAt = now
+	app.UpdatedAt = now
+	app.History = []models.StatusChange{{
+		To:            models.StatusPending,
+		ChangedBy:     submittedBy,
+		ChangedByRole: role,
+		ChangedAt:     now,
+		Reason:        "application submitted",
+	}}
+
+	_, err = appsCollection.InsertOne(ctx, &app)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errorApp, ErrDuplicateApplication
 		}
+		return errorApp, fmt.Errorf("error inserting application: %v", err)
 	}
 
-	return nil
+	return app, nil
 }
 
-func (dr *DormRepo) Insertapplications(application *models.Application) error {
-
+func (dr *DormRepo) DeleteApp(appUserID string, selectionId string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
 	defer cancel()
-	application.Status = "Pending"
+
 	appsCollection := OpenCollection(dr.cli, "applications")
-	result, err := appsCollection.InsertOne(ctx, &application)
+
+	selectionObjectId, err := primitive.ObjectIDFromHex(selectionId)
 	if err != nil {
-		dr.logger.Println(err)
-		return err
+		return fmt.Errorf("invalid selection ID: %v", err)
 	}
-	dr.logger.Printf("Documents ID: %v\n", result.InsertedID)
+	studentObjectId, err := primitive.ObjectIDFromHex(appUserID)
+	if err != nil {
+		return fmt.Errorf("invalid student ID: %v", err)
+	}
+
+	result, err := appsCollection.DeleteOne(ctx, bson.M{"studentId": studentObjectId, "selectionId": selectionObjectId})
+	if err != nil {
+		return fmt.Errorf("error deleting application: %v", err)
+	}
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("no application found with user ID: %s", appUserID)
+	}
+
 	return nil
 }
 
-func (dr *DormRepo) InsertApp(app models.Application, selectionId string) (models.Application, error) {
+// UpdateApplicationStatus atomically moves an application from one status to another
+// and appends the change to its history. The update only matches while the application
+// is still in change.From, so two concurrent updates cannot both succeed.
+func (dr *DormRepo) UpdateApplicationStatus(appId primitive.ObjectID, change models.StatusChange) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
 	defer cancel()
-	var errorApp models.Application
-
-	selCollection := OpenCollection(dr.cli, "selections")
 
-	buildingObjectId, err := primitive.ObjectIDFromHex(selectionId)
-	if err != nil {
-		return errorApp, fmt.Errorf("invalid building ID: %v", err)
+	if !models.CanTransition(change.From, change.To) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, change.From, change.To)
 	}
-
-	selection, err := dr.GetSelection(selectionId)
-	if err != nil {
-		return errorApp, fmt.Errorf("failed to get building: %v", err)
+	if change.ChangedAt.IsZero() {
+		change.ChangedAt = time.Now()
 	}
-	app.Id = primitive.NewObjectID()
-	app.Status = "Pending"
 
-	selection.Applications = append(selection.Applications, &app)
+	appsCollection := OpenCollection(dr.cli, "applications")
 
-	_, err = selCollection.UpdateOne(
+	result, err := appsCollection.UpdateOne(
 		ctx,
-		bson.M{"_id": buildingObjectId},
-		bson.M{"$set": bson.M{"applications": selection.Applications}},
+		bson.M{"_id": appId, "status": change.From},
+		bson.M{
+			"$set":  bson.M{"status": change.To, "updatedAt": change.ChangedAt},
+			"$push": bson.M{"history": change},
+		},
 	)
 	if err != nil {
-		return errorApp, fmt.Errorf("error updating building: %v", err)
+		return fmt.Errorf("error updating application: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("application %s is not in status %s", appId.Hex(), change.From)
 	}
 
-	return app, nil
+	return nil
 }
 
-func (dr *DormRepo) DeleteApp(appUserID string, selectionId string) error {
+func (dr *DormRepo) GetApplicationById(appId string) (*models.Application, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
 	defer cancel()
 
-	selCollection := OpenCollection(dr.cli, "selections")
-
-	selectionObjectId, err := primitive.ObjectIDFromHex(selectionId)
+	appObjectId, err := primitive.ObjectIDFromHex(appId)
 	if err != nil {
-		return fmt.Errorf("invalid selection ID: %v", err)
+		return nil, fmt.Errorf("%w: invalid application ID: %v", ErrApplicationNotFound, err)
 	}
 
-	selection, err := dr.GetSelection(selectionId)
-	if err != nil {
-		return fmt.Errorf("failed to get selection: %v", err)
-	}
+	appsCollection := OpenCollection(dr.cli, "applications")
 
-	// Find and remove the application with the given user ID
-	var updatedApplications []*models.Application
-	appFound := false
-	for _, app := range selection.Applications {
-		if app.Student != nil && app.Student.ID.Hex() == appUserID {
-			appFound = true
-		} else {
-			updatedApplications = append(updatedApplications, app)
+	var app models.Application
+	err = appsCollection.FindOne(ctx, bson.M{"_id": appObjectId}).Decode(&app)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("%w with id: %s", ErrApplicationNotFound, appId)
 		}
+		return nil, err
 	}
+	return &app, nil
+}
 
-	if !appFound {
-		return fmt.Errorf("no application found with user ID: %s", appUserID)
+func (dr *DormRepo) GetApplicationsBySelection(selectionId primitive.ObjectID) ([]*models.Application, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	appsCollection := OpenCollection(dr.cli, "applications")
+
+	apps := []*models.Application{}
+	appCursor, err := appsCollection.Find(ctx, bson.M{"selectionId": selectionId})
+	if err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	if err = appCursor.All(ctx, &apps); err != nil {
+		dr.logger.Println(err)
+		return nil, err
 	}
+	return apps, nil
+}
+
+// SetAcceptedBuilding records the building an accepted application got a place in.
+func (dr *DormRepo) SetAcceptedBuilding(appId primitive.ObjectID, buildingId primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
 
-	// Update the selection with the modified applications
-	_, err = selCollection.UpdateOne(
+	_, err := OpenCollection(dr.cli, "applications").UpdateOne(
 		ctx,
-		bson.M{"_id": selectionObjectId},
-		bson.M{"$set": bson.M{"applications": updatedApplications}},
+		bson.M{"_id": appId},
+		bson.M{"$set": bson.M{"acceptedBuildingId": buildingId}},
 	)
 	if err != nil {
-		return fmt.Errorf("error updating selection: %v", err)
+		return fmt.Errorf("error updating application: %v", err)
 	}
-
 	return nil
 }
 
-func (dr *DormRepo) GetAllApplications() ([]*models.Application, error) {
+// StudentsHoldingPlaces returns which of the students hold an accepted or confirmed
+// place in a selection other than exceptSelection.
+func (dr *DormRepo) StudentsHoldingPlaces(studentIds []primitive.ObjectID, exceptSelection primitive.ObjectID) (map[primitive.ObjectID]bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	holding := map[primitive.ObjectID]bool{}
+	if len(studentIds) == 0 {
+		return holding, nil
+	}
+	ids, err := OpenCollection(dr.cli, "applications").Distinct(ctx, "studentId", bson.M{
+		"studentId":   bson.M{"$in": studentIds},
+		"selectionId": bson.M{"$ne": exceptSelection},
+		"status":      bson.M{"$in": bson.A{models.StatusAccepted, models.StatusConfirmed}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if studentId, ok := id.(primitive.ObjectID); ok {
+			holding[studentId] = true
+		}
+	}
+	return holding, nil
+}
+
+func (dr *DormRepo) GetApplicationsByStudent(studentId primitive.ObjectID) ([]*models.Application, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
 	defer cancel()
 
 	appsCollection := OpenCollection(dr.cli, "applications")
 
-	var apps []*models.Application
-	appCursor, err := appsCollection.Find(ctx, bson.M{})
+	apps := []*models.Application{}
+	appCursor, err := appsCollection.Find(ctx, bson.M{"studentId": studentId})
 	if err != nil {
 		dr.logger.Println(err)
 		return nil, err
@@ -367,10 +522,48 @@ func (dr *DormRepo) GetAllApplications() ([]*models.Application, error) {
 	return apps, nil
 }
 
+// FindApplications returns a page of the applications matching filter in the given
+// order, together with the number of matching applications. A limit of 0 returns
+// every match.
+func (dr *DormRepo) FindApplications(filter bson.M, order bson.D, skip int64, limit int64) ([]*models.Application, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	appsCollection := OpenCollection(dr.cli, "applications")
+
+	total, err := appsCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		dr.logger.Println(err)
+		return nil, 0, err
+	}
+
+	apps := []*models.Application{}
+	opts := options.Find().SetSort(append(order, bson.E{Key: "_id", Value: 1})).SetSkip(skip)
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+	appCursor, err := appsCollection.Find(ctx, filter, opts)
+	if err != nil {
+		dr.logger.Println(err)
+		return nil, 0, err
+	}
+	if err = appCursor.All(ctx, &apps); err != nil {
+		dr.logger.Println(err)
+		return nil, 0, err
+	}
+	return apps, total, nil
+}
+
 func (dr *DormRepo) InsertBuilding(building models.Building) (primitive.ObjectID, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
 	defer cancel()
 	building.Id = primitive.NewObjectID()
+	if building.Rooms == nil {
+		building.Rooms = models.Rooms{}
+	}
+	for _, room := range building.Rooms {
+		room.Building_Id = building.Id
+	}
 	buildingCollection := OpenCollection(dr.cli, "buildings")
 	result, err := buildingCollection.InsertOne(ctx, &building)
 	if err != nil {
@@ -398,6 +591,8 @@ func (dr *DormRepo) GetBuilding(id string) (*models.Building, error) {
 
 	return &building, nil
 }
+
+// DeleteBuilding removes a building. Buildings that still have residents are not deleted.
 func (dr *DormRepo) DeleteBuilding(id string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
 	defer cancel()
@@ -409,30 +604,39 @@ func (dr *DormRepo) DeleteBuilding(id string) error {
 
 	buildingCollection := OpenCollection(dr.cli, "buildings")
 
-	result, err := buildingCollection.DeleteOne(ctx, bson.M{"_id": buildingObjectID})
+	result, err := buildingCollection.DeleteOne(ctx, bson.M{
+		"_id":              buildingObjectID,
+		"rooms.students.0": bson.M{"$exists": false},
+	})
 	if err != nil {
 		return fmt.Errorf("error deleting building: %v", err)
 	}
 
 	if result.DeletedCount != 1 {
-		return fmt.Errorf("building not found")
+		if _, err := dr.GetBuilding(id); err != nil {
+			return ErrBuildingNotFound
+		}
+		return ErrBuildingOccupied
 	}
 
 	return nil
 }
-func (dr *DormRepo) UpdateBuilding(buildingID primitive.ObjectID, address string, name string) error {
+
+func (dr *DormRepo) UpdateBuilding(buildingID primitive.ObjectID, building *models.Building) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
 	defer cancel()
 
 	buildingCollection := OpenCollection(dr.cli, "buildings")
 
-	update := bson.M{}
-	if address != "" {
-		update["address"] = address
-		update["name"] = name
+	update := bson.M{
+		"name":              building.Name,
+		"address":           building.Address,
+		"price":             building.Price,
+		"deposit":           building.Deposit,
+		"maxOvernightStays": building.MaxOvernightStays,
 	}
 
-	_, err := buildingCollection.UpdateOne(
+	result, err := buildingCollection.UpdateOne(
 		ctx,
 		bson.M{"_id": buildingID},
 		bson.M{"$set": update},
@@ -440,78 +644,117 @@ func (dr *DormRepo) UpdateBuilding(buildingID primitive.ObjectID, address string
 	if err != nil {
 		return fmt.Errorf("error updating building: %v", err)
 	}
+	if result.MatchedCount == 0 {
+		return ErrBuildingNotFound
+	}
 	return nil
 }
-func (dr *DormRepo) InsertRoom(insertedCapacity int, insertedBuildingId string) error {
+
+// InsertRoom adds a room to a building. Room numbers must be unique within the building.
+func (dr *DormRepo) InsertRoom(room models.Room, insertedBuildingId string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
 	defer cancel()
 
 	buildingCollection := OpenCollection(dr.cli, "buildings")
-	var room models.Room
 
 	buildingObjectId, err := primitive.ObjectIDFromHex(insertedBuildingId)
 	if err != nil {
 		return fmt.Errorf("invalid building ID: %v", err)
 	}
 
-	building, err := dr.GetBuilding(insertedBuildingId)
+	// Buildings created before rooms were initialised store rooms as null, which $push rejects.
+	_, err = buildingCollection.UpdateOne(
+		ctx,
+		bson.M{"_id": buildingObjectId, "rooms": nil},
+		bson.M{"$set": bson.M{"rooms": models.Rooms{}}},
+	)
 	if err != nil {
-		return fmt.Errorf("failed to get building: %v", err)
+		return fmt.Errorf("error updating building: %v", err)
 	}
 
-	room.Room_Number = len(building.Rooms) + 1
 	room.Building_Id = buildingObjectId
-	room.Capacity = insertedCapacity
-
-	building.Rooms = append(building.Rooms, &room)
+	room.Students = nil
 
-	_, err = buildingCollection.UpdateOne(
+	result, err := buildingCollection.UpdateOne(
 		ctx,
-		bson.M{"_id": buildingObjectId},
-		bson.M{"$set": bson.M{"rooms": building.Rooms}},
+		bson.M{"_id": buildingObjectId, "rooms.room_number": bson.M{"$ne": room.Room_Number}},
+		bson.M{"$push": bson.M{"rooms": room}},
 	)
 	if err != nil {
 		return fmt.Errorf("error updating building: %v", err)
 	}
+	if result.MatchedCount == 0 {
+		if _, err := dr.GetBuilding(insertedBuildingId); err != nil {
+			return ErrBuildingNotFound
+		}
+		return fmt.Errorf("%w: room #%d already exists", ErrRoomConflict, room.Room_Number)
+	}
 
 	return nil
 }
 
-// EditRoom updates the details of a room in a building
+// EditRoom changes the number, floor, capacity, type and price of a room embedded in a building.
+// The capacity is only lowered if the room's current occupants still fit; the check
+// is part of the update filter so it cannot race with students being added.
 func (dr *DormRepo) EditRoom(roomNumber int, buildingId primitive.ObjectID, updatedRoom *models.Room) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
 	defer cancel()
 
-	roomCollection := OpenCollection(dr.cli, "rooms")
+	if updatedRoom.Capacity < 1 {
+		return fmt.Errorf("%w: capacity must be at least 1", ErrRoomConflict)
+	}
+
+	buildingCollection := OpenCollection(dr.cli, "buildings")
 
-	// Define the filter to find the specific room based on room_number and building_id
 	filter := bson.M{
-		"room_number": roomNumber,
-		"building_id": buildingId,
+		"_id": buildingId,
+		"rooms": bson.M{"$elemMatch": bson.M{
+			"room_number": roomNumber,
+			fmt.Sprintf("students.%d", updatedRoom.Capacity): bson.M{"$exists": false},
+		}},
+	}
+	if updatedRoom.Room_Number != roomNumber {
+		filter["rooms.room_number"] = bson.M{"$ne": updatedRoom.Room_Number}
 	}
 
-	// Define the fields to update
-	update := bson.M{
-		"$set": bson.M{
-			"room_number": updatedRoom.Room_Number,
-			"capacity":    updatedRoom.Capacity,
-			"students":    updatedRoom.Students, // Update the students list if needed
-		},
+	set := bson.M{
+		"rooms.$[room].room_number":  updatedRoom.Room_Number,
+		"rooms.$[room].floor":        updatedRoom.Floor,
+		"rooms.$[room].capacity":     updatedRoom.Capacity,
+		"rooms.$[room].monthlyPrice": updatedRoom.MonthlyPrice,
+		"rooms.$[room].inventory":    updatedRoom.Inventory,
+	}
+	update := bson.M{"$set": set}
+	if updatedRoom.RoomTypeId.IsZero() {
+		update["$unset"] = bson.M{"rooms.$[room].roomTypeId": ""}
+	} else {
+		set["rooms.$[room].roomTypeId"] = updatedRoom.RoomTypeId
 	}
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.M{"room.room_number": roomNumber}},
+	})
 
-	// Perform the update operation
-	result, err := roomCollection.UpdateOne(ctx, filter, update)
+	result, err := buildingCollection.UpdateOne(ctx, filter, update, opts)
 	if err != nil {
 		return fmt.Errorf("failed to update room: %v", err)
 	}
 
 	if result.MatchedCount == 0 {
-		return fmt.Errorf("no room found with room number %d in building", roomNumber)
+		room, err := dr.GetRoom(roomNumber, buildingId.Hex())
+		if err != nil {
+			return fmt.Errorf("%w: no room found with room number %d in building", ErrRoomNotFound, roomNumber)
+		}
+		if room.Occupancy() > updatedRoom.Capacity {
+			return fmt.Errorf("%w: room #%d has %d occupants, capacity cannot be lowered to %d",
+				ErrRoomConflict, roomNumber, room.Occupancy(), updatedRoom.Capacity)
+		}
+		return fmt.Errorf("%w: room #%d already exists", ErrRoomConflict, updatedRoom.Room_Number)
 	}
 
 	dr.logger.Printf("Room %d in building %s updated successfully", roomNumber, buildingId.Hex())
 	return nil
 }
+
 func (dr *DormRepo) GetRoom(number int, buildingId string) (*models.Room, error) {
 	buildingCollection := OpenCollection(dr.cli, "buildings")
 
@@ -0,0 +1,363 @@
+package data
+
+import (
+	"context"
+	"dorm-service/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrFacilityNotFound = errors.New("facility not found")
+	ErrSlotFull         = errors.New("slot is fully booked")
+	ErrBookingNotFound  = errors.New("booking not found")
+	ErrBookingChanged   = errors.New("booking is no longer in the expected status")
+	ErrAlreadyBooked    = errors.New("you have already booked this slot")
+	ErrBookingLimit     = errors.New("booking limit reached")
+)
+
+// EnsureFacilityIndexes creates the booking indexes. The unique (facility, start,
+// seat) index over bookings that hold their slot is what keeps a slot from being
+// overbooked, and the unique (student, facility) index keeps one booking guard per
+// resident and facility.
+func (dr *DormRepo) EnsureFacilityIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	_, err := OpenCollection(dr.cli, "facilities").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "buildingId", Value: 1}},
+		Options: options.Index().SetName("building"),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = OpenCollection(dr.cli, "bookings").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "facilityId", Value: 1}, {Key: "start", Value: 1}, {Key: "seat", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("uniq_slot_seat").
+				SetPartialFilterExpression(bson.M{"holdsSlot": true}),
+		},
+		{
+			Keys:    bson.D{{Key: "studentId", Value: 1}, {Key: "start", Value: -1}},
+			Options: options.Index().SetName("student_start"),
+		},
+		{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "end", Value: 1}},
+			Options: options.Index().SetName("status_end"),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = OpenCollection(dr.cli, "bookingGuards").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "studentId", Value: 1}, {Key: "facilityId", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("uniq_student_facility"),
+	})
+	return err
+}
+
+func (dr *DormRepo) InsertFacility(facility *models.Facility) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	facility.Id = primitive.NewObjectID()
+	facility.Active = true
+	_, err := OpenCollection(dr.cli, "facilities").InsertOne(ctx, facility)
+	if err != nil {
+		return fmt.Errorf("error inserting facility: %v", err)
+	}
+	return nil
+}
+
+func (dr *DormRepo) GetFacility(id string) (*models.Facility, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	facilityId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid facility ID", ErrFacilityNotFound)
+	}
+
+	var facility models.Facility
+	err = OpenCollection(dr.cli, "facilities").FindOne(ctx, bson.M{"_id": facilityId}).Decode(&facility)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrFacilityNotFound
+		}
+		return nil, err
+	}
+	return &facility, nil
+}
+
+// GetFacilities returns the facilities of a building, only the bookable ones unless all is set.
+func (dr *DormRepo) GetFacilities(buildingId primitive.ObjectID, all bool) ([]*models.Facility, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	filter := bson.M{"buildingId": buildingId}
+	if !all {
+		filter["active"] = true
+	}
+
+	facilities := []*models.Facility{}
+	cursor, err := OpenCollection(dr.cli, "facilities").Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	if err = cursor.All(ctx, &facilities); err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	return facilities, nil
+}
+
+func (dr *DormRepo) UpdateFacility(facilityId primitive.ObjectID, facility *models.Facility) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	result, err := OpenCollection(dr.cli, "facilities").UpdateOne(
+		ctx,
+		bson.M{"_id": facilityId},
+		bson.M{"$set": bson.M{
+			"name":               facility.Name,
+			"type":               facility.Type,
+			"description":        facility.Description,
+			"opensAt":            facility.OpensAt,
+			"closesAt":           facility.ClosesAt,
+			"slotMinutes":        facility.SlotMinutes,
+			"capacity":           facility.Capacity,
+			"maxActiveBookings":  facility.MaxActiveBookings,
+			"maxBookingsPerWeek": facility.MaxBookingsPerWeek,
+			"noShowLimit":        facility.NoShowLimit,
+			"active":             facility.Active,
+		}},
+	)
+	if err != nil {
+		return fmt.Errorf("error updating facility: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrFacilityNotFound
+	}
+	return nil
+}
+
+// InsertBooking books a seat in a slot within the facility's per-resident limits.
+// The limits are counted and the booking is written in one transaction that first
+// bumps the resident's booking guard, so two bookings by the same resident conflict
+// and the retried one counts the other. The unique slot index rejects a seat someone
+// else has just taken, and the next free seat is tried.
+func (dr *DormRepo) InsertBooking(booking *models.Booking, facility *models.Facility, weekStart time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	booking.Status = models.BookingBooked
+	booking.HoldsSlot = true
+	booking.CreatedAt = time.Now()
+
+	for attempt := 0; attempt < facility.Capacity; attempt++ {
+		booking.Id = primitive.NewObjectID()
+		err := dr.inTransaction(ctx, func(sc mongo.SessionContext) error {
+			return dr.bookSeat(sc, booking, facility, weekStart)
+		})
+		if !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+	return ErrSlotFull
+}
+
+func (dr *DormRepo) bookSeat(ctx context.Context, booking *models.Booking, facility *models.Facility, weekStart time.Time) error {
+	_, err := OpenCollection(dr.cli, "bookingGuards").UpdateOne(
+		ctx,
+		bson.M{"studentId": booking.StudentId, "facilityId": facility.Id},
+		bson.M{"$inc": bson.M{"bookings": 1}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("error updating booking guard: %v", err)
+	}
+
+	bookingsCollection := OpenCollection(dr.cli, "bookings")
+	mine := bson.M{"studentId": booking.StudentId, "facilityId": facility.Id, "holdsSlot": true, "start": booking.Start}
+	existing, err := bookingsCollection.CountDocuments(ctx, mine)
+	if err != nil {
+		return err
+	}
+	if existing > 0 {
+		return ErrAlreadyBooked
+	}
+	if facility.MaxActiveBookings > 0 {
+		active, err := bookingsCollection.CountDocuments(ctx, bson.M{
+			"studentId":  booking.StudentId,
+			"facilityId": facility.Id,
+			"status":     models.BookingBooked,
+			"end":        bson.M{"$gt": booking.CreatedAt},
+		})
+		if err != nil {
+			return err
+		}
+		if int(active) >= facility.MaxActiveBookings {
+			return fmt.Errorf("%w: you can hold at most %d upcoming bookings of %s", ErrBookingLimit, facility.MaxActiveBookings, facility.Name)
+		}
+	}
+	if facility.MaxBookingsPerWeek > 0 {
+		mine["start"] = bson.M{"$gte": weekStart, "$lt": weekStart.AddDate(0, 0, 7)}
+		weekly, err := bookingsCollection.CountDocuments(ctx, mine)
+		if err != nil {
+			return err
+		}
+		if int(weekly) >= facility.MaxBookingsPerWeek {
+			return fmt.Errorf("%w: you can book %s at most %d times a week", ErrBookingLimit, facility.Name, facility.MaxBookingsPerWeek)
+		}
+	}
+
+	var taken []*models.Booking
+	cursor, err := bookingsCollection.Find(ctx, bson.M{"facilityId": facility.Id, "start": booking.Start, "holdsSlot": true})
+	if err != nil {
+		return err
+	}
+	if err := cursor.All(ctx, &taken); err != nil {
+		return err
+	}
+	seats := map[int]bool{}
+	for _, b := range taken {
+		seats[b.Seat] = true
+	}
+	booking.Seat = -1
+	for seat := 0; seat < facility.Capacity; seat++ {
+		if !seats[seat] {
+			booking.Seat = seat
+			break
+		}
+	}
+	if booking.Seat < 0 {
+		return ErrSlotFull
+	}
+
+	if _, err := bookingsCollection.InsertOne(ctx, booking); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+		return fmt.Errorf("error inserting booking: %v", err)
+	}
+	return nil
+}
+
+func (dr *DormRepo) GetBooking(id string) (*models.Booking, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	bookingId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid booking ID", ErrBookingNotFound)
+	}
+
+	var booking models.Booking
+	err = OpenCollection(dr.cli, "bookings").FindOne(ctx, bson.M{"_id": bookingId}).Decode(&booking)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrBookingNotFound
+		}
+		return nil, err
+	}
+	return &booking, nil
+}
+
+// GetBookings returns the bookings matching the filter ordered by start.
+func (dr *DormRepo) GetBookings(filter bson.M) ([]*models.Booking, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	bookings := []*models.Booking{}
+	cursor, err := OpenCollection(dr.cli, "bookings").Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "start", Value: 1}}))
+	if err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	if err = cursor.All(ctx, &bookings); err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	return bookings, nil
+}
+
+func (dr *DormRepo) CountBookings(filter bson.M) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	return OpenCollection(dr.cli, "bookings").CountDocuments(ctx, filter)
+}
+
+// SetBookingStatus moves a booking from one status to another. Cancelling a
+// booking gives its seat back.
+func (dr *DormRepo) SetBookingStatus(bookingId primitive.ObjectID, from string, to string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	set := bson.M{"status": to}
+	switch to {
+	case models.BookingCancelled:
+		set["holdsSlot"] = false
+		set["cancelledAt"] = now
+	case models.BookingAttended:
+		set["checkedInAt"] = now
+	}
+
+	result, err := OpenCollection(dr.cli, "bookings").UpdateOne(
+		ctx,
+		bson.M{"_id": bookingId, "status": from},
+		bson.M{"$set": set},
+	)
+	if err != nil {
+		return fmt.Errorf("error updating booking: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrBookingChanged
+	}
+	return nil
+}
+
+// CancelFacilityBookings cancels all upcoming bookings of a facility and returns them.
+func (dr *DormRepo) CancelFacilityBookings(facilityId primitive.ObjectID, from time.Time) ([]*models.Booking, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	filter := bson.M{"facilityId": facilityId, "status": models.BookingBooked, "start": bson.M{"$gte": from}}
+	bookings, err := dr.GetBookings(filter)
+	if err != nil {
+		return nil, err
+	}
+	_, err = OpenCollection(dr.cli, "bookings").UpdateMany(ctx, filter, bson.M{"$set": bson.M{
+		"status":      models.BookingCancelled,
+		"holdsSlot":   false,
+		"cancelledAt": time.Now(),
+	}})
+	if err != nil {
+		return nil, fmt.Errorf("error cancelling bookings: %v", err)
+	}
+	return bookings, nil
+}
+
+// MarkNoShows marks bookings that ended without the resident checking in as no-shows.
+func (dr *DormRepo) MarkNoShows(now time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	result, err := OpenCollection(dr.cli, "bookings").UpdateMany(
+		ctx,
+		bson.M{"status": models.BookingBooked, "end": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"status": models.BookingNoShow}},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error marking no-shows: %v", err)
+	}
+	return result.ModifiedCount, nil
+}
@@ -0,0 +1,184 @@
+package data
+
+import (
+	"context"
+	"dorm-service/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrIncidentNotFound          = errors.New("incident not found")
+	ErrInvalidIncidentTransition = errors.New("invalid incident status transition")
+	ErrFineNotDue                = errors.New("incident has no unpaid fine")
+)
+
+func (dr *DormRepo) EnsureIncidentIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	_, err := OpenCollection(dr.cli, "incidents").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "studentId", Value: 1}, {Key: "status", Value: 1}, {Key: "sanction.imposedAt", Value: -1}},
+			Options: options.Index().SetName("student_status_imposed"),
+		},
+		{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: -1}},
+			Options: options.Index().SetName("status_created"),
+		},
+	})
+	return err
+}
+
+func (dr *DormRepo) InsertIncident(incident *models.Incident) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	incident.Id = primitive.NewObjectID()
+	incident.Status = models.IncidentReported
+	incident.CreatedAt = now
+	incident.UpdatedAt = now
+	incident.History = []models.StatusChange{{
+		To:            models.IncidentReported,
+		ChangedBy:     incident.ReportedBy,
+		ChangedByRole: incident.ReporterRole,
+		ChangedAt:     now,
+		Reason:        incident.Rule,
+	}}
+	_, err := OpenCollection(dr.cli, "incidents").InsertOne(ctx, incident)
+	if err != nil {
+		return fmt.Errorf("error inserting incident: %v", err)
+	}
+	return nil
+}
+
+func (dr *DormRepo) GetIncident(id string) (*models.Incident, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	incidentId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid incident ID", ErrIncidentNotFound)
+	}
+
+	var incident models.Incident
+	err = OpenCollection(dr.cli, "incidents").FindOne(ctx, bson.M{"_id": incidentId}).Decode(&incident)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrIncidentNotFound
+		}
+		return nil, err
+	}
+	return &incident, nil
+}
+
+// GetIncidents returns the incidents matching the filter, newest first.
+func (dr *DormRepo) GetIncidents(filter bson.M) ([]*models.Incident, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	incidents := []*models.Incident{}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := OpenCollection(dr.cli, "incidents").Find(ctx, filter, opts)
+	if err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	if err = cursor.All(ctx, &incidents); err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	return incidents, nil
+}
+
+// UpdateIncidentStatus moves an incident through the hearing workflow, setting the
+// given fields and recording the change in its history. Like tickets, the update
+// only matches while the incident is still in change.From.
+func (dr *DormRepo) UpdateIncidentStatus(incidentId primitive.ObjectID, change models.StatusChange, set bson.M) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	if !models.CanTransitionIncident(change.From, change.To) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidIncidentTransition, change.From, change.To)
+	}
+
+	fields := bson.M{"status": change.To, "updatedAt": change.ChangedAt}
+	for key, value := range set {
+		fields[key] = value
+	}
+	result, err := OpenCollection(dr.cli, "incidents").UpdateOne(
+		ctx,
+		bson.M{"_id": incidentId, "status": change.From},
+		bson.M{"$set": fields, "$push": bson.M{"history": change}},
+	)
+	if err != nil {
+		return fmt.Errorf("error updating incident: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%w: incident is no longer %s", ErrInvalidIncidentTransition, change.From)
+	}
+	return nil
+}
+
+// SetIncidentStatement stores the resident's statement while the incident is
+// still waiting for a decision.
+func (dr *DormRepo) SetIncidentStatement(incidentId primitive.ObjectID, statement string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	result, err := OpenCollection(dr.cli, "incidents").UpdateOne(ctx,
+		bson.M{"_id": incidentId, "status": bson.M{"$in": bson.A{models.IncidentReported, models.IncidentHearingScheduled}}},
+		bson.M{"$set": bson.M{"statement": statement, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return fmt.Errorf("error updating incident: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%w: the incident has already been decided", ErrInvalidIncidentTransition)
+	}
+	return nil
+}
+
+// RecordFinePayment marks the fine of a sanctioned incident as paid. It fails with
+// ErrFineNotDue if there is no fine or it was paid already.
+func (dr *DormRepo) RecordFinePayment(incidentId primitive.ObjectID, ref string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	result, err := OpenCollection(dr.cli, "incidents").UpdateOne(ctx,
+		bson.M{
+			"_id":                 incidentId,
+			"status":              models.IncidentSanctioned,
+			"sanction.fineAmount": bson.M{"$gt": 0},
+			"sanction.finePaidAt": bson.M{"$exists": false},
+		},
+		bson.M{"$set": bson.M{"sanction.finePaidAt": time.Now(), "sanction.fineRef": ref, "updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return fmt.Errorf("error updating incident: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrFineNotDue
+	}
+	return nil
+}
+
+// GetSanctions returns the sanctions imposed on the given students since the given
+// time.
+func (dr *DormRepo) GetSanctions(studentIds []primitive.ObjectID, since time.Time) ([]*models.Incident, error) {
+	if len(studentIds) == 0 {
+		return []*models.Incident{}, nil
+	}
+	return dr.GetIncidents(bson.M{
+		"studentId":          bson.M{"$in": studentIds},
+		"status":             models.IncidentSanctioned,
+		"sanction.imposedAt": bson.M{"$gte": since},
+	})
+}
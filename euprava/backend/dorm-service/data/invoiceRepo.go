@@ -0,0 +1,334 @@
+package data
+
+import (
+	"context"
+	"dorm-service/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrInvoiceExists   = errors.New("invoice for this period already exists")
+	ErrInvoiceNotFound = errors.New("invoice not found")
+	ErrOverpayment     = errors.New("payment exceeds the outstanding amount")
+	ErrInvoiceChanged  = errors.New("invoice was changed in the meantime")
+)
+
+// EnsureInvoiceIndexes creates the indexes used by rent invoicing. The unique
+// (studentId, period, buildingId, roomNumber) index makes invoice generation safe to
+// repeat, while a student who moves mid-month is billed for each room separately.
+func (dr *DormRepo) EnsureInvoiceIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	invoices := OpenCollection(dr.cli, "invoices")
+	// Invoices used to be unique per student and period only.
+	if _, err := invoices.Indexes().DropOne(ctx, "uniq_student_period"); err != nil && !isIndexNotFound(err) {
+		return err
+	}
+	_, err := invoices.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "studentId", Value: 1}, {Key: "period", Value: 1},
+				{Key: "buildingId", Value: 1}, {Key: "roomNumber", Value: 1},
+			},
+			Options: options.Index().SetUnique(true).SetName("uniq_student_period_room"),
+		},
+		{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "dueDate", Value: 1}},
+			Options: options.Index().SetName("status_due"),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = OpenCollection(dr.cli, "ledger").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "studentId", Value: 1}, {Key: "createdAt", Value: 1}},
+		Options: options.Index().SetName("student_created"),
+	})
+	return err
+}
+
+// isIndexNotFound reports whether dropping an index failed only because the index or
+// the collection does not exist.
+func isIndexNotFound(err error) bool {
+	var cmdErr mongo.CommandError
+	return errors.As(err, &cmdErr) && (cmdErr.Code == 26 || cmdErr.Code == 27)
+}
+
+func (dr *DormRepo) GetAllBuildings() ([]*models.Building, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	buildings := []*models.Building{}
+	cursor, err := OpenCollection(dr.cli, "buildings").Find(ctx, bson.M{})
+	if err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	if err = cursor.All(ctx, &buildings); err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	return buildings, nil
+}
+
+func (dr *DormRepo) insertLedgerEntry(ctx context.Context, entry *models.LedgerEntry) error {
+	entry.Id = primitive.NewObjectID()
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	_, err := OpenCollection(dr.cli, "ledger").InsertOne(ctx, entry)
+	if err != nil {
+		return fmt.Errorf("error inserting ledger entry: %v", err)
+	}
+	return nil
+}
+
+// InsertInvoice issues an invoice and books its amount as a charge on the student's ledger.
+func (dr *DormRepo) InsertInvoice(invoice *models.Invoice) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	invoice.Id = primitive.NewObjectID()
+	invoice.Status = models.InvoiceOpen
+	invoice.IssuedAt = time.Now()
+
+	_, err := OpenCollection(dr.cli, "invoices").InsertOne(ctx, invoice)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrInvoiceExists
+		}
+		return fmt.Errorf("error inserting invoice: %v", err)
+	}
+
+	return dr.insertLedgerEntry(ctx, &models.LedgerEntry{
+		StudentId: invoice.StudentId,
+		InvoiceId: invoice.Id,
+		Type:      models.LedgerCharge,
+		Amount:    invoice.Amount,
+		CreatedAt: invoice.IssuedAt,
+	})
+}
+
+func (dr *DormRepo) GetInvoice(id string) (*models.Invoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	invoiceObjectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid invoice ID: %v", err)
+	}
+
+	var invoice models.Invoice
+	err = OpenCollection(dr.cli, "invoices").FindOne(ctx, bson.M{"_id": invoiceObjectId}).Decode(&invoice)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrInvoiceNotFound
+		}
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// GetInvoices returns invoices matching the filter, newest period first.
+func (dr *DormRepo) GetInvoices(filter bson.M) ([]*models.Invoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	invoices := []*models.Invoice{}
+	opts := options.Find().SetSort(bson.D{{Key: "period", Value: -1}, {Key: "dueDate", Value: 1}})
+	cursor, err := OpenCollection(dr.cli, "invoices").Find(ctx, filter, opts)
+	if err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	if err = cursor.All(ctx, &invoices); err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	return invoices, nil
+}
+
+// RecordPayment adds a payment to an invoice and books it on the ledger. The
+// amount is only added if it does not exceed what is still owed, and the invoice
+// status is recalculated in the same update.
+func (dr *DormRepo) RecordPayment(invoiceId primitive.ObjectID, entry *models.LedgerEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	amount := models.RoundMoney(entry.Amount)
+	result, err := OpenCollection(dr.cli, "invoices").UpdateOne(
+		ctx,
+		bson.M{
+			"_id": invoiceId,
+			"$expr": bson.M{"$lte": bson.A{
+				bson.M{"$round": bson.A{bson.M{"$add": bson.A{"$amountPaid", amount}}, 2}},
+				"$amount",
+			}},
+		},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{"amountPaid": bson.M{"$round": bson.A{bson.M{"$add": bson.A{"$amountPaid", amount}}, 2}}}}},
+			{{Key: "$set", Value: bson.M{"status": bson.M{"$cond": bson.A{
+				bson.M{"$gte": bson.A{"$amountPaid", "$amount"}},
+				models.InvoicePaid,
+				models.InvoicePartiallyPaid,
+			}}}}},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("error recording payment: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrOverpayment
+	}
+
+	entry.InvoiceId = invoiceId
+	entry.Type = models.LedgerPayment
+	entry.Amount = amount
+	return dr.insertLedgerEntry(ctx, entry)
+}
+
+// invoiceStatus recalculates the status of an invoice from its amount and what was paid.
+var invoiceStatus = bson.M{"$switch": bson.M{
+	"branches": bson.A{
+		bson.M{"case": bson.M{"$gte": bson.A{"$amountPaid", "$amount"}}, "then": models.InvoicePaid},
+		bson.M{"case": bson.M{"$gt": bson.A{"$amountPaid", 0}}, "then": models.InvoicePartiallyPaid},
+	},
+	"default": models.InvoiceOpen,
+}}
+
+// CreditInvoice lowers an invoice to the amount owed for the days actually stayed and
+// books the difference as a refund on the student's ledger. The invoice is only
+// changed if its amount is still the one it was read with, and it is restored if the
+// ledger entry cannot be written.
+func (dr *DormRepo) CreditInvoice(invoice *models.Invoice, amount float64, billedTo time.Time, days int, recordedBy string) (*models.LedgerEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	amount = models.RoundMoney(amount)
+	credit := models.RoundMoney(invoice.Amount - amount)
+	if credit <= 0 {
+		return nil, nil
+	}
+
+	invoices := OpenCollection(dr.cli, "invoices")
+	result, err := invoices.UpdateOne(
+		ctx,
+		bson.M{"_id": invoice.Id, "amount": invoice.Amount},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{"amount": amount, "billedTo": billedTo, "daysBilled": days}}},
+			{{Key: "$set", Value: bson.M{"status": invoiceStatus}}},
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error crediting invoice: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return nil, ErrInvoiceChanged
+	}
+
+	entry := models.LedgerEntry{
+		StudentId:  invoice.StudentId,
+		InvoiceId:  invoice.Id,
+		Type:       models.LedgerRefund,
+		Amount:     credit,
+		RecordedBy: recordedBy,
+	}
+	if err := dr.insertLedgerEntry(ctx, &entry); err != nil {
+		_, restoreErr := invoices.UpdateOne(
+			ctx,
+			bson.M{"_id": invoice.Id, "amount": amount},
+			mongo.Pipeline{
+				{{Key: "$set", Value: bson.M{"amount": invoice.Amount, "billedTo": invoice.BilledTo, "daysBilled": invoice.DaysBilled}}},
+				{{Key: "$set", Value: bson.M{"status": invoiceStatus}}},
+			},
+		)
+		if restoreErr != nil {
+			dr.logger.Printf("could not restore invoice %s after a failed credit: %v", invoice.Id.Hex(), restoreErr)
+		}
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetInvoicesToRemind returns overdue invoices whose last reminder is older than remindEvery.
+func (dr *DormRepo) GetInvoicesToRemind(now time.Time, remindEvery time.Duration) ([]*models.Invoice, error) {
+	return dr.GetInvoices(bson.M{
+		"status":  bson.M{"$ne": models.InvoicePaid},
+		"dueDate": bson.M{"$lt": now},
+		"$or": bson.A{
+			bson.M{"lastReminderAt": bson.M{"$exists": false}},
+			bson.M{"lastReminderAt": bson.M{"$lt": now.Add(-remindEvery)}},
+		},
+	})
+}
+
+func (dr *DormRepo) MarkReminderSent(invoiceId primitive.ObjectID, at time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	_, err := OpenCollection(dr.cli, "invoices").UpdateOne(ctx, bson.M{"_id": invoiceId}, bson.M{
+		"$set": bson.M{"lastReminderAt": at},
+		"$inc": bson.M{"remindersSent": 1},
+	})
+	return err
+}
+
+// GetArrears sums up the overdue invoices per student, biggest debt first.
+func (dr *DormRepo) GetArrears(now time.Time) ([]*models.Arrears, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"status": bson.M{"$ne": models.InvoicePaid}, "dueDate": bson.M{"$lt": now}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":           "$studentId",
+			"outstanding":   bson.M{"$sum": bson.M{"$subtract": bson.A{"$amount", "$amountPaid"}}},
+			"invoices":      bson.M{"$sum": 1},
+			"oldestDueDate": bson.M{"$min": "$dueDate"},
+			"remindersSent": bson.M{"$sum": "$remindersSent"},
+			"buildingIds":   bson.M{"$addToSet": "$buildingId"},
+		}}},
+		{{Key: "$sort", Value: bson.M{"outstanding": -1}}},
+	}
+
+	arrears := []*models.Arrears{}
+	cursor, err := OpenCollection(dr.cli, "invoices").Aggregate(ctx, pipeline)
+	if err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	if err = cursor.All(ctx, &arrears); err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	for _, a := range arrears {
+		a.Outstanding = models.RoundMoney(a.Outstanding)
+	}
+	return arrears, nil
+}
+
+func (dr *DormRepo) GetLedger(studentId primitive.ObjectID) ([]*models.LedgerEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	entries := []*models.LedgerEntry{}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}})
+	cursor, err := OpenCollection(dr.cli, "ledger").Find(ctx, bson.M{"studentId": studentId}, opts)
+	if err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	if err = cursor.All(ctx, &entries); err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,87 @@
+package data
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// EnsureManagerIndexes creates the index used to look up the buildings of a manager.
+func (dr *DormRepo) EnsureManagerIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	_, err := OpenCollection(dr.cli, "buildings").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "managers", Value: 1}},
+		Options: options.Index().SetName("managers"),
+	})
+	return err
+}
+
+// SetBuildingManagers replaces the managers of a building.
+func (dr *DormRepo) SetBuildingManagers(buildingId primitive.ObjectID, managers []primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"managers": managers}}
+	if len(managers) == 0 {
+		update = bson.M{"$unset": bson.M{"managers": ""}}
+	}
+	result, err := OpenCollection(dr.cli, "buildings").UpdateOne(ctx, bson.M{"_id": buildingId}, update)
+	if err != nil {
+		return fmt.Errorf("error updating building managers: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrBuildingNotFound
+	}
+	return nil
+}
+
+// ManagedBuildings returns the ids of the buildings a manager runs.
+func (dr *DormRepo) ManagedBuildings(managerId primitive.ObjectID) ([]primitive.ObjectID, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	values, err := OpenCollection(dr.cli, "buildings").Distinct(ctx, "_id", bson.M{"managers": managerId})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]primitive.ObjectID, 0, len(values))
+	for _, value := range values {
+		if id, ok := value.(primitive.ObjectID); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// ManagesBuildings reports whether a manager runs every one of the buildings.
+func (dr *DormRepo) ManagesBuildings(managerId primitive.ObjectID, buildingIds []primitive.ObjectID) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	unique := map[primitive.ObjectID]bool{}
+	for _, id := range buildingIds {
+		unique[id] = true
+	}
+	if len(unique) == 0 {
+		return false, nil
+	}
+	ids := make([]primitive.ObjectID, 0, len(unique))
+	for id := range unique {
+		ids = append(ids, id)
+	}
+	count, err := OpenCollection(dr.cli, "buildings").CountDocuments(ctx, bson.M{
+		"_id":      bson.M{"$in": ids},
+		"managers": managerId,
+	})
+	if err != nil {
+		return false, err
+	}
+	return count == int64(len(ids)), nil
+}
@@ -0,0 +1,271 @@
+package data
+
+import (
+	"context"
+	"dorm-service/models"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// legacySelection is the old selection layout in which applications were
+// embedded in the selection document itself.
+type legacySelection struct {
+	Id           primitive.ObjectID    `bson:"_id"`
+	Applications []*models.Application `bson:"applications"`
+}
+
+// MigrateEmbeddedApplications moves applications embedded in selection documents
+// into the applications collection and removes the embedded array.
+// It is safe to run more than once: already migrated applications are skipped
+// thanks to the unique (studentId, selectionId) index.
+func (dr *DormRepo) MigrateEmbeddedApplications() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	selCollection := OpenCollection(dr.cli, "selections")
+	appsCollection := OpenCollection(dr.cli, "applications")
+
+	cursor, err := selCollection.Find(ctx, bson.M{"applications.0": bson.M{"$exists": true}})
+	if err != nil {
+		return fmt.Errorf("error querying selections: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	migrated := 0
+	for cursor.Next(ctx) {
+		var selection legacySelection
+		if err := cursor.Decode(&selection); err != nil {
+			return fmt.Errorf("error decoding selection: %v", err)
+		}
+
+		for _, app := range selection.Applications {
+			if app == nil || app.Student == nil {
+				continue
+			}
+			app.SelectionId = selection.Id
+			app.StudentId = app.Student.ID
+			if app.Id.IsZero() {
+				app.Id = primitive.NewObjectID()
+			}
+			if app.CreatedAt.IsZero() {
+				app.CreatedAt = app.Id.Timestamp()
+			}
+			app.UpdatedAt = time.Now()
+
+			_, err := appsCollection.InsertOne(ctx, app)
+			if err != nil && !mongo.IsDuplicateKeyError(err) {
+				return fmt.Errorf("error migrating application %s: %v", app.Id.Hex(), err)
+			}
+			if err == nil {
+				migrated++
+			}
+		}
+
+		_, err := selCollection.UpdateOne(ctx, bson.M{"_id": selection.Id}, bson.M{"$unset": bson.M{"applications": ""}})
+		if err != nil {
+			return fmt.Errorf("error clearing applications of selection %s: %v", selection.Id.Hex(), err)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	dr.logger.Printf("Migrated %d embedded applications", migrated)
+	return nil
+}
+
+// BackfillApplicationKeys fills in the studentId of older applications from the
+// student embedded in them. Applications that still lack a student or a selection
+// are only logged: the unique (studentId, selectionId) index leaves them out. The
+// migration only touches applications without a studentId, so it is safe to run
+// more than once.
+func (dr *DormRepo) BackfillApplicationKeys() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	appsCollection := OpenCollection(dr.cli, "applications")
+	result, err := appsCollection.UpdateMany(
+		ctx,
+		bson.M{
+			"studentId":        bson.M{"$not": bson.M{"$type": "objectId"}},
+			"student.user._id": bson.M{"$type": "objectId"},
+		},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"studentId": "$student.user._id"}}}},
+	)
+	if err != nil {
+		return fmt.Errorf("error backfilling application students: %v", err)
+	}
+
+	unkeyed, err := appsCollection.CountDocuments(ctx, bson.M{"$or": bson.A{
+		bson.M{"studentId": bson.M{"$not": bson.M{"$type": "objectId"}}},
+		bson.M{"selectionId": bson.M{"$not": bson.M{"$type": "objectId"}}},
+	}})
+	if err != nil {
+		return fmt.Errorf("error counting applications: %v", err)
+	}
+
+	dr.logger.Printf("Backfilled the student of %d applications, %d applications still lack a student or a selection", result.ModifiedCount, unkeyed)
+	return nil
+}
+
+// legacyDateKeys are the keys older selection documents kept their day-first date
+// strings under.
+var legacyDateKeys = map[string][]string{
+	"startDate": {"startDate", "startdate", "start_date"},
+	"endDate":   {"endDate", "enddate", "end_date"},
+}
+
+// MigrateSelectionDates converts the "02-01-2006" date strings of older selections
+// into timestamps in the default time zone. The old end date was the last day of the
+// selection, so the new end is midnight after it. Selections already stored with
+// timestamps are not touched, which makes the migration safe to run more than once.
+func (dr *DormRepo) MigrateSelectionDates() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	loc, err := time.LoadLocation(models.DefaultTimeZone)
+	if err != nil {
+		return fmt.Errorf("cannot load time zone %s: %v", models.DefaultTimeZone, err)
+	}
+
+	selCollection := OpenCollection(dr.cli, "selections")
+	cursor, err := selCollection.Find(ctx, bson.M{"$or": bson.A{
+		bson.M{"startDate": bson.M{"$not": bson.M{"$type": "date"}}},
+		bson.M{"endDate": bson.M{"$not": bson.M{"$type": "date"}}},
+	}})
+	if err != nil {
+		return fmt.Errorf("error querying selections: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	migrated := 0
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return fmt.Errorf("error decoding selection: %v", err)
+		}
+		id, _ := doc["_id"].(primitive.ObjectID)
+
+		set := bson.M{"timeZone": models.DefaultTimeZone}
+		unset := bson.M{}
+		for field, keys := range legacyDateKeys {
+			var day time.Time
+			for _, key := range keys {
+				value, ok := doc[key].(string)
+				if !ok {
+					continue
+				}
+				if key != field {
+					unset[key] = ""
+				}
+				if parsed, err := time.ParseInLocation("02-01-2006", value, loc); err == nil {
+					day = parsed
+				}
+			}
+			if day.IsZero() {
+				break
+			}
+			if field == "endDate" {
+				day = day.AddDate(0, 0, 1)
+			}
+			set[field] = day
+		}
+		if set["startDate"] == nil || set["endDate"] == nil {
+			dr.logger.Printf("Selection %s has no readable dates, leaving it as it is", id.Hex())
+			continue
+		}
+
+		update := bson.M{"$set": set}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+		if _, err := selCollection.UpdateOne(ctx, bson.M{"_id": id}, update); err != nil {
+			return fmt.Errorf("error migrating selection %s: %v", id.Hex(), err)
+		}
+		migrated++
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	dr.logger.Printf("Migrated dates of %d selections", migrated)
+	return nil
+}
+
+// legacyPhotoPrefix is where photos were served from before they went through the
+// routes of their ticket or inspection.
+const legacyPhotoPrefix = "/uploads/"
+
+// MigratePhotoPaths points the photo paths of tickets and inspections stored under
+// legacyPhotoPrefix at the routes that serve them now. Paths already pointing at
+// those routes are left alone, so the migration is safe to run more than once.
+func (dr *DormRepo) MigratePhotoPaths() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	legacy := bson.M{"$regex": "^" + legacyPhotoPrefix}
+	migrated := 0
+
+	tickets := OpenCollection(dr.cli, "tickets")
+	cursor, err := tickets.Find(ctx, bson.M{"photos": legacy})
+	if err != nil {
+		return fmt.Errorf("error querying tickets: %v", err)
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var ticket models.Ticket
+		if err := cursor.Decode(&ticket); err != nil {
+			return fmt.Errorf("error decoding ticket: %v", err)
+		}
+		for i, photo := range ticket.Photos {
+			if strings.HasPrefix(photo, legacyPhotoPrefix) {
+				ticket.Photos[i] = models.TicketPhotoPath(ticket.Id, path.Base(photo))
+			}
+		}
+		if _, err := tickets.UpdateOne(ctx, bson.M{"_id": ticket.Id}, bson.M{"$set": bson.M{"photos": ticket.Photos}}); err != nil {
+			return fmt.Errorf("error migrating photos of ticket %s: %v", ticket.Id.Hex(), err)
+		}
+		migrated++
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	inspections := OpenCollection(dr.cli, "inspections")
+	cursor, err = inspections.Find(ctx, bson.M{"items.photos": legacy})
+	if err != nil {
+		return fmt.Errorf("error querying inspections: %v", err)
+	}
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+		var inspection models.Inspection
+		if err := cursor.Decode(&inspection); err != nil {
+			return fmt.Errorf("error decoding inspection: %v", err)
+		}
+		set := bson.M{}
+		for i, item := range inspection.Items {
+			for j, photo := range item.Photos {
+				if strings.HasPrefix(photo, legacyPhotoPrefix) {
+					item.Photos[j] = models.InspectionPhotoPath(inspection.Id, path.Base(photo))
+					set[fmt.Sprintf("items.%d.photos", i)] = item.Photos
+				}
+			}
+		}
+		if _, err := inspections.UpdateOne(ctx, bson.M{"_id": inspection.Id}, bson.M{"$set": set}); err != nil {
+			return fmt.Errorf("error migrating photos of inspection %s: %v", inspection.Id.Hex(), err)
+		}
+		migrated++
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	dr.logger.Printf("Migrated photo paths of %d tickets and inspections", migrated)
+	return nil
+}
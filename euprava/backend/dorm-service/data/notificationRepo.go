@@ -0,0 +1,66 @@
+package data
+
+import (
+	"context"
+	"dorm-service/models"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Notify stores a notification for a student.
+func (dr *DormRepo) Notify(studentId primitive.ObjectID, title string, content string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	notification := models.Notification{
+		Id:        primitive.NewObjectID(),
+		StudentId: studentId,
+		Title:     title,
+		Content:   content,
+		CreatedAt: time.Now(),
+	}
+	_, err := OpenCollection(dr.cli, "notifications").InsertOne(ctx, &notification)
+	if err != nil {
+		return fmt.Errorf("error inserting notification: %v", err)
+	}
+	return nil
+}
+
+func (dr *DormRepo) GetNotifications(studentId primitive.ObjectID) ([]*models.Notification, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	notifications := []*models.Notification{}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := OpenCollection(dr.cli, "notifications").Find(ctx, bson.M{"studentId": studentId}, opts)
+	if err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	if err = cursor.All(ctx, &notifications); err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (dr *DormRepo) MarkNotificationRead(id primitive.ObjectID, studentId primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	result, err := OpenCollection(dr.cli, "notifications").UpdateOne(ctx,
+		bson.M{"_id": id, "studentId": studentId},
+		bson.M{"$set": bson.M{"read": true}},
+	)
+	if err != nil {
+		return fmt.Errorf("error updating notification: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("no notification found with id: %s", id.Hex())
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package data
+
+import (
+	"context"
+	"dorm-service/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrParcelNotFound    = errors.New("parcel not found")
+	ErrParcelNotAtDesk   = errors.New("parcel is no longer at the desk")
+	ErrWrongPickupCode   = errors.New("wrong pickup code")
+	ErrPickupCodeBlocked = errors.New("too many wrong pickup codes, a new code has to be issued")
+)
+
+func (dr *DormRepo) EnsureParcelIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	_, err := OpenCollection(dr.cli, "parcels").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "buildingId", Value: 1}, {Key: "status", Value: 1}, {Key: "registeredAt", Value: 1}},
+			Options: options.Index().SetName("building_status_registered"),
+		},
+		{
+			Keys:    bson.D{{Key: "studentId", Value: 1}, {Key: "registeredAt", Value: -1}},
+			Options: options.Index().SetName("student_registered"),
+		},
+	})
+	return err
+}
+
+func (dr *DormRepo) InsertParcel(parcel *models.Parcel) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	parcel.Status = models.ParcelAtDesk
+	parcel.RegisteredAt = time.Now()
+	_, err := OpenCollection(dr.cli, "parcels").InsertOne(ctx, parcel)
+	if err != nil {
+		return fmt.Errorf("error inserting parcel: %v", err)
+	}
+	return nil
+}
+
+func (dr *DormRepo) GetParcel(id string) (*models.Parcel, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	parcelId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid parcel ID", ErrParcelNotFound)
+	}
+
+	var parcel models.Parcel
+	err = OpenCollection(dr.cli, "parcels").FindOne(ctx, bson.M{"_id": parcelId}).Decode(&parcel)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrParcelNotFound
+		}
+		return nil, err
+	}
+	return &parcel, nil
+}
+
+// GetParcels returns the parcels matching the filter, oldest first.
+func (dr *DormRepo) GetParcels(filter bson.M) ([]*models.Parcel, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	parcels := []*models.Parcel{}
+	opts := options.Find().SetSort(bson.D{{Key: "registeredAt", Value: 1}})
+	cursor, err := OpenCollection(dr.cli, "parcels").Find(ctx, filter, opts)
+	if err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	if err = cursor.All(ctx, &parcels); err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	return parcels, nil
+}
+
+// HandOverParcel marks a parcel as picked up if codeHash matches its pickup code.
+// A wrong code counts as a failed attempt; after MaxPickupAttempts the code stops
+// working. The code can only be used once because the parcel leaves AtDesk.
+func (dr *DormRepo) HandOverParcel(parcelId primitive.ObjectID, codeHash string, handedOverBy string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	parcelsCollection := OpenCollection(dr.cli, "parcels")
+	now := time.Now()
+	result, err := parcelsCollection.UpdateOne(
+		ctx,
+		bson.M{
+			"_id":            parcelId,
+			"status":         models.ParcelAtDesk,
+			"pickupCodeHash": codeHash,
+			"failedAttempts": bson.M{"$lt": models.MaxPickupAttempts},
+		},
+		bson.M{
+			"$set":   bson.M{"status": models.ParcelPickedUp, "pickedUpAt": now, "handedOverBy": handedOverBy},
+			"$unset": bson.M{"pickupCodeHash": ""},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("error handing over parcel: %v", err)
+	}
+	if result.MatchedCount == 1 {
+		return nil
+	}
+
+	parcel, err := dr.GetParcel(parcelId.Hex())
+	if err != nil {
+		return err
+	}
+	if parcel.Status != models.ParcelAtDesk {
+		return ErrParcelNotAtDesk
+	}
+	if parcel.FailedAttempts >= models.MaxPickupAttempts {
+		return ErrPickupCodeBlocked
+	}
+	_, err = parcelsCollection.UpdateOne(ctx,
+		bson.M{"_id": parcelId, "status": models.ParcelAtDesk},
+		bson.M{"$inc": bson.M{"failedAttempts": 1}},
+	)
+	if err != nil {
+		return fmt.Errorf("error updating parcel: %v", err)
+	}
+	return ErrWrongPickupCode
+}
+
+// ResetPickupCode replaces the pickup code of a parcel at the desk and clears the
+// failed attempts.
+func (dr *DormRepo) ResetPickupCode(parcelId primitive.ObjectID, codeHash string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	result, err := OpenCollection(dr.cli, "parcels").UpdateOne(ctx,
+		bson.M{"_id": parcelId, "status": models.ParcelAtDesk},
+		bson.M{"$set": bson.M{"pickupCodeHash": codeHash, "failedAttempts": 0}},
+	)
+	if err != nil {
+		return fmt.Errorf("error updating parcel: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrParcelNotAtDesk
+	}
+	return nil
+}
+
+// ReturnParcel records that an uncollected parcel was sent back to the sender.
+func (dr *DormRepo) ReturnParcel(parcelId primitive.ObjectID, returnedBy string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	result, err := OpenCollection(dr.cli, "parcels").UpdateOne(ctx,
+		bson.M{"_id": parcelId, "status": models.ParcelAtDesk},
+		bson.M{
+			"$set":   bson.M{"status": models.ParcelReturned, "handedOverBy": returnedBy},
+			"$unset": bson.M{"pickupCodeHash": ""},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("error updating parcel: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrParcelNotAtDesk
+	}
+	return nil
+}
+
+// FlagUncollectedParcels flags parcels registered before the cutoff that are still
+// at the desk and returns the newly flagged ones.
+func (dr *DormRepo) FlagUncollectedParcels(cutoff time.Time) ([]*models.Parcel, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	parcels, err := dr.GetParcels(bson.M{
+		"status":       models.ParcelAtDesk,
+		"flagged":      false,
+		"registeredAt": bson.M{"$lt": cutoff},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	flagged := []*models.Parcel{}
+	now := time.Now()
+	for _, parcel := range parcels {
+		result, err := OpenCollection(dr.cli, "parcels").UpdateOne(ctx,
+			bson.M{"_id": parcel.Id, "status": models.ParcelAtDesk, "flagged": false},
+			bson.M{"$set": bson.M{"flagged": true, "flaggedAt": now}},
+		)
+		if err != nil {
+			return flagged, fmt.Errorf("error flagging parcel: %v", err)
+		}
+		if result.ModifiedCount == 1 {
+			parcel.Flagged = true
+			parcel.FlaggedAt = &now
+			flagged = append(flagged, parcel)
+		}
+	}
+	return flagged, nil
+}
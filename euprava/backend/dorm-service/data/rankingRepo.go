@@ -0,0 +1,68 @@
+package data
+
+import (
+	"context"
+	"dorm-service/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrRankingNotPublished = errors.New("the ranking list has not been published yet")
+
+// EnsureRankingIndexes makes sure a selection has one published ranking.
+func (dr *DormRepo) EnsureRankingIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	_, err := OpenCollection(dr.cli, "rankings").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "selectionId", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("uniq_selection"),
+	})
+	return err
+}
+
+// SaveRanking publishes a ranking, replacing the one published for the selection before.
+func (dr *DormRepo) SaveRanking(ranking *models.Ranking) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	ranking.Id = primitive.NewObjectID()
+	var existing models.Ranking
+	err := OpenCollection(dr.cli, "rankings").FindOne(ctx, bson.M{"selectionId": ranking.SelectionId}).Decode(&existing)
+	if err == nil {
+		ranking.Id = existing.Id
+	} else if err != mongo.ErrNoDocuments {
+		return err
+	}
+	_, err = OpenCollection(dr.cli, "rankings").ReplaceOne(
+		ctx,
+		bson.M{"selectionId": ranking.SelectionId},
+		ranking,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("error saving ranking: %v", err)
+	}
+	return nil
+}
+
+func (dr *DormRepo) GetRanking(selectionId primitive.ObjectID) (*models.Ranking, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	var ranking models.Ranking
+	err := OpenCollection(dr.cli, "rankings").FindOne(ctx, bson.M{"selectionId": selectionId}).Decode(&ranking)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrRankingNotPublished
+		}
+		return nil, err
+	}
+	return &ranking, nil
+}
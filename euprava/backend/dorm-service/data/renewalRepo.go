@@ -0,0 +1,182 @@
+package data
+
+import (
+	"context"
+	"dorm-service/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrCampaignNotFound = errors.New("renewal campaign not found")
+	ErrRenewalExists    = errors.New("place has already been renewed")
+	ErrRenewalNotFound  = errors.New("renewal not found")
+)
+
+// EnsureRenewalIndexes makes sure a student renews at most once per campaign.
+func (dr *DormRepo) EnsureRenewalIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	_, err := OpenCollection(dr.cli, "renewals").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "campaignId", Value: 1}, {Key: "studentId", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("uniq_campaign_student").
+				SetPartialFilterExpression(bson.M{"status": models.RenewalRenewed}),
+		},
+		{
+			Keys:    bson.D{{Key: "campaignId", Value: 1}, {Key: "buildingId", Value: 1}},
+			Options: options.Index().SetName("campaign_building"),
+		},
+	})
+	return err
+}
+
+func (dr *DormRepo) InsertRenewalCampaign(campaign *models.RenewalCampaign) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	campaign.Id = primitive.NewObjectID()
+	campaign.CreatedAt = time.Now()
+	_, err := OpenCollection(dr.cli, "renewalCampaigns").InsertOne(ctx, campaign)
+	if err != nil {
+		return fmt.Errorf("error inserting renewal campaign: %v", err)
+	}
+	return nil
+}
+
+func (dr *DormRepo) GetRenewalCampaign(id string) (*models.RenewalCampaign, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	campaignId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid campaign ID", ErrCampaignNotFound)
+	}
+
+	var campaign models.RenewalCampaign
+	err = OpenCollection(dr.cli, "renewalCampaigns").FindOne(ctx, bson.M{"_id": campaignId}).Decode(&campaign)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrCampaignNotFound
+		}
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+// GetRenewalCampaigns returns the campaigns matching the filter, latest first.
+func (dr *DormRepo) GetRenewalCampaigns(filter bson.M) ([]*models.RenewalCampaign, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	campaigns := []*models.RenewalCampaign{}
+	opts := options.Find().SetSort(bson.D{{Key: "opensAt", Value: -1}})
+	cursor, err := OpenCollection(dr.cli, "renewalCampaigns").Find(ctx, filter, opts)
+	if err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	if err = cursor.All(ctx, &campaigns); err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	return campaigns, nil
+}
+
+func (dr *DormRepo) InsertRenewal(renewal *models.Renewal) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	renewal.Id = primitive.NewObjectID()
+	renewal.Status = models.RenewalRenewed
+	renewal.RenewedAt = time.Now()
+	_, err := OpenCollection(dr.cli, "renewals").InsertOne(ctx, renewal)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrRenewalExists
+		}
+		return fmt.Errorf("error inserting renewal: %v", err)
+	}
+	return nil
+}
+
+func (dr *DormRepo) GetRenewal(id string) (*models.Renewal, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	renewalId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid renewal ID", ErrRenewalNotFound)
+	}
+
+	var renewal models.Renewal
+	err = OpenCollection(dr.cli, "renewals").FindOne(ctx, bson.M{"_id": renewalId}).Decode(&renewal)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrRenewalNotFound
+		}
+		return nil, err
+	}
+	return &renewal, nil
+}
+
+func (dr *DormRepo) GetRenewals(filter bson.M) ([]*models.Renewal, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	renewals := []*models.Renewal{}
+	opts := options.Find().SetSort(bson.D{{Key: "renewedAt", Value: 1}})
+	cursor, err := OpenCollection(dr.cli, "renewals").Find(ctx, filter, opts)
+	if err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	if err = cursor.All(ctx, &renewals); err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	return renewals, nil
+}
+
+// CancelRenewal gives a renewed place back to the selection.
+func (dr *DormRepo) CancelRenewal(renewalId primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	result, err := OpenCollection(dr.cli, "renewals").UpdateOne(ctx,
+		bson.M{"_id": renewalId, "status": models.RenewalRenewed},
+		bson.M{"$set": bson.M{"status": models.RenewalCancelled, "cancelledAt": time.Now()}},
+	)
+	if err != nil {
+		return fmt.Errorf("error updating renewal: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrRenewalNotFound
+	}
+	return nil
+}
+
+// SelectionRenewals returns the places renewed in the campaigns that precede a
+// selection, and whether any campaign precedes it at all.
+func (dr *DormRepo) SelectionRenewals(selectionId primitive.ObjectID) ([]*models.Renewal, bool, error) {
+	campaigns, err := dr.GetRenewalCampaigns(bson.M{"selectionId": selectionId})
+	if err != nil {
+		return nil, false, err
+	}
+	if len(campaigns) == 0 {
+		return []*models.Renewal{}, false, nil
+	}
+	campaignIds := make([]primitive.ObjectID, 0, len(campaigns))
+	for _, campaign := range campaigns {
+		campaignIds = append(campaignIds, campaign.Id)
+	}
+	renewals, err := dr.GetRenewals(bson.M{"campaignId": bson.M{"$in": campaignIds}, "status": models.RenewalRenewed})
+	return renewals, true, err
+}
@@ -0,0 +1,222 @@
+package data
+
+import (
+	"context"
+	"dorm-service/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrAlreadyCheckedIn = errors.New("student is already checked in")
+	ErrNotCheckedIn     = errors.New("student is not checked in")
+)
+
+// EnsureResidencyIndexes makes sure a student has at most one active residency and
+// creates the indexes used by the per student and per room history.
+func (dr *DormRepo) EnsureResidencyIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	_, err := OpenCollection(dr.cli, "residencies").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "studentId", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("uniq_active_residency").
+				SetPartialFilterExpression(bson.M{"active": true}),
+		},
+		{
+			Keys:    bson.D{{Key: "studentId", Value: 1}, {Key: "from", Value: -1}},
+			Options: options.Index().SetName("student_from"),
+		},
+		{
+			Keys:    bson.D{{Key: "buildingId", Value: 1}, {Key: "roomNumber", Value: 1}, {Key: "from", Value: -1}},
+			Options: options.Index().SetName("building_room_from"),
+		},
+	})
+	return err
+}
+
+// CheckIn opens a residency for a student. A student that was already assigned to
+// the room only gets the residency record; otherwise student is also put into a free
+// bed of the room, in the same transaction.
+func (dr *DormRepo) CheckIn(residency *models.Residency, student *models.Student) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	room, err := dr.GetRoom(residency.RoomNumber, residency.BuildingId.Hex())
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRoomNotFound, err)
+	}
+	assigned := room.HasStudent(residency.StudentId)
+	if !assigned {
+		if student == nil {
+			return fmt.Errorf("%w: no student data to put into the room", ErrStudentNotInRoom)
+		}
+		if _, _, err := dr.FindStudentRoom(residency.StudentId); err == nil {
+			return ErrStudentHasRoom
+		} else if err != ErrStudentNotInRoom {
+			return err
+		}
+		if room.Occupancy() >= room.Capacity {
+			return ErrRoomFull
+		}
+		student.AssignedDorm = residency.BuildingId.Hex()
+	}
+
+	residency.Id = primitive.NewObjectID()
+	residency.Active = true
+
+	return dr.inTransaction(ctx, func(sc mongo.SessionContext) error {
+		if !assigned {
+			if err := dr.pushStudent(sc, residency.BuildingId, room, student); err != nil {
+				return err
+			}
+		}
+		if _, err := OpenCollection(dr.cli, "residencies").InsertOne(sc, residency); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return ErrAlreadyCheckedIn
+			}
+			return err
+		}
+		return nil
+	})
+}
+
+// CheckOut closes the student's active residency and frees their bed in one
+// transaction.
+func (dr *DormRepo) CheckOut(studentId primitive.ObjectID, at time.Time, reason string, checkedOutBy string) (*models.Residency, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	residency, err := dr.GetActiveResidency(studentId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = dr.inTransaction(ctx, func(sc mongo.SessionContext) error {
+		if err := dr.pullStudent(sc, residency.BuildingId, residency.RoomNumber, studentId); err != nil {
+			return err
+		}
+		return dr.closeResidency(sc, residency.Id, at, reason, checkedOutBy)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	residency.Active = false
+	residency.To = &at
+	residency.Reason = reason
+	residency.CheckedOutBy = checkedOutBy
+	return residency, nil
+}
+
+func (dr *DormRepo) closeResidency(ctx context.Context, residencyId primitive.ObjectID, at time.Time, reason string, by string) error {
+	result, err := OpenCollection(dr.cli, "residencies").UpdateOne(
+		ctx,
+		bson.M{"_id": residencyId, "active": true},
+		bson.M{"$set": bson.M{"active": false, "to": at, "reason": reason, "checkedOutBy": by}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotCheckedIn
+	}
+	return nil
+}
+
+// moveResidency closes the student's active residency and opens one for the room
+// they were moved to. Students who never checked in have nothing to move. It runs
+// inside the transaction of the move.
+func (dr *DormRepo) moveResidency(ctx context.Context, studentId primitive.ObjectID, toBuildingId primitive.ObjectID, toRoomNumber int, reason string, movedBy string) error {
+	var current models.Residency
+	err := OpenCollection(dr.cli, "residencies").FindOne(ctx, bson.M{"studentId": studentId, "active": true}).Decode(&current)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if err := dr.closeResidency(ctx, current.Id, now, reason, movedBy); err != nil {
+		return err
+	}
+	_, err = OpenCollection(dr.cli, "residencies").InsertOne(ctx, &models.Residency{
+		Id:          primitive.NewObjectID(),
+		StudentId:   studentId,
+		StudentName: current.StudentName,
+		BuildingId:  toBuildingId,
+		RoomNumber:  toRoomNumber,
+		From:        now,
+		PlannedTo:   current.PlannedTo,
+		Active:      true,
+		CheckedInBy: movedBy,
+	})
+	return err
+}
+
+func (dr *DormRepo) GetActiveResidency(studentId primitive.ObjectID) (*models.Residency, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	var residency models.Residency
+	err := OpenCollection(dr.cli, "residencies").FindOne(ctx, bson.M{"studentId": studentId, "active": true}).Decode(&residency)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrNotCheckedIn
+		}
+		return nil, err
+	}
+	return &residency, nil
+}
+
+// GetResidencies returns the residencies matching the filter, most recent first.
+func (dr *DormRepo) GetResidencies(filter bson.M) ([]*models.Residency, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	residencies := []*models.Residency{}
+	opts := options.Find().SetSort(bson.D{{Key: "from", Value: -1}})
+	cursor, err := OpenCollection(dr.cli, "residencies").Find(ctx, filter, opts)
+	if err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	if err = cursor.All(ctx, &residencies); err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	return residencies, nil
+}
+
+// SetPlannedCheckOut records when a resident is expected to leave. A nil date clears it.
+func (dr *DormRepo) SetPlannedCheckOut(studentId primitive.ObjectID, at *time.Time) (*models.Residency, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{"plannedTo": at}}
+	if at == nil {
+		update = bson.M{"$unset": bson.M{"plannedTo": ""}}
+	}
+	var residency models.Residency
+	err := OpenCollection(dr.cli, "residencies").FindOneAndUpdate(
+		ctx,
+		bson.M{"studentId": studentId, "active": true},
+		update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&residency)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrNotCheckedIn
+		}
+		return nil, err
+	}
+	return &residency, nil
+}
@@ -0,0 +1,221 @@
+package data
+
+import (
+	"context"
+	"dorm-service/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrRoomFull          = errors.New("room is full")
+	ErrStudentNotInRoom  = errors.New("student is not assigned to any room")
+	ErrStudentHasRoom    = errors.New("student is already assigned to a room")
+	ErrRoomChangedOrFull = errors.New("room is full or was changed in the meantime, try again")
+)
+
+// roomWithSpace matches a room with the given number that still has a free bed,
+// assuming its capacity has not changed since it was read.
+func roomWithSpace(room *models.Room) bson.M {
+	return bson.M{"$elemMatch": bson.M{
+		"room_number": room.Room_Number,
+		"capacity":    room.Capacity,
+		fmt.Sprintf("students.%d", room.Capacity-1): bson.M{"$exists": false},
+	}}
+}
+
+// FindStudentRoom returns the building and room the student currently lives in.
+func (dr *DormRepo) FindStudentRoom(studentId primitive.ObjectID) (*models.Building, *models.Room, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	buildingCollection := OpenCollection(dr.cli, "buildings")
+
+	var building models.Building
+	err := buildingCollection.FindOne(ctx, bson.M{"rooms.students.user._id": studentId}).Decode(&building)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil, ErrStudentNotInRoom
+		}
+		return nil, nil, err
+	}
+	for _, room := range building.Rooms {
+		if room.HasStudent(studentId) {
+			return &building, room, nil
+		}
+	}
+	return nil, nil, ErrStudentNotInRoom
+}
+
+// AddStudentToRoom puts a student into a free bed of a room.
+func (dr *DormRepo) AddStudentToRoom(buildingId primitive.ObjectID, roomNumber int, student *models.Student) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	if _, _, err := dr.FindStudentRoom(student.ID); err == nil {
+		return ErrStudentHasRoom
+	} else if err != ErrStudentNotInRoom {
+		return err
+	}
+
+	room, err := dr.GetRoom(roomNumber, buildingId.Hex())
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRoomNotFound, err)
+	}
+	if room.Occupancy() >= room.Capacity {
+		return ErrRoomFull
+	}
+	student.AssignedDorm = buildingId.Hex()
+
+	return dr.pushStudent(ctx, buildingId, room, student)
+}
+
+// RemoveStudentFromRoom takes a student out of the room they live in.
+func (dr *DormRepo) RemoveStudentFromRoom(buildingId primitive.ObjectID, roomNumber int, studentId primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	return dr.pullStudent(ctx, buildingId, roomNumber, studentId)
+}
+
+// pushStudent puts a student into a free bed of a room in a single update.
+func (dr *DormRepo) pushStudent(ctx context.Context, buildingId primitive.ObjectID, room *models.Room, student *models.Student) error {
+	result, err := OpenCollection(dr.cli, "buildings").UpdateOne(
+		ctx,
+		bson.M{"_id": buildingId, "rooms": roomWithSpace(room)},
+		bson.M{"$push": bson.M{"rooms.$[room].students": student}},
+		options.Update().SetArrayFilters(options.ArrayFilters{
+			Filters: []interface{}{bson.M{"room.room_number": room.Room_Number}},
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("error updating room: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrRoomChangedOrFull
+	}
+	return nil
+}
+
+// pullStudent takes a student out of a room in a single update.
+func (dr *DormRepo) pullStudent(ctx context.Context, buildingId primitive.ObjectID, roomNumber int, studentId primitive.ObjectID) error {
+	result, err := OpenCollection(dr.cli, "buildings").UpdateOne(
+		ctx,
+		bson.M{"_id": buildingId, "rooms": bson.M{"$elemMatch": bson.M{
+			"room_number":       roomNumber,
+			"students.user._id": studentId,
+		}}},
+		bson.M{"$pull": bson.M{"rooms.$[room].students": bson.M{"user._id": studentId}}},
+		options.Update().SetArrayFilters(options.ArrayFilters{
+			Filters: []interface{}{bson.M{"room.room_number": roomNumber}},
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("error updating room: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrStudentNotInRoom
+	}
+	return nil
+}
+
+// DeleteRoom removes an empty room from a building.
+func (dr *DormRepo) DeleteRoom(buildingId primitive.ObjectID, roomNumber int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	buildingCollection := OpenCollection(dr.cli, "buildings")
+	result, err := buildingCollection.UpdateOne(
+		ctx,
+		bson.M{"_id": buildingId, "rooms": bson.M{"$elemMatch": bson.M{
+			"room_number": roomNumber,
+			"students.0":  bson.M{"$exists": false},
+		}}},
+		bson.M{"$pull": bson.M{"rooms": bson.M{"room_number": roomNumber}}},
+	)
+	if err != nil {
+		return fmt.Errorf("error deleting room: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		room, err := dr.GetRoom(roomNumber, buildingId.Hex())
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrRoomNotFound, err)
+		}
+		return fmt.Errorf("%w: room #%d still has %d occupants", ErrRoomConflict, roomNumber, room.Occupancy())
+	}
+	return nil
+}
+
+// MoveStudent moves a student from their current room into another one, possibly in
+// another building. The rooms and the student's residency history are updated in one
+// transaction so the student is never in both rooms or in neither.
+func (dr *DormRepo) MoveStudent(studentId primitive.ObjectID, toBuildingId primitive.ObjectID, toRoomNumber int, movedBy string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	fromBuilding, fromRoom, err := dr.FindStudentRoom(studentId)
+	if err != nil {
+		return err
+	}
+	if fromBuilding.Id == toBuildingId && fromRoom.Room_Number == toRoomNumber {
+		return fmt.Errorf("%w: student already lives in room #%d", ErrRoomConflict, toRoomNumber)
+	}
+
+	toRoom, err := dr.GetRoom(toRoomNumber, toBuildingId.Hex())
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRoomNotFound, err)
+	}
+	if toRoom.Occupancy() >= toRoom.Capacity {
+		return ErrRoomFull
+	}
+
+	var student models.Student
+	for _, s := range *fromRoom.Students {
+		if s != nil && s.ID == studentId {
+			student = *s
+			break
+		}
+	}
+	student.AssignedDorm = toBuildingId.Hex()
+
+	reason := fmt.Sprintf("moved to room #%d", toRoomNumber)
+	return dr.inTransaction(ctx, func(sc mongo.SessionContext) error {
+		if fromBuilding.Id == toBuildingId {
+			result, err := OpenCollection(dr.cli, "buildings").UpdateOne(
+				sc,
+				bson.M{"_id": toBuildingId, "$and": bson.A{
+					bson.M{"rooms": bson.M{"$elemMatch": bson.M{"room_number": fromRoom.Room_Number, "students.user._id": studentId}}},
+					bson.M{"rooms": roomWithSpace(toRoom)},
+				}},
+				bson.M{
+					"$pull": bson.M{"rooms.$[src].students": bson.M{"user._id": studentId}},
+					"$push": bson.M{"rooms.$[dst].students": &student},
+				},
+				options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+					bson.M{"src.room_number": fromRoom.Room_Number},
+					bson.M{"dst.room_number": toRoomNumber},
+				}}),
+			)
+			if err != nil {
+				return fmt.Errorf("error moving student: %v", err)
+			}
+			if result.MatchedCount == 0 {
+				return ErrRoomChangedOrFull
+			}
+		} else {
+			if err := dr.pullStudent(sc, fromBuilding.Id, fromRoom.Room_Number, studentId); err != nil {
+				return err
+			}
+			if err := dr.pushStudent(sc, toBuildingId, toRoom, &student); err != nil {
+				return err
+			}
+		}
+		return dr.moveResidency(sc, studentId, toBuildingId, toRoomNumber, reason, movedBy)
+	})
+}
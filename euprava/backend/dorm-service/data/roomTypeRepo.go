@@ -0,0 +1,135 @@
+package data
+
+import (
+	"context"
+	"dorm-service/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrRoomTypeNotFound = errors.New("room type not found")
+	ErrRoomTypeInUse    = errors.New("room type is still used by rooms")
+)
+
+func (dr *DormRepo) InsertRoomType(roomType *models.RoomType) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	typesCollection := OpenCollection(dr.cli, "room_types")
+	roomType.Id = primitive.NewObjectID()
+	if roomType.Amenities == nil {
+		roomType.Amenities = []string{}
+	}
+	_, err := typesCollection.InsertOne(ctx, roomType)
+	if err != nil {
+		return fmt.Errorf("error inserting room type: %v", err)
+	}
+	return nil
+}
+
+func (dr *DormRepo) GetRoomTypes() (models.RoomTypes, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	typesCollection := OpenCollection(dr.cli, "room_types")
+
+	roomTypes := models.RoomTypes{}
+	cursor, err := typesCollection.Find(ctx, bson.M{})
+	if err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	if err = cursor.All(ctx, &roomTypes); err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	return roomTypes, nil
+}
+
+func (dr *DormRepo) GetRoomType(id primitive.ObjectID) (*models.RoomType, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	typesCollection := OpenCollection(dr.cli, "room_types")
+
+	var roomType models.RoomType
+	err := typesCollection.FindOne(ctx, bson.M{"_id": id}).Decode(&roomType)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrRoomTypeNotFound
+		}
+		return nil, err
+	}
+	return &roomType, nil
+}
+
+func (dr *DormRepo) UpdateRoomType(id primitive.ObjectID, roomType *models.RoomType) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	typesCollection := OpenCollection(dr.cli, "room_types")
+	if roomType.Amenities == nil {
+		roomType.Amenities = []string{}
+	}
+
+	result, err := typesCollection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{
+		"name":         roomType.Name,
+		"description":  roomType.Description,
+		"amenities":    roomType.Amenities,
+		"monthlyPrice": roomType.MonthlyPrice,
+	}})
+	if err != nil {
+		return fmt.Errorf("error updating room type: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrRoomTypeNotFound
+	}
+	return nil
+}
+
+// DeleteRoomType removes a room type that no room refers to any more.
+func (dr *DormRepo) DeleteRoomType(id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	buildingCollection := OpenCollection(dr.cli, "buildings")
+	used, err := buildingCollection.CountDocuments(ctx, bson.M{"rooms.roomTypeId": id})
+	if err != nil {
+		return fmt.Errorf("error checking room type usage: %v", err)
+	}
+	if used > 0 {
+		return ErrRoomTypeInUse
+	}
+
+	typesCollection := OpenCollection(dr.cli, "room_types")
+	result, err := typesCollection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return fmt.Errorf("error deleting room type: %v", err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrRoomTypeNotFound
+	}
+	return nil
+}
+
+// SetApplicationPlacement stores the room an application's student was placed in.
+func (dr *DormRepo) SetApplicationPlacement(appId primitive.ObjectID, placement *models.RoomAssignment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	appsCollection := OpenCollection(dr.cli, "applications")
+	_, err := appsCollection.UpdateOne(ctx, bson.M{"_id": appId}, bson.M{"$set": bson.M{
+		"placement": placement,
+		"updatedAt": time.Now(),
+	}})
+	if err != nil {
+		return fmt.Errorf("error updating application placement: %v", err)
+	}
+	return nil
+}
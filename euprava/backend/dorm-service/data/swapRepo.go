@@ -0,0 +1,201 @@
+package data
+
+import (
+	"context"
+	"dorm-service/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrSwapNotFound     = errors.New("room swap not found")
+	ErrSwapExists       = errors.New("you already have an open room swap")
+	ErrSwapChanged      = errors.New("room swap status has changed")
+	ErrSwapRoomsChanged = errors.New("one of the students no longer lives in the room of the swap")
+)
+
+// EnsureSwapIndexes makes sure a student has at most one open swap proposal.
+func (dr *DormRepo) EnsureSwapIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	_, err := OpenCollection(dr.cli, "roomSwaps").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "requester.studentId", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("uniq_open_swap").
+				SetPartialFilterExpression(bson.M{"open": true}),
+		},
+		{
+			Keys:    bson.D{{Key: "partner.studentId", Value: 1}, {Key: "createdAt", Value: -1}},
+			Options: options.Index().SetName("partner_created"),
+		},
+	})
+	return err
+}
+
+func (dr *DormRepo) InsertRoomSwap(swap *models.RoomSwap) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	swap.Id = primitive.NewObjectID()
+	swap.Status = models.SwapProposed
+	swap.Open = true
+	swap.CreatedAt = time.Now()
+	_, err := OpenCollection(dr.cli, "roomSwaps").InsertOne(ctx, swap)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrSwapExists
+		}
+		return fmt.Errorf("error inserting room swap: %v", err)
+	}
+	return nil
+}
+
+func (dr *DormRepo) GetRoomSwap(id string) (*models.RoomSwap, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	swapId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid room swap ID", ErrSwapNotFound)
+	}
+
+	var swap models.RoomSwap
+	err = OpenCollection(dr.cli, "roomSwaps").FindOne(ctx, bson.M{"_id": swapId}).Decode(&swap)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrSwapNotFound
+		}
+		return nil, err
+	}
+	return &swap, nil
+}
+
+// GetRoomSwaps returns the swaps matching the filter, newest first.
+func (dr *DormRepo) GetRoomSwaps(filter bson.M) ([]*models.RoomSwap, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	swaps := []*models.RoomSwap{}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := OpenCollection(dr.cli, "roomSwaps").Find(ctx, filter, opts)
+	if err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	if err = cursor.All(ctx, &swaps); err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	return swaps, nil
+}
+
+// SetSwapStatus moves a swap from one status to another and sets the given fields
+// with it. Swaps stop being open once they are declined, rejected or cancelled.
+func (dr *DormRepo) SetSwapStatus(swapId primitive.ObjectID, from string, to string, set bson.M) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	return dr.setSwapStatus(ctx, swapId, from, to, set)
+}
+
+func (dr *DormRepo) setSwapStatus(ctx context.Context, swapId primitive.ObjectID, from string, to string, set bson.M) error {
+	if set == nil {
+		set = bson.M{}
+	}
+	set["status"] = to
+	set["open"] = to == models.SwapProposed || to == models.SwapAccepted
+
+	result, err := OpenCollection(dr.cli, "roomSwaps").UpdateOne(
+		ctx,
+		bson.M{"_id": swapId, "status": from},
+		bson.M{"$set": set},
+	)
+	if err != nil {
+		return fmt.Errorf("error updating room swap: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrSwapChanged
+	}
+	return nil
+}
+
+// ExecuteRoomSwap approves an accepted swap and moves both students into each
+// other's room in one transaction, closing and opening their residencies. It fails
+// with ErrSwapRoomsChanged if either student moved since the swap was proposed.
+func (dr *DormRepo) ExecuteRoomSwap(swap *models.RoomSwap, decidedBy string, note string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	students := map[primitive.ObjectID]*models.Student{}
+	for _, side := range []models.SwapSide{swap.Requester, swap.Partner} {
+		building, room, err := dr.FindStudentRoom(side.StudentId)
+		if err != nil {
+			return ErrSwapRoomsChanged
+		}
+		if building.Id != side.BuildingId || room.Room_Number != side.RoomNumber {
+			return ErrSwapRoomsChanged
+		}
+		for _, s := range *room.Students {
+			if s != nil && s.ID == side.StudentId {
+				students[side.StudentId] = s
+			}
+		}
+	}
+
+	return dr.inTransaction(ctx, func(sc mongo.SessionContext) error {
+		set := bson.M{"decidedBy": decidedBy, "decidedAt": time.Now(), "decisionNote": note}
+		if err := dr.setSwapStatus(sc, swap.Id, models.SwapAccepted, models.SwapApproved, set); err != nil {
+			return err
+		}
+
+		// Each student takes the other's place in the room's student list.
+		sides := [][2]models.SwapSide{{swap.Requester, swap.Partner}, {swap.Partner, swap.Requester}}
+		for _, pair := range sides {
+			leaving, arriving := pair[0], pair[1]
+			student := *students[arriving.StudentId]
+			student.AssignedDorm = leaving.BuildingId.Hex()
+			if err := dr.replaceStudent(sc, leaving, &student); err != nil {
+				return err
+			}
+		}
+
+		for _, pair := range sides {
+			moving, other := pair[0], pair[1]
+			reason := fmt.Sprintf("swapped rooms with %s", other.StudentName)
+			if err := dr.moveResidency(sc, moving.StudentId, other.BuildingId, other.RoomNumber, reason, decidedBy); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// replaceStudent gives the bed of side.StudentId in side's room to student.
+func (dr *DormRepo) replaceStudent(ctx context.Context, side models.SwapSide, student *models.Student) error {
+	result, err := OpenCollection(dr.cli, "buildings").UpdateOne(
+		ctx,
+		bson.M{"_id": side.BuildingId, "rooms": bson.M{"$elemMatch": bson.M{
+			"room_number":       side.RoomNumber,
+			"students.user._id": side.StudentId,
+		}}},
+		bson.M{"$set": bson.M{"rooms.$[r].students.$[s]": student}},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+			bson.M{"r.room_number": side.RoomNumber},
+			bson.M{"s.user._id": side.StudentId},
+		}}),
+	)
+	if err != nil {
+		return fmt.Errorf("error swapping students: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrSwapRoomsChanged
+	}
+	return nil
+}
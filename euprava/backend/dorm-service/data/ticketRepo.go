@@ -0,0 +1,179 @@
+package data
+
+import (
+	"context"
+	"dorm-service/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrTicketNotFound          = errors.New("ticket not found")
+	ErrInvalidTicketTransition = errors.New("invalid ticket status transition")
+)
+
+func (dr *DormRepo) EnsureTicketIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	_, err := OpenCollection(dr.cli, "tickets").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "buildingId", Value: 1}, {Key: "status", Value: 1}, {Key: "createdAt", Value: -1}},
+			Options: options.Index().SetName("building_status_created"),
+		},
+		{
+			Keys:    bson.D{{Key: "reportedBy", Value: 1}, {Key: "createdAt", Value: -1}},
+			Options: options.Index().SetName("reporter_created"),
+		},
+		{
+			Keys:    bson.D{{Key: "assignedTo", Value: 1}, {Key: "status", Value: 1}},
+			Options: options.Index().SetName("assignee_status"),
+		},
+	})
+	return err
+}
+
+func (dr *DormRepo) InsertTicket(ticket *models.Ticket) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	ticket.Id = primitive.NewObjectID()
+	ticket.Status = models.TicketOpen
+	ticket.Photos = []string{}
+	ticket.Comments = []models.TicketComment{}
+	ticket.History = []models.StatusChange{{
+		To:            models.TicketOpen,
+		ChangedBy:     ticket.ReportedBy,
+		ChangedByRole: ticket.ReporterRole,
+		ChangedAt:     now,
+	}}
+	ticket.CreatedAt = now
+	ticket.UpdatedAt = now
+
+	_, err := OpenCollection(dr.cli, "tickets").InsertOne(ctx, ticket)
+	if err != nil {
+		return fmt.Errorf("error inserting ticket: %v", err)
+	}
+	return nil
+}
+
+func (dr *DormRepo) GetTicket(id string) (*models.Ticket, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	ticketId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid ticket ID", ErrTicketNotFound)
+	}
+
+	var ticket models.Ticket
+	err = OpenCollection(dr.cli, "tickets").FindOne(ctx, bson.M{"_id": ticketId}).Decode(&ticket)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrTicketNotFound
+		}
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+// GetTickets returns the tickets matching the filter, newest first.
+func (dr *DormRepo) GetTickets(filter bson.M) ([]*models.Ticket, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	tickets := []*models.Ticket{}
+	opts := options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	cursor, err := OpenCollection(dr.cli, "tickets").Find(ctx, filter, opts)
+	if err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	if err = cursor.All(ctx, &tickets); err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	return tickets, nil
+}
+
+// UpdateTicketStatus moves a ticket to a new status and records the change in its
+// history. It only succeeds if the ticket is still in change.From, so concurrent
+// updates cannot skip a step of the workflow. Extra fields in set are updated too.
+func (dr *DormRepo) UpdateTicketStatus(ticketId primitive.ObjectID, change models.StatusChange, set bson.M) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	if !models.CanTransitionTicket(change.From, change.To) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTicketTransition, change.From, change.To)
+	}
+
+	fields := bson.M{"status": change.To, "updatedAt": change.ChangedAt}
+	for key, value := range set {
+		fields[key] = value
+	}
+	update := bson.M{
+		"$set":  fields,
+		"$push": bson.M{"history": change},
+	}
+	if change.To == models.TicketOpen {
+		update["$unset"] = bson.M{"resolvedAt": "", "assignedTo": "", "assignedToName": "", "assignedAt": ""}
+	}
+
+	result, err := OpenCollection(dr.cli, "tickets").UpdateOne(
+		ctx,
+		bson.M{"_id": ticketId, "status": change.From},
+		update,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating ticket: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%w: ticket is no longer %s", ErrInvalidTicketTransition, change.From)
+	}
+	return nil
+}
+
+func (dr *DormRepo) AddTicketComment(ticketId primitive.ObjectID, comment *models.TicketComment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	comment.Id = primitive.NewObjectID()
+	comment.CreatedAt = time.Now()
+	result, err := OpenCollection(dr.cli, "tickets").UpdateOne(
+		ctx,
+		bson.M{"_id": ticketId},
+		bson.M{"$push": bson.M{"comments": comment}, "$set": bson.M{"updatedAt": comment.CreatedAt}},
+	)
+	if err != nil {
+		return fmt.Errorf("error adding comment: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrTicketNotFound
+	}
+	return nil
+}
+
+func (dr *DormRepo) AddTicketPhoto(ticketId primitive.ObjectID, path string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	result, err := OpenCollection(dr.cli, "tickets").UpdateOne(
+		ctx,
+		bson.M{"_id": ticketId},
+		bson.M{"$push": bson.M{"photos": path}, "$set": bson.M{"updatedAt": time.Now()}},
+	)
+	if err != nil {
+		return fmt.Errorf("error adding photo: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrTicketNotFound
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package data
+
+import (
+	"context"
+	"dorm-service/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrVisitNotFound = errors.New("visit not found")
+	ErrVisitChanged  = errors.New("visit status has changed")
+)
+
+func (dr *DormRepo) EnsureVisitIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	_, err := OpenCollection(dr.cli, "visits").Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "buildingId", Value: 1}, {Key: "from", Value: 1}},
+			Options: options.Index().SetName("building_from"),
+		},
+		{
+			Keys:    bson.D{{Key: "studentId", Value: 1}, {Key: "overnight", Value: 1}, {Key: "from", Value: 1}},
+			Options: options.Index().SetName("student_overnight_from"),
+		},
+	})
+	return err
+}
+
+func (dr *DormRepo) InsertVisit(visit *models.Visit) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	visit.Id = primitive.NewObjectID()
+	visit.CreatedAt = time.Now()
+	_, err := OpenCollection(dr.cli, "visits").InsertOne(ctx, visit)
+	if err != nil {
+		return fmt.Errorf("error inserting visit: %v", err)
+	}
+	return nil
+}
+
+func (dr *DormRepo) GetVisit(id string) (*models.Visit, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	visitId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid visit ID", ErrVisitNotFound)
+	}
+
+	var visit models.Visit
+	err = OpenCollection(dr.cli, "visits").FindOne(ctx, bson.M{"_id": visitId}).Decode(&visit)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, ErrVisitNotFound
+		}
+		return nil, err
+	}
+	return &visit, nil
+}
+
+// GetVisits returns the visits matching the filter, earliest first.
+func (dr *DormRepo) GetVisits(filter bson.M) ([]*models.Visit, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	visits := []*models.Visit{}
+	opts := options.Find().SetSort(bson.D{{Key: "from", Value: 1}})
+	cursor, err := OpenCollection(dr.cli, "visits").Find(ctx, filter, opts)
+	if err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	if err = cursor.All(ctx, &visits); err != nil {
+		dr.logger.Println(err)
+		return nil, err
+	}
+	return visits, nil
+}
+
+func (dr *DormRepo) CountVisits(filter bson.M) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	return OpenCollection(dr.cli, "visits").CountDocuments(ctx, filter)
+}
+
+// SetVisitStatus moves a visit from one status to another and sets the given
+// fields with it. It fails with ErrVisitChanged if the visit is no longer in the
+// expected status.
+func (dr *DormRepo) SetVisitStatus(visitId primitive.ObjectID, from string, to string, set bson.M) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Second)
+	defer cancel()
+
+	if set == nil {
+		set = bson.M{}
+	}
+	set["status"] = to
+
+	result, err := OpenCollection(dr.cli, "visits").UpdateOne(
+		ctx,
+		bson.M{"_id": visitId, "status": from},
+		bson.M{"$set": set},
+	)
+	if err != nil {
+		return fmt.Errorf("error updating visit: %v", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrVisitChanged
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package documents
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	pageWidth  = 595.0
+	pageHeight = 842.0
+	margin     = 56.0
+)
+
+// PDF writes simple text documents, such as confirmations and statements, as PDF.
+// It only uses the standard Helvetica font, so characters outside of the Windows
+// Latin alphabet are transliterated.
+type PDF struct {
+	pages [][]line
+	y     float64
+}
+
+type line struct {
+	text string
+	size float64
+	bold bool
+	y    float64
+}
+
+func NewPDF() *PDF {
+	p := &PDF{}
+	p.newPage()
+	return p
+}
+
+func (p *PDF) newPage() {
+	p.pages = append(p.pages, nil)
+	p.y = pageHeight - margin
+}
+
+func (p *PDF) write(text string, size float64, bold bool) {
+	// Helvetica is roughly half as wide as it is high.
+	maxChars := int((pageWidth - 2*margin) / (size * 0.5))
+	for _, part := range wrap(text, maxChars) {
+		p.y -= size * 1.5
+		if p.y < margin {
+			p.newPage()
+			p.y -= size * 1.5
+		}
+		last := len(p.pages) - 1
+		p.pages[last] = append(p.pages[last], line{text: part, size: size, bold: bold, y: p.y})
+	}
+}
+
+// Title adds a large bold line.
+func (p *PDF) Title(text string) {
+	p.write(text, 16, true)
+}
+
+// Heading adds a bold line.
+func (p *PDF) Heading(text string) {
+	p.write(text, 12, true)
+}
+
+// Text adds a paragraph, wrapped to the page width.
+func (p *PDF) Text(text string) {
+	p.write(text, 10, false)
+}
+
+// Space adds an empty line.
+func (p *PDF) Space() {
+	p.y -= 10
+}
+
+// Bytes renders the document.
+func (p *PDF) Bytes() []byte {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+
+	// Every page takes two objects, the page and its content stream, after the
+	// catalog, the page tree and the two fonts.
+	kids := make([]string, len(p.pages))
+	for i := range p.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, lines := range p.pages {
+		var content bytes.Buffer
+		for _, l := range lines {
+			font := "F1"
+			if l.bold {
+				font = "F2"
+			}
+			fmt.Fprintf(&content, "BT /%s %.0f Tf %.0f %.0f Td (%s) Tj ET\n", font, l.size, margin, l.y, encode(l.text))
+		}
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
+
+func wrap(text string, maxChars int) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return []string{""}
+	}
+	var lines []string
+	current := words[0]
+	for _, word := range words[1:] {
+		if len([]rune(current))+1+len([]rune(word)) > maxChars {
+			lines = append(lines, current)
+			current = word
+			continue
+		}
+		current += " " + word
+	}
+	return append(lines, current)
+}
+
+// winAnsi maps the letters of the Serbian Latin alphabet that are not in Latin-1.
+var winAnsi = map[rune]string{
+	'š': "\x9a", 'Š': "\x8a", 'ž': "\x9e", 'Ž': "\x8e",
+	'č': "c", 'Č': "C", 'ć': "c", 'Ć': "C", 'đ': "dj", 'Đ': "Dj",
+	'–': "\x96", '—': "\x97", '€': "\x80",
+}
+
+// encode converts text to WinAnsi and escapes it for a PDF string.
+func encode(text string) string {
+	var sb strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r < 0x80:
+			sb.WriteRune(r)
+		case winAnsi[r] != "":
+			sb.WriteString(winAnsi[r])
+		case r >= 0xa0 && r <= 0xff:
+			sb.WriteByte(byte(r))
+		default:
+			sb.WriteByte('?')
+		}
+	}
+	return sb.String()
+}
@@ -5,12 +5,14 @@ import (
 	controllers "dorm-service/controllers"
 	"dorm-service/data"
 	helper "dorm-service/helpers"
+	"dorm-service/payments"
 	routes "dorm-service/routes"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/heroku/x/hmetrics/onload"
@@ -47,6 +49,64 @@ func main() {
 	defer store.DisconnectMongo(timeoutContext)
 	store.Ping()
 
+	if err := store.BackfillApplicationKeys(); err != nil {
+		logger.Println("Warning: application keys backfill failed:", err)
+	}
+	if err := store.EnsureApplicationIndexes(); err != nil {
+		logger.Println("Warning: cannot ensure application indexes:", err)
+	}
+	if err := store.EnsureAppealIndexes(); err != nil {
+		logger.Println("Warning: cannot ensure appeal indexes:", err)
+	}
+	if err := store.EnsureInvoiceIndexes(); err != nil {
+		logger.Println("Warning: cannot ensure invoice indexes:", err)
+	}
+	if err := store.EnsureDepositIndexes(); err != nil {
+		logger.Println("Warning: cannot ensure deposit indexes:", err)
+	}
+	if err := store.EnsureResidencyIndexes(); err != nil {
+		logger.Println("Warning: cannot ensure residency indexes:", err)
+	}
+	if err := store.EnsureTicketIndexes(); err != nil {
+		logger.Println("Warning: cannot ensure ticket indexes:", err)
+	}
+	if err := store.EnsureFacilityIndexes(); err != nil {
+		logger.Println("Warning: cannot ensure facility indexes:", err)
+	}
+	if err := store.EnsureParcelIndexes(); err != nil {
+		logger.Println("Warning: cannot ensure parcel indexes:", err)
+	}
+	if err := store.EnsureVisitIndexes(); err != nil {
+		logger.Println("Warning: cannot ensure visit indexes:", err)
+	}
+	if err := store.EnsureSwapIndexes(); err != nil {
+		logger.Println("Warning: cannot ensure room swap indexes:", err)
+	}
+	if err := store.EnsureRenewalIndexes(); err != nil {
+		logger.Println("Warning: cannot ensure renewal indexes:", err)
+	}
+	if err := store.EnsureIncidentIndexes(); err != nil {
+		logger.Println("Warning: cannot ensure incident indexes:", err)
+	}
+	if err := store.EnsureRankingIndexes(); err != nil {
+		logger.Println("Warning: cannot ensure ranking indexes:", err)
+	}
+	if err := store.EnsureManagerIndexes(); err != nil {
+		logger.Println("Warning: cannot ensure manager indexes:", err)
+	}
+	if err := store.EnsureSelectionIndexes(); err != nil {
+		logger.Println("Warning: cannot ensure selection indexes:", err)
+	}
+	if err := store.MigrateEmbeddedApplications(); err != nil {
+		logger.Println("Warning: embedded applications migration failed:", err)
+	}
+	if err := store.MigrateSelectionDates(); err != nil {
+		logger.Println("Warning: selection dates migration failed:", err)
+	}
+	if err := store.MigratePhotoPaths(); err != nil {
+		logger.Println("Warning: photo paths migration failed:", err)
+	}
+
 	helper.InitializeTokenHelper(store.GetClient())
 
 	if err != nil {
@@ -56,7 +116,31 @@ func main() {
 		logger.Fatal(err)
 	}
 
-	dormController := controllers.NewDormController(logger, store)
+	paymentProvider, err := payments.NewProvider()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	dormController := controllers.NewDormController(logger, store, paymentProvider)
+
+	// Monthly invoices and overdue reminders; both jobs are idempotent.
+	go func() {
+		dormController.RunBillingJobs()
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			dormController.RunBillingJobs()
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(15 * time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			dormController.RunNoShowJob()
+			dormController.RunParcelJob()
+		}
+	}()
 
 	routes.MainRoutes(router, *dormController)
 
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
@@ -36,7 +37,40 @@ func Authentication() gin.HandlerFunc {
 
 	}
 }
-func AuthorizeRoles(roles []string) gin.HandlerFunc {
+
+// ScopedRoles can only act on the resources the scopes of a route let them reach.
+// Every other role listed on a route has full access to it.
+var ScopedRoles = map[string]bool{
+	"MANAGER": true,
+}
+
+// Scope reports whether the user may act on the resource a request is about, for
+// example whether a building manager manages the building of a ticket.
+type Scope func(c *gin.Context, uid string) (bool, error)
+
+// notFoundError marks a scope error about a resource that does not exist.
+type notFoundError struct {
+	err error
+}
+
+func (e notFoundError) Error() string { return e.err.Error() }
+
+func (e notFoundError) Unwrap() error { return e.err }
+
+// NotFound marks err as a missing resource, which AuthorizeRoles answers with 404.
+// Any other scope error is answered with 500.
+func NotFound(err error) error {
+	return notFoundError{err: err}
+}
+
+// Own is the scope of routes that only reach the user's own data.
+func Own(c *gin.Context, uid string) (bool, error) {
+	return true, nil
+}
+
+// AuthorizeRoles lets through users with one of the given roles. Users with a scoped
+// role are also checked against every scope; a route without scopes is closed to them.
+func AuthorizeRoles(roles []string, scopes ...Scope) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user_type, _ := c.Get("user_type")
 		userRole := user_type.(string)
@@ -48,6 +82,27 @@ func AuthorizeRoles(roles []string) gin.HandlerFunc {
 			}
 		}
 
+		if authorized && ScopedRoles[userRole] {
+			authorized = len(scopes) > 0
+			uid := c.GetString("uid")
+			for _, scope := range scopes {
+				allowed, err := scope(c, uid)
+				if err != nil {
+					var missing notFoundError
+					if errors.As(err, &missing) {
+						c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+						return
+					}
+					c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+					return
+				}
+				if !allowed {
+					authorized = false
+					break
+				}
+			}
+		}
+
 		if !authorized {
 			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
 			return
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	StatusPending     = "Pending"
+	StatusUnderReview = "UnderReview"
+	StatusAccepted    = "Accepted"
+	StatusRejected    = "Rejected"
+	StatusWaitlisted  = "Waitlisted"
+	StatusConfirmed   = "Confirmed"
+	StatusDeclined    = "Declined"
+	StatusWithdrawn   = "Withdrawn"
+)
+
+// applicationTransitions lists, for every status, the statuses an application may move to.
+var applicationTransitions = map[string][]string{
+	StatusPending:     {StatusUnderReview, StatusWithdrawn},
+	StatusUnderReview: {StatusAccepted, StatusRejected, StatusWaitlisted, StatusWithdrawn},
+	StatusWaitlisted:  {StatusAccepted, StatusRejected, StatusWithdrawn},
+	StatusAccepted:    {StatusConfirmed, StatusDeclined, StatusWithdrawn},
+	StatusRejected:    {StatusUnderReview},
+	StatusConfirmed:   {StatusWithdrawn},
+}
+
+// CanTransition reports whether an application may move from one status to another.
+func CanTransition(from string, to string) bool {
+	for _, allowed := range applicationTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusChange is a single entry in an application's status history.
+type StatusChange struct {
+	From          string    `json:"from" bson:"from"`
+	To            string    `json:"to" bson:"to"`
+	ChangedBy     string    `json:"changedBy" bson:"changedBy"`
+	ChangedByRole string    `json:"changedByRole" bson:"changedByRole"`
+	ChangedAt     time.Time `json:"changedAt" bson:"changedAt"`
+	Reason        string    `json:"reason,omitempty" bson:"reason,omitempty"`
+}
+
+type StatusChangeRequest struct {
+	Status string `json:"status" validate:"required"`
+	Reason string `json:"reason"`
+}
+
+const (
+	AppealPending   = "Pending"
+	AppealUpheld    = "Upheld"
+	AppealDismissed = "Dismissed"
+)
+
+// DefaultAppealWindowDays is used when a selection does not set its own appeal window.
+const DefaultAppealWindowDays = 8
+
+type Appeal struct {
+	Id            primitive.ObjectID `json:"id" bson:"_id"`
+	ApplicationId primitive.ObjectID `json:"applicationId" bson:"applicationId"`
+	SelectionId   primitive.ObjectID `json:"selectionId" bson:"selectionId"`
+	StudentId     primitive.ObjectID `json:"studentId" bson:"studentId"`
+	Reason        string             `json:"reason" bson:"reason" validate:"required"`
+	Status        string             `json:"status" bson:"status"`
+	FiledAt       time.Time          `json:"filedAt" bson:"filedAt"`
+	ResolvedBy    string             `json:"resolvedBy,omitempty" bson:"resolvedBy,omitempty"`
+	ResolvedAt    *time.Time         `json:"resolvedAt,omitempty" bson:"resolvedAt,omitempty"`
+	Resolution    string             `json:"resolution,omitempty" bson:"resolution,omitempty"`
+}
+
+// ReopensReview reports whether upholding the appeal still has to send the
+// application back to review. A retry after the appeal could not be closed finds the
+// application under review already, and UnderReview cannot move to itself.
+func (a *Appeal) ReopensReview(app *Application) bool {
+	return !(a.Status == AppealPending && app.Status == StatusUnderReview)
+}
+
+type AppealResolution struct {
+	Upheld         bool   `json:"upheld"`
+	Resolution     string `json:"resolution" validate:"required"`
+	RerunPlacement bool   `json:"rerunPlacement"`
+}
+
+// DefaultPageSize and MaxPageSize bound how many applications are listed at once.
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// ApplicationFilter selects applications for review listings and bulk actions.
+// Incomplete matches applications without a student or academic snapshot.
+type ApplicationFilter struct {
+	SelectionId string `json:"selectionId" form:"selectionId"`
+	Status      string `json:"status" form:"status"`
+	Year        *int   `json:"year" form:"year"`
+	Scholarship *bool  `json:"scholarship" form:"scholarship"`
+	Incomplete  bool   `json:"incomplete" form:"incomplete"`
+}
+
+type ApplicationPage struct {
+	Items    []*Application `json:"items"`
+	Total    int64          `json:"total"`
+	Page     int            `json:"page"`
+	PageSize int            `json:"pageSize"`
+}
+
+// BulkStatusRequest moves every application matching Filter, or only those listed
+// in Ids, to Status.
+type BulkStatusRequest struct {
+	Filter ApplicationFilter `json:"filter"`
+	Ids    []string          `json:"ids"`
+	Status string            `json:"status" validate:"required"`
+	Reason string            `json:"reason"`
+}
+
+type BulkSkip struct {
+	Id     primitive.ObjectID `json:"id"`
+	Status string             `json:"status"`
+	Reason string             `json:"reason"`
+}
+
+type BulkStatusResult struct {
+	Matched int                  `json:"matched"`
+	Updated []primitive.ObjectID `json:"updated"`
+	Skipped []BulkSkip           `json:"skipped"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "testing"
+
+func TestAppealReopensReview(t *testing.T) {
+	tests := []struct {
+		name   string
+		appeal string
+		app    string
+		want   bool
+	}{
+		{"first attempt", AppealPending, StatusRejected, true},
+		{"retry after the appeal could not be closed", AppealPending, StatusUnderReview, false},
+		{"resolved appeal", AppealUpheld, StatusUnderReview, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appeal := &Appeal{Status: tt.appeal}
+			app := &Application{Status: tt.app}
+			if got := appeal.ReopensReview(app); got != tt.want {
+				t.Errorf("ReopensReview() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppealRetryNeedsNoTransition(t *testing.T) {
+	// The first attempt moved the application out of Rejected; a retry must not
+	// try that move again, since UnderReview cannot move to itself.
+	if !CanTransition(StatusRejected, StatusUnderReview) {
+		t.Fatal("a rejected application cannot be sent back to review")
+	}
+	if CanTransition(StatusUnderReview, StatusUnderReview) {
+		t.Fatal("expected UnderReview to not move to itself")
+	}
+	appeal := &Appeal{Status: AppealPending}
+	if appeal.ReopensReview(&Application{Status: StatusUnderReview}) {
+		t.Error("retrying an upheld appeal tries to send the application back to review again")
+	}
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	IncidentReported         = "Reported"
+	IncidentHearingScheduled = "HearingScheduled"
+	IncidentSanctioned       = "Sanctioned"
+	IncidentDismissed        = "Dismissed"
+)
+
+// incidentTransitions lists, for every incident status, the statuses it may move to.
+// A hearing can be rescheduled; a decision is final.
+var incidentTransitions = map[string][]string{
+	IncidentReported:         {IncidentHearingScheduled, IncidentDismissed},
+	IncidentHearingScheduled: {IncidentHearingScheduled, IncidentSanctioned, IncidentDismissed},
+}
+
+// CanTransitionIncident reports whether an incident may move from one status to another.
+func CanTransitionIncident(from string, to string) bool {
+	for _, allowed := range incidentTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	SanctionWarning  = "Warning"
+	SanctionFine     = "Fine"
+	SanctionEviction = "EvictionProceedings"
+)
+
+// SanctionSeverity orders sanction levels; students with no sanction have severity 0.
+var SanctionSeverity = map[string]int{
+	SanctionWarning:  1,
+	SanctionFine:     2,
+	SanctionEviction: 3,
+}
+
+// SanctionWindow is how long a sanction stays on a resident's record for renewal
+// and ranking.
+const SanctionWindow = 365 * 24 * time.Hour
+
+// Hearing is where the resident can answer the report before a decision is made.
+type Hearing struct {
+	At          time.Time `json:"at" bson:"at" validate:"required"`
+	Location    string    `json:"location" bson:"location" validate:"required"`
+	ScheduledBy string    `json:"scheduledBy" bson:"scheduledBy"`
+}
+
+// Sanction is the outcome of an incident the resident was found responsible for.
+type Sanction struct {
+	Level      string     `json:"level" bson:"level"`
+	FineAmount float64    `json:"fineAmount,omitempty" bson:"fineAmount,omitempty"`
+	FinePaidAt *time.Time `json:"finePaidAt,omitempty" bson:"finePaidAt,omitempty"`
+	FineRef    string     `json:"fineRef,omitempty" bson:"fineRef,omitempty"`
+	ImposedAt  time.Time  `json:"imposedAt" bson:"imposedAt"`
+}
+
+// Incident is a house-rule violation a staff member reported against a resident.
+type Incident struct {
+	Id             primitive.ObjectID `json:"id" bson:"_id"`
+	StudentId      primitive.ObjectID `json:"studentId" bson:"studentId"`
+	StudentName    string             `json:"studentName" bson:"studentName"`
+	BuildingId     primitive.ObjectID `json:"buildingId" bson:"buildingId"`
+	RoomNumber     int                `json:"roomNumber" bson:"roomNumber"`
+	Rule           string             `json:"rule" bson:"rule"`
+	Description    string             `json:"description" bson:"description"`
+	OccurredAt     time.Time          `json:"occurredAt" bson:"occurredAt"`
+	Status         string             `json:"status" bson:"status"`
+	ReportedBy     string             `json:"reportedBy" bson:"reportedBy"`
+	ReportedByName string             `json:"reportedByName" bson:"reportedByName"`
+	ReporterRole   string             `json:"reporterRole" bson:"reporterRole"`
+	// Statement is the resident's own account of the incident.
+	Statement string         `json:"statement,omitempty" bson:"statement,omitempty"`
+	Hearing   *Hearing       `json:"hearing,omitempty" bson:"hearing,omitempty"`
+	Sanction  *Sanction      `json:"sanction,omitempty" bson:"sanction,omitempty"`
+	Decision  string         `json:"decision,omitempty" bson:"decision,omitempty"`
+	DecidedBy string         `json:"decidedBy,omitempty" bson:"decidedBy,omitempty"`
+	DecidedAt *time.Time     `json:"decidedAt,omitempty" bson:"decidedAt,omitempty"`
+	History   []StatusChange `json:"history" bson:"history"`
+	CreatedAt time.Time      `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt" bson:"updatedAt"`
+}
+
+type IncidentRequest struct {
+	StudentId   string    `json:"studentId" validate:"required"`
+	Rule        string    `json:"rule" validate:"required"`
+	Description string    `json:"description" validate:"required"`
+	OccurredAt  time.Time `json:"occurredAt" validate:"required"`
+}
+
+type StatementRequest struct {
+	Statement string `json:"statement" validate:"required"`
+}
+
+// IncidentDecision closes an incident after the hearing: either a sanction is
+// imposed or the report is dismissed.
+type IncidentDecision struct {
+	Dismiss    bool    `json:"dismiss"`
+	Level      string  `json:"level" validate:"omitempty,oneof=Warning Fine EvictionProceedings"`
+	FineAmount float64 `json:"fineAmount" validate:"min=0"`
+	Reasoning  string  `json:"reasoning" validate:"required"`
+}
+
+type FinePaymentRequest struct {
+	Token string `json:"token"`
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	DocumentIncomeCertificate     = "IncomeCertificate"
+	DocumentDisabilityDecision    = "DisabilityDecision"
+	DocumentEnrollmentCertificate = "EnrollmentCertificate"
+	DocumentOther                 = "Other"
+)
+
+// DocumentTypes are the kinds of supporting documents an applicant can attach.
+var DocumentTypes = map[string]bool{
+	DocumentIncomeCertificate:     true,
+	DocumentDisabilityDecision:    true,
+	DocumentEnrollmentCertificate: true,
+	DocumentOther:                 true,
+}
+
+const (
+	DocumentPending  = "Pending"
+	DocumentAccepted = "Accepted"
+	DocumentRejected = "Rejected"
+)
+
+// SupportingDocument is a file an applicant attached to prove what they stated.
+type SupportingDocument struct {
+	Id          primitive.ObjectID `json:"id" bson:"_id"`
+	Type        string             `json:"type" bson:"type"`
+	FileName    string             `json:"fileName" bson:"fileName"`
+	Path        string             `json:"-" bson:"path"`
+	ContentType string             `json:"contentType" bson:"contentType"`
+	Size        int64              `json:"size" bson:"size"`
+	Status      string             `json:"status" bson:"status"`
+	UploadedAt  time.Time          `json:"uploadedAt" bson:"uploadedAt"`
+	ReviewedBy  string             `json:"reviewedBy,omitempty" bson:"reviewedBy,omitempty"`
+	ReviewedAt  *time.Time         `json:"reviewedAt,omitempty" bson:"reviewedAt,omitempty"`
+	Note        string             `json:"note,omitempty" bson:"note,omitempty"`
+}
+
+type DocumentReview struct {
+	Accepted bool   `json:"accepted"`
+	Note     string `json:"note"`
+}
+
+// Document returns the attached document with the given id, or nil.
+func (a *Application) Document(id primitive.ObjectID) *SupportingDocument {
+	for i := range a.Documents {
+		if a.Documents[i].Id == id {
+			return &a.Documents[i]
+		}
+	}
+	return nil
+}
+
+// MissingDocuments returns the document types the selection requires for which the
+// application has no document with the wanted status. With accepted set only
+// accepted documents count, otherwise any document that was not rejected does.
+func (a *Application) MissingDocuments(selection *Selection, accepted bool) []string {
+	missing := []string{}
+	for _, docType := range selection.RequiredDocuments {
+		found := false
+		for _, doc := range a.Documents {
+			if doc.Type != docType || doc.Status == DocumentRejected {
+				continue
+			}
+			if !accepted || doc.Status == DocumentAccepted {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, docType)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	BookingBooked    = "Booked"
+	BookingCancelled = "Cancelled"
+	BookingAttended  = "Attended"
+	BookingNoShow    = "NoShow"
+)
+
+// NoShowWindow is how far back no-shows count against a resident's booking rights.
+const NoShowWindow = 30 * 24 * time.Hour
+
+// CheckInEarly is how long before the start of a booking the resident can check in.
+const CheckInEarly = 15 * time.Minute
+
+// Facility is a shared resource of a building, such as a washing machine, a study
+// room or a gym, that residents book in fixed time slots.
+type Facility struct {
+	Id          primitive.ObjectID `json:"id" bson:"_id"`
+	BuildingId  primitive.ObjectID `json:"buildingId" bson:"buildingId"`
+	Name        string             `json:"name" bson:"name" validate:"required"`
+	Type        string             `json:"type" bson:"type" validate:"required,oneof=Laundry StudyRoom Gym Other"`
+	Description string             `json:"description,omitempty" bson:"description,omitempty"`
+	// OpensAt and ClosesAt are the daily opening hours as HH:MM.
+	OpensAt     string `json:"opensAt" bson:"opensAt" validate:"required,datetime=15:04"`
+	ClosesAt    string `json:"closesAt" bson:"closesAt" validate:"required,datetime=15:04"`
+	SlotMinutes int    `json:"slotMinutes" bson:"slotMinutes" validate:"required,min=15,max=720"`
+	// Capacity is how many residents can book the same slot.
+	Capacity int `json:"capacity" bson:"capacity" validate:"required,min=1"`
+	// MaxActiveBookings limits the upcoming bookings a resident can hold.
+	MaxActiveBookings int `json:"maxActiveBookings" bson:"maxActiveBookings" validate:"min=0"`
+	// MaxBookingsPerWeek limits bookings per resident in a calendar week; 0 means no limit.
+	MaxBookingsPerWeek int `json:"maxBookingsPerWeek" bson:"maxBookingsPerWeek" validate:"min=0"`
+	// NoShowLimit blocks residents with this many no-shows in the last 30 days; 0 means never.
+	NoShowLimit int  `json:"noShowLimit" bson:"noShowLimit" validate:"min=0"`
+	Active      bool `json:"active" bson:"active"`
+}
+
+// Slot is one bookable period of a facility.
+type Slot struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Free  int       `json:"free"`
+}
+
+func clock(day time.Time, hhmm string) (time.Time, error) {
+	t, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected HH:MM", hhmm)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, day.Location()), nil
+}
+
+// Slots returns the slots of the facility on the given day, in the day's location.
+func (f *Facility) Slots(day time.Time) ([]Slot, error) {
+	opens, err := clock(day, f.OpensAt)
+	if err != nil {
+		return nil, err
+	}
+	closes, err := clock(day, f.ClosesAt)
+	if err != nil {
+		return nil, err
+	}
+	length := time.Duration(f.SlotMinutes) * time.Minute
+	var slots []Slot
+	for start := opens; !start.Add(length).After(closes); start = start.Add(length) {
+		slots = append(slots, Slot{Start: start, End: start.Add(length), Free: f.Capacity})
+	}
+	return slots, nil
+}
+
+// SlotAt returns the slot starting at start, or an error if no slot starts then.
+func (f *Facility) SlotAt(start time.Time) (*Slot, error) {
+	slots, err := f.Slots(start)
+	if err != nil {
+		return nil, err
+	}
+	for _, slot := range slots {
+		if slot.Start.Equal(start) {
+			return &slot, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not the start of a slot", start.Format("02-01-2006 15:04"))
+}
+
+// Booking is a resident's reservation of a facility slot.
+type Booking struct {
+	Id         primitive.ObjectID `json:"id" bson:"_id"`
+	FacilityId primitive.ObjectID `json:"facilityId" bson:"facilityId"`
+	BuildingId primitive.ObjectID `json:"buildingId" bson:"buildingId"`
+	StudentId  primitive.ObjectID `json:"studentId" bson:"studentId"`
+	Start      time.Time          `json:"start" bson:"start"`
+	End        time.Time          `json:"end" bson:"end"`
+	// Seat is which of the facility's places in the slot the booking holds.
+	Seat   int    `json:"seat" bson:"seat"`
+	Status string `json:"status" bson:"status"`
+	// HoldsSlot is true while the booking takes up its seat; cancelling frees it.
+	HoldsSlot   bool       `json:"-" bson:"holdsSlot"`
+	CreatedAt   time.Time  `json:"createdAt" bson:"createdAt"`
+	CancelledAt *time.Time `json:"cancelledAt,omitempty" bson:"cancelledAt,omitempty"`
+	CheckedInAt *time.Time `json:"checkedInAt,omitempty" bson:"checkedInAt,omitempty"`
+}
+
+type BookingRequest struct {
+	Start time.Time `json:"start" validate:"required"`
+}
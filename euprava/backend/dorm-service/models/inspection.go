@@ -0,0 +1,202 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	DepositHeld    = "Held"
+	DepositSettled = "Settled"
+)
+
+// A settlement is recorded as pending before the refund goes out and completed
+// once the refund went through.
+const (
+	SettlementPending   = "Pending"
+	SettlementCompleted = "Completed"
+)
+
+const (
+	InspectionCheckIn  = "CheckIn"
+	InspectionCheckOut = "CheckOut"
+)
+
+const (
+	ConditionGood    = "Good"
+	ConditionWorn    = "Worn"
+	ConditionDamaged = "Damaged"
+	ConditionMissing = "Missing"
+)
+
+// conditionRank orders item conditions from best to worst.
+var conditionRank = map[string]int{
+	ConditionGood:    0,
+	ConditionWorn:    1,
+	ConditionDamaged: 2,
+	ConditionMissing: 3,
+}
+
+// DefaultChecklist is inspected in rooms that do not list their own inventory.
+var DefaultChecklist = []string{
+	"Bed", "Mattress", "Desk", "Chair", "Wardrobe", "Shelves",
+	"Window", "Door and lock", "Walls", "Floor", "Lighting", "Heating",
+}
+
+// Checklist returns the items that have to be checked on an inspection of the room.
+func (r *Room) Checklist() []string {
+	if len(r.Inventory) > 0 {
+		return r.Inventory
+	}
+	return DefaultChecklist
+}
+
+// DepositAmount returns the security deposit for a room.
+func (b *Building) DepositAmount(room *Room, types map[primitive.ObjectID]*RoomType) float64 {
+	if b.Deposit > 0 {
+		return b.Deposit
+	}
+	return b.RoomPrice(room, types)
+}
+
+// Deposit is the security deposit a resident paid at check-in.
+type Deposit struct {
+	Id          primitive.ObjectID `json:"id" bson:"_id"`
+	StudentId   primitive.ObjectID `json:"studentId" bson:"studentId"`
+	BuildingId  primitive.ObjectID `json:"buildingId" bson:"buildingId"`
+	RoomNumber  int                `json:"roomNumber" bson:"roomNumber"`
+	Amount      float64            `json:"amount" bson:"amount"`
+	Status      string             `json:"status" bson:"status"`
+	Provider    string             `json:"provider" bson:"provider"`
+	ProviderRef string             `json:"providerRef" bson:"providerRef"`
+	RecordedBy  string             `json:"recordedBy" bson:"recordedBy"`
+	PaidAt      time.Time          `json:"paidAt" bson:"paidAt"`
+	SettledAt   *time.Time         `json:"settledAt,omitempty" bson:"settledAt,omitempty"`
+}
+
+type DepositRequest struct {
+	// StudentId is only used when an admin takes the deposit at the desk.
+	StudentId string `json:"studentId"`
+	Token     string `json:"token"`
+}
+
+// InspectionItem is one line of an inspection checklist.
+type InspectionItem struct {
+	Name      string   `json:"name" bson:"name" validate:"required"`
+	Condition string   `json:"condition" bson:"condition" validate:"required,oneof=Good Worn Damaged Missing"`
+	Notes     string   `json:"notes,omitempty" bson:"notes,omitempty"`
+	Photos    []string `json:"photos" bson:"photos"`
+	// DamageCharge is what the resident is charged for the item on check-out.
+	DamageCharge float64 `json:"damageCharge,omitempty" bson:"damageCharge,omitempty" validate:"min=0"`
+}
+
+// Inspection is the record of a room inspection at check-in or check-out.
+type Inspection struct {
+	Id          primitive.ObjectID `json:"id" bson:"_id"`
+	StudentId   primitive.ObjectID `json:"studentId" bson:"studentId"`
+	BuildingId  primitive.ObjectID `json:"buildingId" bson:"buildingId"`
+	RoomNumber  int                `json:"roomNumber" bson:"roomNumber"`
+	Type        string             `json:"type" bson:"type"`
+	Items       []InspectionItem   `json:"items" bson:"items"`
+	Notes       string             `json:"notes,omitempty" bson:"notes,omitempty"`
+	InspectedBy string             `json:"inspectedBy" bson:"inspectedBy"`
+	InspectedAt time.Time          `json:"inspectedAt" bson:"inspectedAt"`
+}
+
+type InspectionRequest struct {
+	StudentId string           `json:"studentId" validate:"required"`
+	Type      string           `json:"type" validate:"required,oneof=CheckIn CheckOut"`
+	Items     []InspectionItem `json:"items" validate:"required,dive"`
+	Notes     string           `json:"notes"`
+}
+
+// Item returns the checklist line with the given name, or nil.
+func (i *Inspection) Item(name string) *InspectionItem {
+	for idx := range i.Items {
+		if i.Items[idx].Name == name {
+			return &i.Items[idx]
+		}
+	}
+	return nil
+}
+
+// Deduction is a damage charge taken from a deposit.
+type Deduction struct {
+	Item             string  `json:"item" bson:"item"`
+	CheckInCondition string  `json:"checkInCondition,omitempty" bson:"checkInCondition,omitempty"`
+	Condition        string  `json:"condition" bson:"condition"`
+	Notes            string  `json:"notes,omitempty" bson:"notes,omitempty"`
+	Amount           float64 `json:"amount" bson:"amount"`
+}
+
+// Settlement is the statement a resident gets when their deposit is settled on leaving.
+type Settlement struct {
+	Id                   primitive.ObjectID  `json:"id" bson:"_id"`
+	StudentId            primitive.ObjectID  `json:"studentId" bson:"studentId"`
+	DepositId            primitive.ObjectID  `json:"depositId" bson:"depositId"`
+	BuildingId           primitive.ObjectID  `json:"buildingId" bson:"buildingId"`
+	RoomNumber           int                 `json:"roomNumber" bson:"roomNumber"`
+	CheckInInspectionId  *primitive.ObjectID `json:"checkInInspectionId,omitempty" bson:"checkInInspectionId,omitempty"`
+	CheckOutInspectionId primitive.ObjectID  `json:"checkOutInspectionId" bson:"checkOutInspectionId"`
+	DepositAmount        float64             `json:"depositAmount" bson:"depositAmount"`
+	Deductions           []Deduction         `json:"deductions" bson:"deductions"`
+	TotalDamage          float64             `json:"totalDamage" bson:"totalDamage"`
+	Deducted             float64             `json:"deducted" bson:"deducted"`
+	Refund               float64             `json:"refund" bson:"refund"`
+	// AmountOwed is the damage not covered by the deposit.
+	AmountOwed float64   `json:"amountOwed" bson:"amountOwed"`
+	Status     string    `json:"status" bson:"status"`
+	RefundRef  string    `json:"refundRef,omitempty" bson:"refundRef,omitempty"`
+	SettledBy  string    `json:"settledBy" bson:"settledBy"`
+	SettledAt  time.Time `json:"settledAt" bson:"settledAt"`
+}
+
+// NewSettlement works out what is deducted from a deposit. Only damage recorded on
+// check-out is charged, and items that were already in the same or worse
+// condition at check-in are not charged again.
+func NewSettlement(deposit *Deposit, checkIn *Inspection, checkOut *Inspection) *Settlement {
+	settlement := &Settlement{
+		StudentId:            deposit.StudentId,
+		DepositId:            deposit.Id,
+		BuildingId:           deposit.BuildingId,
+		RoomNumber:           deposit.RoomNumber,
+		CheckOutInspectionId: checkOut.Id,
+		DepositAmount:        deposit.Amount,
+		Deductions:           []Deduction{},
+	}
+	if checkIn != nil {
+		settlement.CheckInInspectionId = &checkIn.Id
+	}
+
+	for _, item := range checkOut.Items {
+		if item.DamageCharge <= 0 {
+			continue
+		}
+		deduction := Deduction{Item: item.Name, Condition: item.Condition, Notes: item.Notes, Amount: RoundMoney(item.DamageCharge)}
+		if checkIn != nil {
+			if before := checkIn.Item(item.Name); before != nil {
+				deduction.CheckInCondition = before.Condition
+				if conditionRank[item.Condition] <= conditionRank[before.Condition] {
+					continue
+				}
+			}
+		}
+		settlement.Deductions = append(settlement.Deductions, deduction)
+		settlement.TotalDamage += deduction.Amount
+	}
+
+	settlement.TotalDamage = RoundMoney(settlement.TotalDamage)
+	settlement.Deducted = settlement.TotalDamage
+	if settlement.Deducted > deposit.Amount {
+		settlement.Deducted = deposit.Amount
+		settlement.AmountOwed = RoundMoney(settlement.TotalDamage - deposit.Amount)
+	}
+	settlement.Refund = RoundMoney(deposit.Amount - settlement.Deducted)
+	return settlement
+}
+
+// InspectionPhotoPath is the route an inspection photo is served from.
+func InspectionPhotoPath(inspectionId primitive.ObjectID, name string) string {
+	return "/inspections/" + inspectionId.Hex() + "/photos/" + name
+}
@@ -0,0 +1,133 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	InvoiceOpen          = "Open"
+	InvoicePartiallyPaid = "PartiallyPaid"
+	InvoicePaid          = "Paid"
+)
+
+const (
+	LedgerCharge  = "Charge"
+	LedgerPayment = "Payment"
+	LedgerRefund  = "Refund"
+)
+
+// PeriodLayout is the format of an invoice period, e.g. "2026-10".
+const PeriodLayout = "2006-01"
+
+// Invoice is the monthly rent bill of one resident.
+type Invoice struct {
+	Id             primitive.ObjectID `json:"id" bson:"_id"`
+	StudentId      primitive.ObjectID `json:"studentId" bson:"studentId"`
+	BuildingId     primitive.ObjectID `json:"buildingId" bson:"buildingId"`
+	RoomNumber     int                `json:"roomNumber" bson:"roomNumber"`
+	Period         string             `json:"period" bson:"period"`
+	BilledFrom     time.Time          `json:"billedFrom" bson:"billedFrom"`
+	BilledTo       time.Time          `json:"billedTo" bson:"billedTo"`
+	DaysBilled     int                `json:"daysBilled" bson:"daysBilled"`
+	DaysInPeriod   int                `json:"daysInPeriod" bson:"daysInPeriod"`
+	MonthlyPrice   float64            `json:"monthlyPrice" bson:"monthlyPrice"`
+	Amount         float64            `json:"amount" bson:"amount"`
+	AmountPaid     float64            `json:"amountPaid" bson:"amountPaid"`
+	Status         string             `json:"status" bson:"status"`
+	IssuedAt       time.Time          `json:"issuedAt" bson:"issuedAt"`
+	DueDate        time.Time          `json:"dueDate" bson:"dueDate"`
+	RemindersSent  int                `json:"remindersSent" bson:"remindersSent"`
+	LastReminderAt *time.Time         `json:"lastReminderAt,omitempty" bson:"lastReminderAt,omitempty"`
+}
+
+// Outstanding is what is still owed on the invoice. It is never negative; anything
+// paid above a reduced amount is credited on the ledger.
+func (i *Invoice) Outstanding() float64 {
+	return RoundMoney(math.Max(i.Amount-i.AmountPaid, 0))
+}
+
+func (i *Invoice) IsOverdue(now time.Time) bool {
+	return i.Status != InvoicePaid && now.After(i.DueDate)
+}
+
+// LedgerEntry is one movement on a student's rent account.
+type LedgerEntry struct {
+	Id          primitive.ObjectID `json:"id" bson:"_id"`
+	StudentId   primitive.ObjectID `json:"studentId" bson:"studentId"`
+	InvoiceId   primitive.ObjectID `json:"invoiceId" bson:"invoiceId"`
+	Type        string             `json:"type" bson:"type"`
+	Amount      float64            `json:"amount" bson:"amount"`
+	Provider    string             `json:"provider,omitempty" bson:"provider,omitempty"`
+	ProviderRef string             `json:"providerRef,omitempty" bson:"providerRef,omitempty"`
+	RecordedBy  string             `json:"recordedBy,omitempty" bson:"recordedBy,omitempty"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+type PaymentRequest struct {
+	Amount float64 `json:"amount" validate:"required,gt=0"`
+	Token  string  `json:"token"`
+}
+
+// Arrears sums up what one student owes on overdue invoices.
+type Arrears struct {
+	StudentId     primitive.ObjectID   `json:"studentId" bson:"_id"`
+	Outstanding   float64              `json:"outstanding" bson:"outstanding"`
+	Invoices      int                  `json:"invoices" bson:"invoices"`
+	OldestDueDate time.Time            `json:"oldestDueDate" bson:"oldestDueDate"`
+	RemindersSent int                  `json:"remindersSent" bson:"remindersSent"`
+	BuildingIds   []primitive.ObjectID `json:"buildingIds" bson:"buildingIds"`
+}
+
+// Notification is a message shown to a student in eUprava.
+type Notification struct {
+	Id        primitive.ObjectID `json:"id" bson:"_id"`
+	StudentId primitive.ObjectID `json:"studentId" bson:"studentId"`
+	Title     string             `json:"title" bson:"title"`
+	Content   string             `json:"content" bson:"content"`
+	Read      bool               `json:"read" bson:"read"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+func RoundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// ProRate returns the part of a monthly price owed for staying from..to within the
+// month starting at periodStart, together with the number of days billed and in the month.
+// Days are counted by calendar date, so the day of moving in is billed and so is the
+// day of moving out unless the stay ends at midnight.
+func ProRate(monthlyPrice float64, periodStart time.Time, from time.Time, to time.Time) (float64, int, int) {
+	periodEnd := periodStart.AddDate(0, 1, 0)
+	daysInPeriod := calendarDays(periodStart, periodEnd)
+
+	if from.Before(periodStart) {
+		from = periodStart
+	}
+	if to.IsZero() || to.After(periodEnd) {
+		to = periodEnd
+	}
+	if !to.After(from) {
+		return 0, 0, daysInPeriod
+	}
+
+	from, to = from.In(periodStart.Location()), to.In(periodStart.Location())
+	days := calendarDays(from, to)
+	if to.Hour() != 0 || to.Minute() != 0 || to.Second() != 0 || to.Nanosecond() != 0 {
+		days++
+	}
+	if days >= daysInPeriod {
+		return RoundMoney(monthlyPrice), daysInPeriod, daysInPeriod
+	}
+	return RoundMoney(monthlyPrice * float64(days) / float64(daysInPeriod)), days, daysInPeriod
+}
+
+// calendarDays counts the dates between from and to, ignoring the time of day and
+// daylight saving changes.
+func calendarDays(from time.Time, to time.Time) int {
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	end := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(end.Sub(start).Hours() / 24)
+}
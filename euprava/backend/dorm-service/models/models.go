@@ -3,6 +3,7 @@ package models
 import (
 	"encoding/json"
 	"io"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
@@ -27,33 +28,218 @@ type Student struct {
 	Year          int     `json:"year"`
 }
 
+// AcademicRecord is the snapshot of a student's academic data taken from
+// university-service when an application is submitted.
+type AcademicRecord struct {
+	GPA           float64   `json:"gpa" bson:"gpa"`
+	ESBP          int       `json:"esbp" bson:"esbp"`
+	Year          int       `json:"year" bson:"year"`
+	Scholarship   bool      `json:"scholarship" bson:"scholarship"`
+	HighschoolGPA float64   `json:"highschool_gpa" bson:"highschoolGpa"`
+	Enrolled      bool      `json:"enrolled" bson:"enrolled"`
+	FetchedAt     time.Time `json:"fetchedAt" bson:"fetchedAt"`
+}
+
+// ApplyTo overwrites the academic fields of a student with the snapshot values.
+func (r *AcademicRecord) ApplyTo(s *Student) {
+	s.GPA = r.GPA
+	s.ESBP = r.ESBP
+	s.Year = r.Year
+	s.Scholarship = r.Scholarship
+	s.HighschoolGPA = r.HighschoolGPA
+}
+
 type Application struct {
-	Id      primitive.ObjectID `bson:"_id"`
-	Status  string             `json:"status"` //accepted / rejected / pending
-	Student *Student           `json:"student"`
+	Id          primitive.ObjectID `json:"id" bson:"_id"`
+	SelectionId primitive.ObjectID `json:"selectionId" bson:"selectionId"`
+	StudentId   primitive.ObjectID `json:"studentId" bson:"studentId"`
+	Status      string             `json:"status" bson:"status"`
+	Student     *Student           `json:"student" bson:"student"`
+	Academic    *AcademicRecord    `json:"academic,omitempty" bson:"academic,omitempty"`
+	// RoomTypePreferences lists the room types the student wants, most wanted first.
+	RoomTypePreferences []primitive.ObjectID `json:"roomTypePreferences,omitempty" bson:"roomTypePreferences,omitempty"`
+	// BuildingPreferences lists the buildings of the selection the student would live
+	// in, most wanted first. An empty list means any building of the selection.
+	BuildingPreferences []primitive.ObjectID `json:"buildingPreferences,omitempty" bson:"buildingPreferences,omitempty"`
+	// AcceptedBuildingId is the building the student was given a place in.
+	AcceptedBuildingId *primitive.ObjectID `json:"acceptedBuildingId,omitempty" bson:"acceptedBuildingId,omitempty"`
+	Placement          *RoomAssignment     `json:"placement,omitempty" bson:"placement,omitempty"`
+	// Documents are the supporting documents the applicant attached.
+	Documents []SupportingDocument `json:"documents,omitempty" bson:"documents,omitempty"`
+	// Ranking is the student's place on the published ranking list, filled in for
+	// responses only.
+	Ranking   *RankingPosition `json:"ranking,omitempty" bson:"-"`
+	History   []StatusChange   `json:"history" bson:"history"`
+	CreatedAt time.Time        `json:"createdAt" bson:"createdAt"`
+	UpdatedAt time.Time        `json:"updatedAt" bson:"updatedAt"`
 }
 
+// LastChangeTo returns the most recent history entry that moved the application into status.
+func (a *Application) LastChangeTo(status string) *StatusChange {
+	for i := len(a.History) - 1; i >= 0; i-- {
+		if a.History[i].To == status {
+			return &a.History[i]
+		}
+	}
+	return nil
+}
+
+// DefaultTimeZone is the time zone of selections that do not name their own.
+const DefaultTimeZone = "Europe/Belgrade"
+
+// Selection is an application round for one or more buildings. Applications are
+// accepted from StartDate up to, but not including, EndDate.
 type Selection struct {
-	Id           primitive.ObjectID `bson:"_id"`
-	StartDate    string             `json:"start_date"`
-	EndDate      string             `json:"end_date"`
-	BuildingId   primitive.ObjectID `json:"buildingId" bson:"buildingId"`
-	Applications Applications       `json:"applications,omitempty" bson:"applications,omitempty"`
+	Id        primitive.ObjectID `bson:"_id"`
+	StartDate time.Time          `json:"start_date" bson:"startDate"`
+	EndDate   time.Time          `json:"end_date" bson:"endDate"`
+	// TimeZone is the IANA zone the selection dates are shown in.
+	TimeZone   string             `json:"time_zone,omitempty" bson:"timeZone,omitempty"`
+	BuildingId primitive.ObjectID `json:"buildingId" bson:"buildingId"`
+	// BuildingIds lists every building the selection fills, BuildingId included.
+	// Selections created for a single building leave it empty.
+	BuildingIds []primitive.ObjectID `json:"buildingIds,omitempty" bson:"buildingIds,omitempty"`
+	// AppealWindowDays is how long after rejection a student may appeal; 0 means DefaultAppealWindowDays.
+	AppealWindowDays int `json:"appeal_window_days,omitempty" bson:"appealWindowDays,omitempty"`
+	// RequiredDocuments lists the document types every applicant has to attach.
+	RequiredDocuments []string `json:"requiredDocuments,omitempty" bson:"requiredDocuments,omitempty"`
+}
+
+// Location returns the time zone of the selection.
+func (s *Selection) Location() *time.Location {
+	name := s.TimeZone
+	if name == "" {
+		name = DefaultTimeZone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// IsOpen reports whether applications can be submitted at the given time.
+func (s *Selection) IsOpen(at time.Time) bool {
+	return !at.Before(s.StartDate) && at.Before(s.EndDate)
+}
+
+// Closed reports whether the selection window is over at the given time.
+func (s *Selection) Closed(at time.Time) bool {
+	return !at.Before(s.EndDate)
+}
+
+// Period returns the selection window as text in the selection's time zone.
+func (s *Selection) Period() string {
+	const layout = "02.01.2006. 15:04 MST"
+	loc := s.Location()
+	return s.StartDate.In(loc).Format(layout) + " - " + s.EndDate.In(loc).Format(layout)
+}
+
+// Buildings returns the buildings the selection fills.
+func (s *Selection) Buildings() []primitive.ObjectID {
+	if len(s.BuildingIds) > 0 {
+		return s.BuildingIds
+	}
+	return []primitive.ObjectID{s.BuildingId}
+}
+
+// Covers reports whether the selection fills the given building.
+func (s *Selection) Covers(buildingId primitive.ObjectID) bool {
+	for _, id := range s.Buildings() {
+		if id == buildingId {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildingChoices returns the buildings the application can be placed in, in the
+// order the student prefers them.
+func (a *Application) BuildingChoices(selection *Selection) []primitive.ObjectID {
+	if len(a.BuildingPreferences) > 0 {
+		return a.BuildingPreferences
+	}
+	return selection.Buildings()
+}
+
+// PlacedBuilding returns the building an accepted application holds a place in.
+// Applications accepted before selections covered several buildings have no
+// AcceptedBuildingId and belong to the selection's building.
+func (a *Application) PlacedBuilding(selection *Selection) primitive.ObjectID {
+	if a.AcceptedBuildingId != nil {
+		return *a.AcceptedBuildingId
+	}
+	return selection.BuildingId
+}
+
+func (s *Selection) AppealWindow() time.Duration {
+	days := s.AppealWindowDays
+	if days <= 0 {
+		days = DefaultAppealWindowDays
+	}
+	return time.Duration(days) * 24 * time.Hour
 }
 
 type Building struct {
 	Id      primitive.ObjectID `bson:"_id"`
-	Name    string             `json:"name" bson:"name"`
-	Address string             `json:"address" bson:"address"`
+	Name    string             `json:"name" bson:"name" validate:"required"`
+	Address string             `json:"address" bson:"address" validate:"required"`
 	Rooms   Rooms              `json:"rooms,omitempty" bson:"rooms"`
-	Price   float64            `json:"price,omitempty" bson:"price"`
+	Price   float64            `json:"price,omitempty" bson:"price" validate:"min=0"`
+	// Deposit is the security deposit taken at check-in. When it is not set the
+	// deposit equals one month's rent of the room.
+	Deposit float64 `json:"deposit,omitempty" bson:"deposit,omitempty" validate:"min=0"`
+	// MaxOvernightStays is how many overnight guests a resident can have per month.
+	// When it is not set DefaultMaxOvernightStays applies.
+	MaxOvernightStays int `json:"maxOvernightStays,omitempty" bson:"maxOvernightStays,omitempty" validate:"min=0"`
+	// Managers are the users with the MANAGER role who run the building.
+	Managers []primitive.ObjectID `json:"managers,omitempty" bson:"managers,omitempty"`
+}
+
+type BuildingManagersRequest struct {
+	ManagerIds []primitive.ObjectID `json:"managerIds"`
 }
 
 type Room struct {
-	Room_Number int                `json:"room_number,omitempty" bson:"room_number"`
-	Capacity    int                `json:"capacity" bson:"capacity"`
+	Room_Number int                `json:"room_number,omitempty" bson:"room_number" validate:"required,min=1"`
+	Floor       int                `json:"floor" bson:"floor"`
+	Capacity    int                `json:"capacity" bson:"capacity" validate:"required,min=1"`
 	Building_Id primitive.ObjectID `json:"building_id" bson:"building_id"`
 	Students    *Students          `json:"students,omitempty" bson:"students,omitempty"`
+	RoomTypeId  primitive.ObjectID `json:"room_type_id,omitempty" bson:"roomTypeId,omitempty"`
+	// MonthlyPrice overrides the room type and building price when set.
+	MonthlyPrice float64 `json:"monthly_price,omitempty" bson:"monthlyPrice,omitempty" validate:"min=0"`
+	// Inventory lists the furniture and fittings checked on inspections. Rooms
+	// without an inventory are inspected against DefaultChecklist.
+	Inventory []string `json:"inventory,omitempty" bson:"inventory,omitempty"`
+	// EffectivePrice is the resolved monthly price, filled in for responses only.
+	EffectivePrice float64 `json:"effective_price,omitempty" bson:"-"`
+}
+
+func (r *Room) Occupancy() int {
+	if r.Students == nil {
+		return 0
+	}
+	return len(*r.Students)
+}
+
+// HasStudent reports whether the student with the given id lives in the room.
+func (r *Room) HasStudent(studentId primitive.ObjectID) bool {
+	if r.Students == nil {
+		return false
+	}
+	for _, s := range *r.Students {
+		if s != nil && s.ID == studentId {
+			return true
+		}
+	}
+	return false
+}
+
+type MoveStudentRequest struct {
+	StudentId    string `json:"studentId" validate:"required"`
+	ToBuildingId string `json:"toBuildingId" validate:"required"`
+	ToRoomNumber int    `json:"toRoomNumber" validate:"required,min=1"`
 }
 
 type Students []*Student
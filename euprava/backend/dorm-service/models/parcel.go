@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	ParcelAtDesk   = "AtDesk"
+	ParcelPickedUp = "PickedUp"
+	ParcelReturned = "Returned"
+)
+
+// MaxPickupAttempts is how many wrong pickup codes are accepted before the code
+// has to be reissued.
+const MaxPickupAttempts = 5
+
+// Parcel is a parcel or letter waiting at the reception desk for a resident.
+type Parcel struct {
+	Id             primitive.ObjectID `json:"id" bson:"_id"`
+	BuildingId     primitive.ObjectID `json:"buildingId" bson:"buildingId"`
+	RoomNumber     int                `json:"roomNumber" bson:"roomNumber"`
+	StudentId      primitive.ObjectID `json:"studentId" bson:"studentId"`
+	StudentName    string             `json:"studentName" bson:"studentName"`
+	Carrier        string             `json:"carrier,omitempty" bson:"carrier,omitempty"`
+	TrackingNumber string             `json:"trackingNumber,omitempty" bson:"trackingNumber,omitempty"`
+	Description    string             `json:"description,omitempty" bson:"description,omitempty"`
+	Status         string             `json:"status" bson:"status"`
+	// PickupCodeHash is the hash of the one-time code the student shows at the desk.
+	PickupCodeHash string     `json:"-" bson:"pickupCodeHash"`
+	FailedAttempts int        `json:"failedAttempts" bson:"failedAttempts"`
+	RegisteredBy   string     `json:"registeredBy" bson:"registeredBy"`
+	RegisteredAt   time.Time  `json:"registeredAt" bson:"registeredAt"`
+	HandedOverBy   string     `json:"handedOverBy,omitempty" bson:"handedOverBy,omitempty"`
+	PickedUpAt     *time.Time `json:"pickedUpAt,omitempty" bson:"pickedUpAt,omitempty"`
+	// Flagged is set when the parcel has not been collected in time.
+	Flagged   bool       `json:"flagged" bson:"flagged"`
+	FlaggedAt *time.Time `json:"flaggedAt,omitempty" bson:"flaggedAt,omitempty"`
+}
+
+type ParcelRequest struct {
+	BuildingId string `json:"buildingId" validate:"required"`
+	RoomNumber int    `json:"roomNumber" validate:"required,min=1"`
+	// StudentId picks the resident when more than one student lives in the room.
+	StudentId      string `json:"studentId"`
+	Carrier        string `json:"carrier"`
+	TrackingNumber string `json:"trackingNumber"`
+	Description    string `json:"description"`
+}
+
+type PickupRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+// Occupant is a current resident of a room.
+type Occupant struct {
+	StudentId primitive.ObjectID `json:"studentId"`
+	Name      string             `json:"name"`
+}
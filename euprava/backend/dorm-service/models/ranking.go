@@ -0,0 +1,62 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RankingEntry is one line of a published ranking list. Students are listed under
+// a code instead of their name; the student id is kept for lookups only.
+type RankingEntry struct {
+	Position   int                 `json:"position" bson:"position"`
+	Code       string              `json:"code" bson:"code"`
+	StudentId  primitive.ObjectID  `json:"-" bson:"studentId"`
+	GPA        float64             `json:"gpa" bson:"gpa"`
+	Year       int                 `json:"year" bson:"year"`
+	Sanctioned bool                `json:"sanctioned" bson:"sanctioned"`
+	Outcome    string              `json:"outcome" bson:"outcome"`
+	BuildingId *primitive.ObjectID `json:"buildingId,omitempty" bson:"buildingId,omitempty"`
+}
+
+// Ranking is the official results list of a selection. Publishing it again, e.g.
+// after appeals, replaces the previous list.
+type Ranking struct {
+	Id          primitive.ObjectID `json:"id" bson:"_id"`
+	SelectionId primitive.ObjectID `json:"selectionId" bson:"selectionId"`
+	// Salt makes the student codes of one list impossible to match with another.
+	Salt        string         `json:"-" bson:"salt"`
+	Entries     []RankingEntry `json:"entries" bson:"entries"`
+	PublishedBy string         `json:"publishedBy" bson:"publishedBy"`
+	PublishedAt time.Time      `json:"publishedAt" bson:"publishedAt"`
+}
+
+// StudentCode returns the pseudonymous code of a student in this ranking.
+func (r *Ranking) StudentCode(studentId primitive.ObjectID) string {
+	mac := hmac.New(sha256.New, []byte(r.Salt))
+	mac.Write([]byte(studentId.Hex()))
+	return strings.ToUpper(hex.EncodeToString(mac.Sum(nil))[:10])
+}
+
+// Entry returns the line of the given student, or nil if they are not on the list.
+func (r *Ranking) Entry(studentId primitive.ObjectID) *RankingEntry {
+	for i := range r.Entries {
+		if r.Entries[i].StudentId == studentId {
+			return &r.Entries[i]
+		}
+	}
+	return nil
+}
+
+// RankingPosition is where a student placed on a published ranking list.
+type RankingPosition struct {
+	Position    int       `json:"position"`
+	Of          int       `json:"of"`
+	Code        string    `json:"code"`
+	Outcome     string    `json:"outcome"`
+	PublishedAt time.Time `json:"publishedAt"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	RenewalRenewed   = "Renewed"
+	RenewalCancelled = "Cancelled"
+)
+
+// RenewalCampaign is the period in which current residents can keep their place
+// for the next academic year without applying again. Places renewed in a campaign
+// are taken out of the capacity of the selection it precedes.
+type RenewalCampaign struct {
+	Id           primitive.ObjectID `json:"id" bson:"_id"`
+	AcademicYear string             `json:"academicYear" bson:"academicYear" validate:"required"`
+	OpensAt      time.Time          `json:"opensAt" bson:"opensAt" validate:"required"`
+	ClosesAt     time.Time          `json:"closesAt" bson:"closesAt" validate:"required,gtfield=OpensAt"`
+	// MinESBP is the number of ESBP points a resident needs to renew.
+	MinESBP int `json:"minEsbp" bson:"minEsbp" validate:"min=0"`
+	// SelectionId is the open selection that follows the campaign.
+	SelectionId *primitive.ObjectID `json:"selectionId,omitempty" bson:"selectionId,omitempty"`
+	CreatedBy   string              `json:"createdBy" bson:"createdBy"`
+	CreatedAt   time.Time           `json:"createdAt" bson:"createdAt"`
+}
+
+// IsOpen reports whether residents can renew at the given time.
+func (rc *RenewalCampaign) IsOpen(at time.Time) bool {
+	return !at.Before(rc.OpensAt) && at.Before(rc.ClosesAt)
+}
+
+// Renewal is a resident's place kept for the next academic year.
+type Renewal struct {
+	Id          primitive.ObjectID `json:"id" bson:"_id"`
+	CampaignId  primitive.ObjectID `json:"campaignId" bson:"campaignId"`
+	StudentId   primitive.ObjectID `json:"studentId" bson:"studentId"`
+	StudentName string             `json:"studentName" bson:"studentName"`
+	BuildingId  primitive.ObjectID `json:"buildingId" bson:"buildingId"`
+	RoomNumber  int                `json:"roomNumber" bson:"roomNumber"`
+	Status      string             `json:"status" bson:"status"`
+	Academic    *AcademicRecord    `json:"academic,omitempty" bson:"academic,omitempty"`
+	RenewedAt   time.Time          `json:"renewedAt" bson:"renewedAt"`
+	CancelledAt *time.Time         `json:"cancelledAt,omitempty" bson:"cancelledAt,omitempty"`
+}
+
+// RenewalEligibility tells a resident whether they are in good standing to renew
+// and, if not, why.
+type RenewalEligibility struct {
+	Eligible    bool     `json:"eligible"`
+	Reasons     []string `json:"reasons"`
+	Outstanding float64  `json:"outstanding"`
+	Enrolled    bool     `json:"enrolled"`
+	ESBP        int      `json:"esbp"`
+	MinESBP     int      `json:"minEsbp"`
+}
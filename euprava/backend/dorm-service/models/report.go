@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Occupancy reports can be broken down by building, by floor or by room type.
+const (
+	ReportByBuilding = "building"
+	ReportByFloor    = "floor"
+	ReportByRoomType = "roomType"
+)
+
+// OccupancyRow is one line of an occupancy report. Month is set on trend and
+// projection lines only.
+type OccupancyRow struct {
+	Month        string             `json:"month,omitempty"`
+	BuildingId   primitive.ObjectID `json:"buildingId"`
+	BuildingName string             `json:"buildingName"`
+	Floor        *int               `json:"floor,omitempty"`
+	RoomType     string             `json:"roomType,omitempty"`
+	Capacity     int                `json:"capacity"`
+	Occupied     int                `json:"occupied"`
+	Free         int                `json:"free"`
+	// OccupancyRate is the percentage of beds taken.
+	OccupancyRate float64 `json:"occupancyRate"`
+	// PlannedCheckOuts counts residents expected to leave during the month.
+	PlannedCheckOuts int `json:"plannedCheckOuts,omitempty"`
+}
+
+// Fill works out the free beds and the occupancy rate from capacity and occupied.
+func (r *OccupancyRow) Fill() {
+	if r.Occupied < 0 {
+		r.Occupied = 0
+	}
+	r.Free = r.Capacity - r.Occupied
+	if r.Free < 0 {
+		r.Free = 0
+	}
+	r.OccupancyRate = 0
+	if r.Capacity > 0 {
+		r.OccupancyRate = RoundMoney(100 * float64(r.Occupied) / float64(r.Capacity))
+	}
+}
+
+type OccupancyReport struct {
+	GeneratedAt time.Time       `json:"generatedAt"`
+	GroupBy     string          `json:"groupBy"`
+	Rows        []*OccupancyRow `json:"rows"`
+	// Totals has one line per month, or a single line for the current state.
+	Totals []*OccupancyRow `json:"totals"`
+}
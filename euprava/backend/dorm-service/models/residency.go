@@ -0,0 +1,62 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Residency records a stay of a student in a room, from check-in until check-out.
+type Residency struct {
+	Id          primitive.ObjectID `json:"id" bson:"_id"`
+	StudentId   primitive.ObjectID `json:"studentId" bson:"studentId"`
+	StudentName string             `json:"studentName" bson:"studentName"`
+	BuildingId  primitive.ObjectID `json:"buildingId" bson:"buildingId"`
+	RoomNumber  int                `json:"roomNumber" bson:"roomNumber"`
+	From        time.Time          `json:"from" bson:"from"`
+	To          *time.Time         `json:"to,omitempty" bson:"to,omitempty"`
+	// PlannedTo is when the student is expected to check out, if known.
+	PlannedTo *time.Time `json:"plannedTo,omitempty" bson:"plannedTo,omitempty"`
+	// Active is true until the student checks out or moves to another room.
+	Active       bool   `json:"active" bson:"active"`
+	Reason       string `json:"reason,omitempty" bson:"reason,omitempty"`
+	CheckedInBy  string `json:"checkedInBy" bson:"checkedInBy"`
+	CheckedOutBy string `json:"checkedOutBy,omitempty" bson:"checkedOutBy,omitempty"`
+}
+
+type CheckInRequest struct {
+	StudentId  string `json:"studentId" validate:"required"`
+	BuildingId string `json:"buildingId" validate:"required"`
+	RoomNumber int    `json:"roomNumber" validate:"required,min=1"`
+	// Date defaults to now; it can be set to record a check-in after the fact.
+	Date      *time.Time `json:"date"`
+	PlannedTo *time.Time `json:"plannedTo"`
+	// DepositToken pays the security deposit at the desk if it was not paid yet.
+	DepositToken string `json:"depositToken"`
+}
+
+// PlannedCheckOutRequest sets when a resident is expected to leave; a missing date
+// clears it.
+type PlannedCheckOutRequest struct {
+	StudentId string     `json:"studentId" validate:"required"`
+	Date      *time.Time `json:"date"`
+}
+
+type CheckOutRequest struct {
+	StudentId string     `json:"studentId" validate:"required"`
+	Reason    string     `json:"reason" validate:"required"`
+	Date      *time.Time `json:"date"`
+}
+
+// FullName returns the student's first and last name.
+func (s *Student) FullName() string {
+	var parts []string
+	if s.First_name != nil {
+		parts = append(parts, *s.First_name)
+	}
+	if s.Last_name != nil {
+		parts = append(parts, *s.Last_name)
+	}
+	return strings.Join(parts, " ")
+}
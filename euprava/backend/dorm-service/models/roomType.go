@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RoomType describes a kind of room, e.g. a single or a double with a private bathroom.
+type RoomType struct {
+	Id           primitive.ObjectID `json:"id" bson:"_id"`
+	Name         string             `json:"name" bson:"name" validate:"required"`
+	Description  string             `json:"description,omitempty" bson:"description,omitempty"`
+	Amenities    []string           `json:"amenities" bson:"amenities"`
+	MonthlyPrice float64            `json:"monthly_price,omitempty" bson:"monthlyPrice,omitempty" validate:"min=0"`
+}
+
+type RoomTypes []*RoomType
+
+// ById indexes room types by their id.
+func (t RoomTypes) ById() map[primitive.ObjectID]*RoomType {
+	byId := make(map[primitive.ObjectID]*RoomType, len(t))
+	for _, roomType := range t {
+		byId[roomType.Id] = roomType
+	}
+	return byId
+}
+
+// RoomPrice returns the monthly price of a room. A price set on the room wins over
+// the price of its room type, which wins over the building price.
+func (b *Building) RoomPrice(room *Room, types map[primitive.ObjectID]*RoomType) float64 {
+	if room.MonthlyPrice > 0 {
+		return room.MonthlyPrice
+	}
+	if roomType, ok := types[room.RoomTypeId]; ok && roomType.MonthlyPrice > 0 {
+		return roomType.MonthlyPrice
+	}
+	return b.Price
+}
+
+// RoomAssignment records the room a student was placed in and what it costs.
+type RoomAssignment struct {
+	BuildingId   primitive.ObjectID `json:"buildingId" bson:"buildingId"`
+	RoomNumber   int                `json:"roomNumber" bson:"roomNumber"`
+	RoomTypeId   primitive.ObjectID `json:"roomTypeId,omitempty" bson:"roomTypeId,omitempty"`
+	MonthlyPrice float64            `json:"monthlyPrice" bson:"monthlyPrice"`
+	AssignedAt   time.Time          `json:"assignedAt" bson:"assignedAt"`
+	// PreferenceRank is the 1-based position of the room type in the student's
+	// preferences, or 0 if none of their preferences could be met.
+	PreferenceRank int `json:"preferenceRank" bson:"preferenceRank"`
+}
+
+type ApplicationRequest struct {
+	RoomTypePreferences []primitive.ObjectID `json:"roomTypePreferences"`
+	BuildingPreferences []primitive.ObjectID `json:"buildingPreferences"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	SwapProposed  = "Proposed"
+	SwapAccepted  = "Accepted"
+	SwapDeclined  = "Declined"
+	SwapApproved  = "Approved"
+	SwapRejected  = "Rejected"
+	SwapCancelled = "Cancelled"
+)
+
+// SwapSide is one of the residents in a room swap and the room they lived in when
+// the swap was proposed.
+type SwapSide struct {
+	StudentId   primitive.ObjectID `json:"studentId" bson:"studentId"`
+	StudentName string             `json:"studentName" bson:"studentName"`
+	BuildingId  primitive.ObjectID `json:"buildingId" bson:"buildingId"`
+	RoomNumber  int                `json:"roomNumber" bson:"roomNumber"`
+}
+
+// RoomSwap is a request of two residents to swap rooms. The requester proposes
+// it, the partner accepts it and an admin approves it, which moves both students.
+type RoomSwap struct {
+	Id        primitive.ObjectID `json:"id" bson:"_id"`
+	Requester SwapSide           `json:"requester" bson:"requester"`
+	Partner   SwapSide           `json:"partner" bson:"partner"`
+	Reason    string             `json:"reason,omitempty" bson:"reason,omitempty"`
+	Status    string             `json:"status" bson:"status"`
+	// Open is true while the swap is waiting for the partner or an admin. A student
+	// can have only one open proposal at a time.
+	Open         bool       `json:"-" bson:"open"`
+	CreatedAt    time.Time  `json:"createdAt" bson:"createdAt"`
+	RespondedAt  *time.Time `json:"respondedAt,omitempty" bson:"respondedAt,omitempty"`
+	DecidedBy    string     `json:"decidedBy,omitempty" bson:"decidedBy,omitempty"`
+	DecidedAt    *time.Time `json:"decidedAt,omitempty" bson:"decidedAt,omitempty"`
+	DecisionNote string     `json:"decisionNote,omitempty" bson:"decisionNote,omitempty"`
+}
+
+type SwapRequest struct {
+	PartnerId string `json:"partnerId" validate:"required"`
+	Reason    string `json:"reason"`
+}
+
+type SwapResponse struct {
+	Accept bool `json:"accept"`
+}
+
+type SwapDecision struct {
+	Approve bool   `json:"approve"`
+	Note    string `json:"note"`
+}
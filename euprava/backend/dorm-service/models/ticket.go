@@ -0,0 +1,198 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	TicketOpen       = "Open"
+	TicketAssigned   = "Assigned"
+	TicketInProgress = "InProgress"
+	TicketResolved   = "Resolved"
+)
+
+// ticketTransitions lists, for every ticket status, the statuses it may move to.
+// A resolved ticket can be reopened if the problem comes back.
+var ticketTransitions = map[string][]string{
+	TicketOpen:       {TicketAssigned},
+	TicketAssigned:   {TicketAssigned, TicketInProgress, TicketOpen},
+	TicketInProgress: {TicketResolved, TicketAssigned},
+	TicketResolved:   {TicketOpen},
+}
+
+// CanTransitionTicket reports whether a ticket may move from one status to another.
+func CanTransitionTicket(from string, to string) bool {
+	for _, allowed := range ticketTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	PriorityLow    = "Low"
+	PriorityMedium = "Medium"
+	PriorityHigh   = "High"
+	PriorityUrgent = "Urgent"
+)
+
+// TicketCategories are the kinds of problems residents can report.
+var TicketCategories = []string{"Plumbing", "Electrical", "Heating", "Furniture", "Appliances", "Cleaning", "Internet", "Other"}
+
+// SLA is how quickly a ticket of some priority has to be assigned and resolved.
+type SLA struct {
+	Assign  time.Duration
+	Resolve time.Duration
+}
+
+var SLAs = map[string]SLA{
+	PriorityUrgent: {Assign: 2 * time.Hour, Resolve: 24 * time.Hour},
+	PriorityHigh:   {Assign: 8 * time.Hour, Resolve: 3 * 24 * time.Hour},
+	PriorityMedium: {Assign: 24 * time.Hour, Resolve: 7 * 24 * time.Hour},
+	PriorityLow:    {Assign: 3 * 24 * time.Hour, Resolve: 14 * 24 * time.Hour},
+}
+
+// Ticket is a maintenance request for a room or a building.
+type Ticket struct {
+	Id         primitive.ObjectID `json:"id" bson:"_id"`
+	BuildingId primitive.ObjectID `json:"buildingId" bson:"buildingId"`
+	// RoomNumber is 0 for problems in shared areas of the building.
+	RoomNumber     int             `json:"roomNumber" bson:"roomNumber"`
+	Category       string          `json:"category" bson:"category"`
+	Priority       string          `json:"priority" bson:"priority"`
+	Title          string          `json:"title" bson:"title"`
+	Description    string          `json:"description" bson:"description"`
+	Photos         []string        `json:"photos" bson:"photos"`
+	Status         string          `json:"status" bson:"status"`
+	ReportedBy     string          `json:"reportedBy" bson:"reportedBy"`
+	ReporterRole   string          `json:"reporterRole" bson:"reporterRole"`
+	AssignedTo     string          `json:"assignedTo,omitempty" bson:"assignedTo,omitempty"`
+	AssignedToName string          `json:"assignedToName,omitempty" bson:"assignedToName,omitempty"`
+	Comments       []TicketComment `json:"comments" bson:"comments"`
+	History        []StatusChange  `json:"history" bson:"history"`
+	CreatedAt      time.Time       `json:"createdAt" bson:"createdAt"`
+	UpdatedAt      time.Time       `json:"updatedAt" bson:"updatedAt"`
+	AssignedAt     *time.Time      `json:"assignedAt,omitempty" bson:"assignedAt,omitempty"`
+	ResolvedAt     *time.Time      `json:"resolvedAt,omitempty" bson:"resolvedAt,omitempty"`
+}
+
+type TicketComment struct {
+	Id         primitive.ObjectID `json:"id" bson:"_id"`
+	AuthorId   string             `json:"authorId" bson:"authorId"`
+	AuthorName string             `json:"authorName" bson:"authorName"`
+	AuthorRole string             `json:"authorRole" bson:"authorRole"`
+	Text       string             `json:"text" bson:"text" validate:"required"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+type TicketRequest struct {
+	// BuildingId and RoomNumber are only read when an admin reports a problem;
+	// residents always report for the room they live in.
+	BuildingId  string `json:"buildingId"`
+	RoomNumber  int    `json:"roomNumber" validate:"min=0"`
+	Category    string `json:"category" validate:"required,oneof=Plumbing Electrical Heating Furniture Appliances Cleaning Internet Other"`
+	Priority    string `json:"priority" validate:"required,oneof=Low Medium High Urgent"`
+	Title       string `json:"title" validate:"required,max=120"`
+	Description string `json:"description" validate:"required"`
+}
+
+type TicketAssignRequest struct {
+	StaffId   string `json:"staffId" validate:"required"`
+	StaffName string `json:"staffName"`
+	Reason    string `json:"reason"`
+}
+
+// AssignDeadline is when the ticket has to be assigned according to its SLA.
+func (t *Ticket) AssignDeadline() time.Time {
+	return t.CreatedAt.Add(SLAs[t.Priority].Assign)
+}
+
+// ResolveDeadline is when the ticket has to be resolved according to its SLA.
+func (t *Ticket) ResolveDeadline() time.Time {
+	return t.CreatedAt.Add(SLAs[t.Priority].Resolve)
+}
+
+// ResolutionBreached reports whether the ticket was, or by now is, late to be resolved.
+func (t *Ticket) ResolutionBreached(now time.Time) bool {
+	if t.ResolvedAt != nil {
+		return t.ResolvedAt.After(t.ResolveDeadline())
+	}
+	return now.After(t.ResolveDeadline())
+}
+
+// SLAStats sums up how a group of tickets did against their SLAs.
+type SLAStats struct {
+	Total             int     `json:"total"`
+	Unresolved        int     `json:"unresolved"`
+	Resolved          int     `json:"resolved"`
+	AssignedLate      int     `json:"assignedLate"`
+	ResolutionBreach  int     `json:"resolutionBreaches"`
+	AvgHoursToAssign  float64 `json:"avgHoursToAssign"`
+	AvgHoursToResolve float64 `json:"avgHoursToResolve"`
+	// Compliance is the share of tickets resolved, or still open, within their SLA.
+	Compliance float64 `json:"compliance"`
+
+	assignHours  float64
+	assigned     int
+	resolveHours float64
+}
+
+// Add counts a ticket into the stats.
+func (s *SLAStats) Add(t *Ticket, now time.Time) {
+	s.Total++
+	if t.AssignedAt != nil {
+		s.assigned++
+		s.assignHours += t.AssignedAt.Sub(t.CreatedAt).Hours()
+		if t.AssignedAt.After(t.AssignDeadline()) {
+			s.AssignedLate++
+		}
+	} else if now.After(t.AssignDeadline()) {
+		s.AssignedLate++
+	}
+	if t.Status == TicketResolved && t.ResolvedAt != nil {
+		s.Resolved++
+		s.resolveHours += t.ResolvedAt.Sub(t.CreatedAt).Hours()
+	} else {
+		s.Unresolved++
+	}
+	if t.ResolutionBreached(now) {
+		s.ResolutionBreach++
+	}
+}
+
+// Finish computes the averages once all tickets are added.
+func (s *SLAStats) Finish() {
+	if s.assigned > 0 {
+		s.AvgHoursToAssign = round2(s.assignHours / float64(s.assigned))
+	}
+	if s.Resolved > 0 {
+		s.AvgHoursToResolve = round2(s.resolveHours / float64(s.Resolved))
+	}
+	if s.Total > 0 {
+		s.Compliance = round2(float64(s.Total-s.ResolutionBreach) / float64(s.Total))
+	}
+}
+
+// BuildingSLAReport is the SLA report for the tickets of one building.
+type BuildingSLAReport struct {
+	BuildingId   primitive.ObjectID   `json:"buildingId"`
+	BuildingName string               `json:"buildingName"`
+	From         time.Time            `json:"from"`
+	To           time.Time            `json:"to"`
+	Overall      *SLAStats            `json:"overall"`
+	ByPriority   map[string]*SLAStats `json:"byPriority"`
+	ByCategory   map[string]*SLAStats `json:"byCategory"`
+}
+
+func round2(x float64) float64 {
+	return math.Round(x*100) / 100
+}
+
+// TicketPhotoPath is the route a ticket photo is served from.
+func TicketPhotoPath(ticketId primitive.ObjectID, name string) string {
+	return "/tickets/" + ticketId.Hex() + "/photos/" + name
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	VisitPending   = "Pending"
+	VisitApproved  = "Approved"
+	VisitRejected  = "Rejected"
+	VisitCancelled = "Cancelled"
+	VisitArrived   = "Arrived"
+	VisitLeft      = "Left"
+)
+
+// VisitCurfew and VisitCurfewEnds are the hours between which guests need an
+// approved overnight stay.
+const (
+	VisitCurfew     = 23
+	VisitCurfewEnds = 7
+)
+
+// MaxVisitLength is the longest stay a single visit can be registered for.
+const MaxVisitLength = 72 * time.Hour
+
+// DefaultMaxOvernightStays applies to buildings that do not set their own limit.
+const DefaultMaxOvernightStays = 4
+
+// MaxOvernightStaysPerMonth returns the monthly overnight stay limit of a resident.
+func (b *Building) MaxOvernightStaysPerMonth() int {
+	if b.MaxOvernightStays > 0 {
+		return b.MaxOvernightStays
+	}
+	return DefaultMaxOvernightStays
+}
+
+// Visit is a guest a resident registered in advance. Day visits are approved on
+// registration, overnight stays wait for an admin.
+type Visit struct {
+	Id            primitive.ObjectID `json:"id" bson:"_id"`
+	StudentId     primitive.ObjectID `json:"studentId" bson:"studentId"`
+	StudentName   string             `json:"studentName" bson:"studentName"`
+	BuildingId    primitive.ObjectID `json:"buildingId" bson:"buildingId"`
+	RoomNumber    int                `json:"roomNumber" bson:"roomNumber"`
+	GuestName     string             `json:"guestName" bson:"guestName"`
+	GuestDocument string             `json:"guestDocument" bson:"guestDocument"`
+	From          time.Time          `json:"from" bson:"from"`
+	To            time.Time          `json:"to" bson:"to"`
+	Overnight     bool               `json:"overnight" bson:"overnight"`
+	Note          string             `json:"note,omitempty" bson:"note,omitempty"`
+	Status        string             `json:"status" bson:"status"`
+	DecidedBy     string             `json:"decidedBy,omitempty" bson:"decidedBy,omitempty"`
+	DecidedAt     *time.Time         `json:"decidedAt,omitempty" bson:"decidedAt,omitempty"`
+	DecisionNote  string             `json:"decisionNote,omitempty" bson:"decisionNote,omitempty"`
+	ArrivedAt     *time.Time         `json:"arrivedAt,omitempty" bson:"arrivedAt,omitempty"`
+	LeftAt        *time.Time         `json:"leftAt,omitempty" bson:"leftAt,omitempty"`
+	CreatedAt     time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+type VisitRequest struct {
+	GuestName string `json:"guestName" validate:"required"`
+	// GuestDocument is the number of the guest's ID card or passport.
+	GuestDocument string    `json:"guestDocument" validate:"required"`
+	From          time.Time `json:"from" validate:"required"`
+	To            time.Time `json:"to" validate:"required,gtfield=From"`
+	Note          string    `json:"note"`
+}
+
+type VisitDecision struct {
+	Approve bool   `json:"approve"`
+	Note    string `json:"note"`
+}
+
+// IsOvernight reports whether a visit from..to falls into curfew hours.
+func IsOvernight(from time.Time, to time.Time) bool {
+	curfew := time.Date(from.Year(), from.Month(), from.Day(), VisitCurfew, 0, 0, 0, from.Location())
+	return from.Hour() < VisitCurfewEnds || to.After(curfew)
+}
+
+// OvernightStatuses are the visit statuses that use up one of the resident's
+// overnight stays.
+var OvernightStatuses = []string{VisitPending, VisitApproved, VisitArrived, VisitLeft}
@@ -0,0 +1,89 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrPaymentDeclined = errors.New("payment declined")
+
+type ChargeRequest struct {
+	PayerId     string
+	Amount      float64
+	Description string
+	// Token identifies the payment method, e.g. a card token from the provider's checkout.
+	Token string
+}
+
+type ChargeResult struct {
+	Provider  string
+	Reference string
+	ChargedAt time.Time
+}
+
+// Provider is implemented by every payment provider dorm-service can take payments through.
+type Provider interface {
+	Name() string
+	Charge(ctx context.Context, req ChargeRequest) (*ChargeResult, error)
+	Refund(ctx context.Context, reference string, amount float64) error
+}
+
+// FakeProvider accepts every payment except those made with the "decline" token.
+// It is meant for local development and demos.
+type FakeProvider struct {
+	mu       sync.Mutex
+	payments map[string]float64
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{payments: map[string]float64{}}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) Charge(ctx context.Context, req ChargeRequest) (*ChargeResult, error) {
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+	if req.Token == "decline" {
+		return nil, ErrPaymentDeclined
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	reference := "fake_" + primitive.NewObjectID().Hex()
+	p.payments[reference] = req.Amount
+	return &ChargeResult{Provider: p.Name(), Reference: reference, ChargedAt: time.Now()}, nil
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, reference string, amount float64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	charged, ok := p.payments[reference]
+	if !ok {
+		return fmt.Errorf("unknown payment %s", reference)
+	}
+	if amount > charged {
+		return fmt.Errorf("cannot refund more than was charged")
+	}
+	p.payments[reference] = charged - amount
+	return nil
+}
+
+// NewProvider returns the provider selected by the PAYMENT_PROVIDER environment variable.
+func NewProvider() (Provider, error) {
+	switch name := os.Getenv("PAYMENT_PROVIDER"); name {
+	case "", "fake":
+		return NewFakeProvider(), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider: %s", name)
+	}
+}
@@ -10,21 +10,148 @@ import (
 func MainRoutes(routes *gin.Engine, dc controllers.DormController) {
 	routes.Use(middleware.Authentication())
 
-	routes.GET("/applications", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.GetAllApplications())
+	routes.GET("/applications", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER"}, dc.SelectionQueryScope()), dc.GetAllApplications())
 	routes.GET("/application", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.GetApplication())
 	routes.POST("/applications/create/:selectionId", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.InsertApplication())
 	routes.DELETE("/application/:id", middleware.AuthorizeRoles([]string{"STUDENT", "ADMIN"}), dc.DeleteApplication())
+	routes.PUT("/application/:id/status", middleware.AuthorizeRoles([]string{"STUDENT"}), dc.UpdateMyApplicationStatus())
+	routes.POST("/application/:id/appeal", middleware.AuthorizeRoles([]string{"STUDENT"}), dc.FileAppeal())
+	routes.POST("/applications/bulk-status", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.BulkUpdateApplicationStatus())
+	routes.POST("/applications/:id/documents", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.UploadApplicationDocument())
+	routes.GET("/applications/:id/documents/:docId", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER", "STUDENT"}, dc.ApplicationScope()), dc.GetApplicationDocument())
+	routes.DELETE("/applications/:id/documents/:docId", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.DeleteApplicationDocument())
+	routes.PUT("/applications/:id/documents/:docId/review", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER"}, dc.ApplicationScope()), dc.ReviewApplicationDocument())
+	routes.GET("/applications/:id", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER"}, dc.ApplicationScope()), dc.GetApplicationById())
+	routes.PUT("/applications/:id/status", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER"}, dc.ApplicationScope()), dc.UpdateApplicationStatus())
 
-	routes.GET("/building/:id", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.GetBuilding())
+	routes.GET("/appeals", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.GetAppeals())
+	routes.PUT("/appeals/:id/resolve", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.ResolveAppeal())
+
+	routes.GET("/building/:id", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER", "STUDENT"}, dc.BuildingScope("id")), dc.GetBuilding())
 	routes.POST("/building", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.InsertBuilding())
 	routes.DELETE("/building/:id", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.DeleteBuilding())
-	//	routes.PUT("/buildings/:id", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.UpdateBuilding())
+	routes.PUT("/building/:id", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER"}, dc.BuildingScope("id")), dc.UpdateBuilding())
+	routes.PUT("/building/:id/managers", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.SetBuildingManagers())
+	routes.GET("/my-buildings", middleware.AuthorizeRoles([]string{"MANAGER"}, middleware.Own), dc.GetManagedBuildings())
+
+	routes.GET("building/:id/room/:number", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER", "STUDENT"}, dc.BuildingScope("id")), dc.GetRoom())
+	routes.POST("building/:id/room", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER", "STUDENT"}, dc.BuildingScope("id")), dc.InsertRoom())
+	routes.PUT("building/:id/room/:number", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER"}, dc.BuildingScope("id")), dc.UpdateRoom())
+	routes.DELETE("building/:id/room/:number", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER"}, dc.BuildingScope("id")), dc.DeleteRoom())
+	routes.POST("/rooms/move", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER"}, dc.BodyScope()), dc.MoveStudent())
+
+	routes.POST("/residencies/check-in", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER"}, dc.BodyScope()), dc.CheckIn())
+	routes.POST("/residencies/check-out", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER"}, dc.BodyScope()), dc.CheckOut())
+	routes.PUT("/residencies/planned-check-out", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER"}, dc.BodyScope()), dc.SetPlannedCheckOut())
+	routes.GET("/residencies", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.GetStudentResidencies())
+	routes.GET("/residencies/confirmation", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.GetResidencyConfirmation())
+	routes.GET("building/:id/room/:number/residencies", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER"}, dc.BuildingScope("id")), dc.GetRoomResidencies())
+
+	routes.GET("building/:id/room/:number/checklist", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.GetRoomChecklist())
+	routes.POST("/deposit", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.TakeDeposit())
+	routes.GET("/deposits", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.GetDeposits())
+	routes.GET("/my-deposits", middleware.AuthorizeRoles([]string{"STUDENT"}), dc.GetMyDeposits())
+	routes.POST("/deposits/:studentId/settle", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.SettleDeposit())
+	routes.GET("/settlements", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.GetSettlements())
+	routes.GET("/settlements/:id/statement", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.GetSettlementStatement())
+	routes.POST("/inspections", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.InsertInspection())
+	routes.GET("/inspections", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.GetInspections())
+	routes.GET("/inspections/:id", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.GetInspection())
+	routes.POST("/inspections/:id/photos", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.UploadInspectionPhoto())
+	routes.GET("/inspections/:id/photos/:name", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.GetInspectionPhoto())
+
+	routes.POST("/tickets", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.InsertTicket())
+	routes.GET("/tickets", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER", "MAINTENANCE"}, dc.BuildingQueryScope()), dc.GetTickets())
+	routes.GET("/tickets/sla", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.GetSLAReport())
+	routes.GET("/my-tickets", middleware.AuthorizeRoles([]string{"STUDENT"}), dc.GetMyTickets())
+	routes.GET("/tickets/:id", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER", "MAINTENANCE", "STUDENT"}, dc.TicketScope()), dc.GetTicket())
+	routes.PUT("/tickets/:id/assign", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER"}, dc.TicketScope()), dc.AssignTicket())
+	routes.PUT("/tickets/:id/status", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER", "MAINTENANCE"}, dc.TicketScope()), dc.UpdateTicketStatus())
+	routes.POST("/tickets/:id/comments", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER", "MAINTENANCE", "STUDENT"}, dc.TicketScope()), dc.AddTicketComment())
+	routes.POST("/tickets/:id/photos", middleware.AuthorizeRoles([]string{"ADMIN", "MAINTENANCE", "STUDENT"}), dc.UploadTicketPhoto())
+	routes.GET("/tickets/:id/photos/:name", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER", "MAINTENANCE", "STUDENT"}, dc.TicketScope()), dc.GetTicketPhoto())
+
+	routes.POST("/building/:id/facilities", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.InsertFacility())
+	routes.GET("/building/:id/facilities", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.GetFacilities())
+	routes.PUT("/facilities/:id", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.UpdateFacility())
+	routes.DELETE("/facilities/:id", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.DeleteFacility())
+	routes.GET("/facilities/:id/slots", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.GetFacilitySlots())
+	routes.POST("/facilities/:id/bookings", middleware.AuthorizeRoles([]string{"STUDENT"}), dc.BookFacility())
+	routes.GET("/facilities/:id/bookings", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.GetFacilityBookings())
+	routes.GET("/my-bookings", middleware.AuthorizeRoles([]string{"STUDENT"}), dc.GetMyBookings())
+	routes.DELETE("/bookings/:id", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.CancelBooking())
+	routes.POST("/bookings/:id/check-in", middleware.AuthorizeRoles([]string{"STUDENT"}), dc.CheckInBooking())
+	routes.PUT("/bookings/:id/no-show", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.MarkBookingNoShow())
+
+	routes.GET("building/:id/room/:number/occupants", middleware.AuthorizeRoles([]string{"ADMIN", "RECEPTION"}), dc.GetRoomOccupants())
+	routes.POST("/parcels", middleware.AuthorizeRoles([]string{"ADMIN", "RECEPTION"}), dc.RegisterParcel())
+	routes.GET("/parcels", middleware.AuthorizeRoles([]string{"ADMIN", "RECEPTION"}), dc.GetParcels())
+	routes.GET("/my-parcels", middleware.AuthorizeRoles([]string{"STUDENT"}), dc.GetMyParcels())
+	routes.POST("/parcels/:id/handover", middleware.AuthorizeRoles([]string{"ADMIN", "RECEPTION"}), dc.HandOverParcel())
+	routes.POST("/parcels/:id/reissue-code", middleware.AuthorizeRoles([]string{"ADMIN", "RECEPTION", "STUDENT"}), dc.ReissuePickupCode())
+	routes.POST("/parcels/:id/return", middleware.AuthorizeRoles([]string{"ADMIN", "RECEPTION"}), dc.ReturnParcel())
+
+	routes.POST("/visits", middleware.AuthorizeRoles([]string{"STUDENT"}), dc.RegisterVisit())
+	routes.GET("/my-visits", middleware.AuthorizeRoles([]string{"STUDENT"}), dc.GetMyVisits())
+	routes.GET("/visits", middleware.AuthorizeRoles([]string{"ADMIN", "RECEPTION"}), dc.GetVisits())
+	routes.GET("building/:id/expected-guests", middleware.AuthorizeRoles([]string{"ADMIN", "RECEPTION"}), dc.GetExpectedGuests())
+	routes.POST("/visits/:id/decision", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.DecideVisit())
+	routes.POST("/visits/:id/cancel", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.CancelVisit())
+	routes.POST("/visits/:id/arrived", middleware.AuthorizeRoles([]string{"ADMIN", "RECEPTION"}), dc.GuestArrived())
+	routes.POST("/visits/:id/left", middleware.AuthorizeRoles([]string{"ADMIN", "RECEPTION"}), dc.GuestLeft())
+
+	routes.POST("/swaps", middleware.AuthorizeRoles([]string{"STUDENT"}), dc.ProposeSwap())
+	routes.GET("/my-swaps", middleware.AuthorizeRoles([]string{"STUDENT"}), dc.GetMySwaps())
+	routes.GET("/swaps", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.GetRoomSwaps())
+	routes.POST("/swaps/:id/respond", middleware.AuthorizeRoles([]string{"STUDENT"}), dc.RespondToSwap())
+	routes.POST("/swaps/:id/cancel", middleware.AuthorizeRoles([]string{"STUDENT"}), dc.CancelSwap())
+	routes.POST("/swaps/:id/decision", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.DecideSwap())
+
+	routes.POST("/renewal-campaigns", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.InsertRenewalCampaign())
+	routes.GET("/renewal-campaigns", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.GetRenewalCampaigns())
+	routes.GET("/renewal-campaigns/:id/eligibility", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.GetRenewalEligibility())
+	routes.POST("/renewal-campaigns/:id/renew", middleware.AuthorizeRoles([]string{"STUDENT"}), dc.RenewPlace())
+	routes.GET("/renewal-campaigns/:id/renewals", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.GetCampaignRenewals())
+	routes.GET("/my-renewals", middleware.AuthorizeRoles([]string{"STUDENT"}), dc.GetMyRenewals())
+	routes.POST("/renewals/:id/cancel", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.CancelRenewal())
+
+	routes.POST("/incidents", middleware.AuthorizeRoles([]string{"ADMIN", "RECEPTION", "MAINTENANCE"}), dc.ReportIncident())
+	routes.GET("/incidents", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.GetIncidents())
+	routes.GET("/my-incidents", middleware.AuthorizeRoles([]string{"STUDENT"}), dc.GetMyIncidents())
+	routes.GET("/incidents/:id", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.GetIncident())
+	routes.PUT("/incidents/:id/statement", middleware.AuthorizeRoles([]string{"STUDENT"}), dc.SubmitStatement())
+	routes.PUT("/incidents/:id/hearing", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.ScheduleHearing())
+	routes.PUT("/incidents/:id/decision", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.DecideIncident())
+	routes.POST("/incidents/:id/pay-fine", middleware.AuthorizeRoles([]string{"STUDENT"}), dc.PayFine())
+
+	routes.GET("/reports/occupancy", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER"}, dc.BuildingQueryScope()), dc.GetOccupancyReport())
+	routes.GET("/reports/occupancy/trend", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER"}, dc.BuildingQueryScope()), dc.GetOccupancyTrend())
+	routes.GET("/reports/vacancies", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER"}, dc.BuildingQueryScope()), dc.GetVacancyProjection())
+
+	routes.GET("/room-types", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.GetRoomTypes())
+	routes.POST("/room-type", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.InsertRoomType())
+	routes.PUT("/room-type/:id", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.UpdateRoomType())
+	routes.DELETE("/room-type/:id", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.DeleteRoomType())
+
+	routes.POST("/invoices/generate", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.GenerateInvoicesHandler())
+	routes.POST("/invoices/reminders", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.SendRemindersHandler())
+	routes.GET("/invoices", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.GetInvoices())
+	routes.GET("/invoices/arrears", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.GetArrears())
+	routes.POST("/invoices/:id/pay", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.PayInvoice())
+	routes.GET("/my-invoices", middleware.AuthorizeRoles([]string{"STUDENT"}), dc.GetMyInvoices())
+	routes.GET("/my-ledger", middleware.AuthorizeRoles([]string{"STUDENT"}), dc.GetMyLedger())
+	routes.GET("/ledger/:studentId", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.GetStudentLedger())
 
-	routes.GET("building/:id/room/:number", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.GetRoom())
-	routes.POST("building/:id/room", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.InsertRoom())
+	routes.GET("/notifications", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.GetMyNotifications())
+	routes.PUT("/notifications/:id/read", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.MarkNotificationRead())
 
-	routes.GET("selection/:id", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.GetSelection())
-	routes.POST("selection/:buildingId", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.InsertSelection())
-	routes.PUT("selection/:id", middleware.AuthorizeRoles([]string{"ADMIN", "STUDENT"}), dc.UpdateSelection())
-	routes.DELETE("selection/:id", middleware.AuthorizeRoles([]string{"ADMIN"}), dc.DeleteSelection())
+	routes.GET("selection/:id", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER", "STUDENT"}, dc.SelectionScope("id")), dc.GetSelection())
+	routes.POST("selection/:id", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER", "STUDENT"}, dc.BuildingScope("id"), dc.BodyScope()), dc.InsertSelection())
+	routes.PUT("selection/:id", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER", "STUDENT"}, dc.SelectionScope("id"), dc.BodyScope()), dc.UpdateSelection())
+	routes.DELETE("selection/:id", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER"}, dc.SelectionScope("id")), dc.DeleteSelection())
+	routes.POST("selection/:id/process", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER"}, dc.SelectionScope("id")), dc.ProcessSelection())
+	routes.POST("selection/:id/assign", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER"}, dc.SelectionScope("id")), dc.AssignSelection())
+	routes.POST("selection/:id/ranking", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER"}, dc.SelectionScope("id")), dc.PublishRanking())
+	routes.GET("selection/:id/ranking", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER", "STUDENT"}, dc.SelectionScope("id")), dc.GetRanking())
+	routes.GET("selection/:id/ranking/export", middleware.AuthorizeRoles([]string{"ADMIN", "MANAGER"}, dc.SelectionScope("id")), dc.ExportRanking())
 }
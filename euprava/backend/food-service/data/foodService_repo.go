@@ -59,6 +59,22 @@ func NewFoodServiceRepo(ctx context.Context, logger *log.Logger) (*FoodServiceRe
 	}, nil
 }
 
+// inTransaction izvrsava fn u transakciji i ponavlja je pri prolaznim greskama.
+// Baza radi kao replica set sa jednim cvorom (vidi docker-compose.yml) da bi
+// transakcije radile.
+func (rr *FoodServiceRepo) inTransaction(ctx context.Context, fn func(sc mongo.SessionContext) error) error {
+	session, err := rr.cli.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
+
 // Disconnect from database
 func (pr *FoodServiceRepo) DisconnectMongo(ctx context.Context) error {
 	err := pr.cli.Disconnect(ctx)
@@ -186,28 +202,64 @@ func (rr *FoodServiceRepo) GetMyOrders(userID primitive.ObjectID) (Orders, error
 	return myOrders, nil
 }
 
-func (rr *FoodServiceRepo) CancelOrder(orderID primitive.ObjectID) error {
+// CancelOrder otkazuje porudzbinu korisnika i u istoj transakciji vraca njenu cenu
+// na novcanik. Prihvacena porudzbina se ne moze otkazati.
+// Vraca stavku refundacije, ili nil ako porudzbina nije bila placena.
+func (rr *FoodServiceRepo) CancelOrder(orderID, userID primitive.ObjectID) (*WalletTransaction, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	orderCollection := rr.getCollection("order") // Naziv kolekcije mora biti tačan
 
-	filter := bson.M{"_id": orderID}
+	filter := bson.M{
+		"_id":      orderID,
+		"userId":   userID,
+		"statusO2": bson.M{"$ne": Otkazana},
+		"statusO":  bson.M{"$ne": Prihvacena},
+	}
 	update := bson.M{"$set": bson.M{"statusO2": "Otkazana"}}
 
-	result, err := orderCollection.UpdateOne(ctx, filter, update)
-	if err != nil {
+	var refund *WalletTransaction
+	err := rr.inTransaction(ctx, func(sc mongo.SessionContext) error {
+		refund = nil
+		var order Order
+		err := orderCollection.FindOneAndUpdate(sc, filter, update).Decode(&order)
+		if err == mongo.ErrNoDocuments {
+			return rr.cancelRefused(sc, orderID, userID)
+		}
+		if err != nil {
+			return err
+		}
+
+		if order.Price <= 0 {
+			return nil
+		}
+		refund, err = rr.refundOrder(sc, &order)
 		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+	return refund, nil
+}
 
-	if result.MatchedCount == 0 {
+// cancelRefused objasnjava zasto porudzbina nije mogla da se otkaze
+func (rr *FoodServiceRepo) cancelRefused(ctx context.Context, orderID, userID primitive.ObjectID) error {
+	var order Order
+	err := rr.getCollection("order").FindOne(ctx, bson.M{"_id": orderID, "userId": userID}).Decode(&order)
+	if err == mongo.ErrNoDocuments {
+		// Tudja porudzbina se ne razlikuje od nepostojece
 		return errors.New("order not found")
 	}
-
-	return nil
+	if err != nil {
+		return err
+	}
+	if order.StatusO2 == Otkazana {
+		return ErrOrderAlreadyCanceled
+	}
+	return ErrOrderAccepted
 }
 
-
 func (rr *FoodServiceRepo) UpdateFoodEntry(r *http.Request, foodID primitive.ObjectID, foodData *Food) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -420,12 +472,16 @@ func (rr *FoodServiceRepo) CreateOrderEntry(r *http.Request, orderData *Order) e
 		orderData.Food = food
 	}
 
-	orderCollection := rr.getCollection("order")
-	_, err := orderCollection.InsertOne(ctx, orderData)
-	if err != nil {
+	// Placa se unapred iz novcanika, u istoj transakciji sa upisom porudzbine
+	return rr.inTransaction(ctx, func(sc mongo.SessionContext) error {
+		if orderData.Price > 0 {
+			if err := rr.debitOrder(sc, orderData); err != nil {
+				return err
+			}
+		}
+		_, err := rr.getCollection("order").InsertOne(sc, orderData)
 		return err
-	}
-	return nil
+	})
 }
 
 
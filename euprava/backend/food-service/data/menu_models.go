@@ -0,0 +1,123 @@
+package data
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MenuDateLayout je format datuma menija (jedan meni po danu, obroku i menzi)
+const MenuDateLayout = "2006-01-02"
+
+const DefaultMenuTimeZone = "Europe/Belgrade"
+
+type MealSlot string
+
+const (
+	BREAKFAST MealSlot = "BREAKFAST"
+	LUNCH     MealSlot = "LUNCH"
+	DINNER    MealSlot = "DINNER"
+)
+
+var MealSlots = []MealSlot{BREAKFAST, LUNCH, DINNER}
+
+func (s MealSlot) Valid() bool {
+	for _, slot := range MealSlots {
+		if s == slot {
+			return true
+		}
+	}
+	return false
+}
+
+type MenuItem struct {
+	Food  Food    `bson:"food" json:"food"`
+	Price float64 `bson:"price" json:"price"`
+}
+
+type Menu struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Canteen     string             `bson:"canteen" json:"canteen"`
+	Date        string             `bson:"date" json:"date"`
+	Slot        MealSlot           `bson:"slot" json:"slot"`
+	Items       []MenuItem         `bson:"items" json:"items"`
+	Published   bool               `bson:"published" json:"published"`
+	PublishedAt *time.Time         `bson:"publishedAt,omitempty" json:"publishedAt,omitempty"`
+	CreatedBy   primitive.ObjectID `bson:"createdBy,omitempty" json:"createdBy,omitempty"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+type Menus []*Menu
+
+// Item vraca stavku menija za jelo
+func (m *Menu) Item(foodID primitive.ObjectID) *MenuItem {
+	for i := range m.Items {
+		if m.Items[i].Food.ID == foodID {
+			return &m.Items[i]
+		}
+	}
+	return nil
+}
+
+type SaveMenuItem struct {
+	FoodID string  `json:"foodId"`
+	Price  float64 `json:"price"`
+}
+
+type SaveMenuRequest struct {
+	Canteen string         `json:"canteen"`
+	Date    string         `json:"date"`
+	Slot    MealSlot       `json:"slot"`
+	Items   []SaveMenuItem `json:"items"`
+}
+
+// WeekRequest oznacava nedelju menze; weekStart moze biti bilo koji dan u nedelji
+type WeekRequest struct {
+	Canteen   string `json:"canteen"`
+	WeekStart string `json:"weekStart"`
+}
+
+type WeekResult struct {
+	Canteen   string `json:"canteen"`
+	WeekStart string `json:"weekStart"`
+	Count     int64  `json:"count"`
+}
+
+var (
+	ErrMenuNotFound     = errors.New("menu not found")
+	ErrMenuInPast       = errors.New("menu date is in the past")
+	ErrFoodNotOnMenu    = errors.New("food is not on today's or a future menu")
+	ErrMenuFoodNotFound = errors.New("menu food not found")
+)
+
+// MenuLocation vraca vremensku zonu po kojoj se racuna "danas" za menije
+func MenuLocation() *time.Location {
+	name := os.Getenv("MENU_TIME_ZONE")
+	if name == "" {
+		name = DefaultMenuTimeZone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Today vraca danasnji datum u MenuDateLayout formatu
+func Today() string {
+	return time.Now().In(MenuLocation()).Format(MenuDateLayout)
+}
+
+// ParseMenuDate parsira datum menija
+func ParseMenuDate(value string) (time.Time, error) {
+	return time.ParseInLocation(MenuDateLayout, value, MenuLocation())
+}
+
+// WeekStart vraca ponedeljak nedelje kojoj datum pripada
+func WeekStart(date time.Time) time.Time {
+	offset := (int(date.Weekday()) + 6) % 7
+	return date.AddDate(0, 0, -offset)
+}
@@ -0,0 +1,295 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===== MENUS =====
+
+func (rr *FoodServiceRepo) EnsureMenuIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	menus := rr.getCollection("menus")
+
+	// Jedan meni po menzi, danu i obroku
+	_, err := menus.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "canteen", Value: 1}, {Key: "date", Value: 1}, {Key: "slot", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("uniq_canteen_date_slot"),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Provera pri porucivanju: objavljeni meniji od danas nadalje koji sadrze jelo
+	_, err = menus.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "items.food._id", Value: 1}, {Key: "published", Value: 1}, {Key: "date", Value: 1}},
+		Options: options.Index().SetName("menus_by_food_date"),
+	})
+	return err
+}
+
+// SaveMenu kreira ili zamenjuje stavke menija za menzu, dan i obrok.
+// Status objave postojeceg menija se ne menja.
+func (rr *FoodServiceRepo) SaveMenu(req *SaveMenuRequest, createdBy primitive.ObjectID) (*Menu, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	ids := make([]primitive.ObjectID, 0, len(req.Items))
+	prices := map[primitive.ObjectID]float64{}
+	for _, item := range req.Items {
+		oid, err := primitive.ObjectIDFromHex(item.FoodID)
+		if err != nil {
+			return nil, ErrMenuFoodNotFound
+		}
+		if _, seen := prices[oid]; !seen {
+			ids = append(ids, oid)
+		}
+		prices[oid] = item.Price
+	}
+
+	foods := Foods{}
+	if len(ids) > 0 {
+		cursor, err := rr.getCollection("food").Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+		if err != nil {
+			return nil, err
+		}
+		if err := cursor.All(ctx, &foods); err != nil {
+			return nil, err
+		}
+		if len(foods) != len(ids) {
+			return nil, ErrMenuFoodNotFound
+		}
+	}
+
+	// Zadrzi redosled koji je kuvar poslao
+	byID := map[primitive.ObjectID]*Food{}
+	for _, f := range foods {
+		byID[f.ID] = f
+	}
+	items := make([]MenuItem, 0, len(ids))
+	for _, id := range ids {
+		items = append(items, MenuItem{Food: *byID[id], Price: prices[id]})
+	}
+
+	now := time.Now()
+	filter := bson.M{"canteen": req.Canteen, "date": req.Date, "slot": req.Slot}
+	update := bson.M{
+		"$set": bson.M{
+			"items":     items,
+			"updatedAt": now,
+		},
+		"$setOnInsert": bson.M{
+			"published": false,
+			"createdBy": createdBy,
+			"createdAt": now,
+		},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var menu Menu
+	if err := rr.getCollection("menus").FindOneAndUpdate(ctx, filter, update, opts).Decode(&menu); err != nil {
+		return nil, err
+	}
+	return &menu, nil
+}
+
+func (rr *FoodServiceRepo) GetMenuByID(id primitive.ObjectID) (*Menu, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var menu Menu
+	err := rr.getCollection("menus").FindOne(ctx, bson.M{"_id": id}).Decode(&menu)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrMenuNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &menu, nil
+}
+
+// DeleteMenu brise meni koji jos nije prosao
+func (rr *FoodServiceRepo) DeleteMenu(id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	menu, err := rr.GetMenuByID(id)
+	if err != nil {
+		return err
+	}
+	if menu.Date < Today() {
+		return ErrMenuInPast
+	}
+
+	result, err := rr.getCollection("menus").DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrMenuNotFound
+	}
+	return nil
+}
+
+// GetMenus vraca menije u opsegu datuma [from, to], sortirane po danu i obroku.
+// Prazan canteen znaci sve menze.
+func (rr *FoodServiceRepo) GetMenus(canteen, from, to string, publishedOnly bool) (Menus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{"date": bson.M{"$gte": from, "$lte": to}}
+	if canteen != "" {
+		filter["canteen"] = canteen
+	}
+	if publishedOnly {
+		filter["published"] = true
+	}
+
+	cursor, err := rr.getCollection("menus").Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	menus := Menus{}
+	if err := cursor.All(ctx, &menus); err != nil {
+		return nil, err
+	}
+
+	slotOrder := map[MealSlot]int{}
+	for i, s := range MealSlots {
+		slotOrder[s] = i
+	}
+	sort.SliceStable(menus, func(i, j int) bool {
+		if menus[i].Date != menus[j].Date {
+			return menus[i].Date < menus[j].Date
+		}
+		if menus[i].Canteen != menus[j].Canteen {
+			return menus[i].Canteen < menus[j].Canteen
+		}
+		return slotOrder[menus[i].Slot] < slotOrder[menus[j].Slot]
+	})
+	return menus, nil
+}
+
+// GetCanteens vraca sve menze koje imaju bar jedan meni
+func (rr *FoodServiceRepo) GetCanteens() ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	values, err := rr.getCollection("menus").Distinct(ctx, "canteen", bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	canteens := make([]string, 0, len(values))
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			canteens = append(canteens, s)
+		}
+	}
+	sort.Strings(canteens)
+	return canteens, nil
+}
+
+// PublishWeek objavljuje sve menije menze u nedelji koja pocinje sa weekStart
+func (rr *FoodServiceRepo) PublishWeek(canteen string, weekStart time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{
+		"canteen":   canteen,
+		"published": false,
+		"date": bson.M{
+			"$gte": weekStart.Format(MenuDateLayout),
+			"$lte": weekStart.AddDate(0, 0, 6).Format(MenuDateLayout),
+		},
+	}
+	update := bson.M{"$set": bson.M{"published": true, "publishedAt": now, "updatedAt": now}}
+
+	result, err := rr.getCollection("menus").UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+// CopyWeek kopira menije prethodne nedelje u nedelju koja pocinje sa weekStart.
+// Kopije su neobjavljene, a vec postojeci meniji se ne prepisuju.
+func (rr *FoodServiceRepo) CopyWeek(canteen string, weekStart time.Time, createdBy primitive.ObjectID) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	previous := weekStart.AddDate(0, 0, -7)
+	source, err := rr.GetMenus(canteen, previous.Format(MenuDateLayout), previous.AddDate(0, 0, 6).Format(MenuDateLayout), false)
+	if err != nil {
+		return 0, err
+	}
+
+	menus := rr.getCollection("menus")
+	now := time.Now()
+	var copied int64
+	for _, m := range source {
+		date, err := ParseMenuDate(m.Date)
+		if err != nil {
+			rr.logger.Println("Skipping menu with invalid date:", m.ID.Hex(), m.Date)
+			continue
+		}
+
+		filter := bson.M{"canteen": canteen, "date": date.AddDate(0, 0, 7).Format(MenuDateLayout), "slot": m.Slot}
+		update := bson.M{"$setOnInsert": bson.M{
+			"items":     m.Items,
+			"published": false,
+			"createdBy": createdBy,
+			"createdAt": now,
+			"updatedAt": now,
+		}}
+		result, err := menus.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+		if mongo.IsDuplicateKeyError(err) {
+			// meni je istovremeno napravljen, ne prepisuje se
+			continue
+		}
+		if err != nil {
+			return copied, err
+		}
+		if result.UpsertedCount > 0 {
+			copied++
+		}
+	}
+	return copied, nil
+}
+
+// FindOrderableMenu vraca objavljeni danasnji ili buduci meni na kome je jelo.
+// Ako menuID nije zadat, uzima se najblizi takav meni.
+func (rr *FoodServiceRepo) FindOrderableMenu(foodID, menuID primitive.ObjectID) (*Menu, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"published":      true,
+		"date":           bson.M{"$gte": Today()},
+		"items.food._id": foodID,
+	}
+	if !menuID.IsZero() {
+		filter["_id"] = menuID
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "date", Value: 1}})
+
+	var menu Menu
+	err := rr.getCollection("menus").FindOne(ctx, filter, opts).Decode(&menu)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrFoodNotOnMenu
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &menu, nil
+}
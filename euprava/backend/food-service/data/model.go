@@ -129,6 +129,12 @@ type Order struct {
 	UserID  primitive.ObjectID `bson:"userId,omitempty" json:"userId,omitempty"`
 	StatusO StatusO            `bson:"statusO,omitempty" json:"statusO,omitempty"`
 	StatusO2 StatusO2            `bson:"statusO2,omitempty" json:"statusO2,omitempty"`
+	MenuID   primitive.ObjectID `bson:"menuId,omitempty" json:"menuId,omitempty"`
+	Canteen  string             `bson:"canteen,omitempty" json:"canteen,omitempty"`
+	Date     string             `bson:"date,omitempty" json:"date,omitempty"`
+	Slot     MealSlot           `bson:"slot,omitempty" json:"slot,omitempty"`
+	Price    float64            `bson:"price" json:"price"`
+	Pricing  *AppliedPricing    `bson:"pricing,omitempty" json:"pricing,omitempty"`
 
 }
 
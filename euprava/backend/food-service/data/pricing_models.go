@@ -0,0 +1,112 @@
+package data
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+type PriceCategory string
+
+const (
+	FULL       PriceCategory = "FULL"
+	SUBSIDISED PriceCategory = "SUBSIDISED"
+	FREE       PriceCategory = "FREE"
+)
+
+// PricingRule odredjuje koliki deo cene sa menija student placa.
+// Pravilo vazi za studenta koji ispunjava sve uslove koje trazi; pravila se proveravaju redom.
+type PricingRule struct {
+	Name         string        `bson:"name" json:"name"`
+	Category     PriceCategory `bson:"category" json:"category"`
+	PayPercent   float64       `bson:"payPercent" json:"payPercent"`
+	Scholarship  bool          `bson:"scholarship" json:"scholarship"`
+	DormResident bool          `bson:"dormResident" json:"dormResident"`
+}
+
+type PricingRules struct {
+	Rules     []PricingRule `bson:"rules" json:"rules"`
+	UpdatedAt time.Time     `bson:"updatedAt" json:"updatedAt"`
+}
+
+// FullPriceRule vazi kada nijedno pravilo ne odgovara studentu
+var FullPriceRule = PricingRule{Name: "Full price", Category: FULL, PayPercent: 100}
+
+var DefaultPricingRules = []PricingRule{
+	{Name: "Scholarship holder living in the dorm", Category: FREE, PayPercent: 0, Scholarship: true, DormResident: true},
+	{Name: "Scholarship holder", Category: SUBSIDISED, PayPercent: 50, Scholarship: true},
+	{Name: "Dorm resident", Category: SUBSIDISED, PayPercent: 70, DormResident: true},
+	FullPriceRule,
+}
+
+var (
+	ErrInvalidPricingRule = errors.New("invalid pricing rule")
+	// ErrStudentStatusUnavailable znaci da se cena ne moze odrediti jer neki servis nije odgovorio
+	ErrStudentStatusUnavailable = errors.New("student status unavailable")
+)
+
+// Validate proverava da procenat odgovara kategoriji
+func (p PricingRule) Validate() error {
+	if p.Name == "" {
+		return ErrInvalidPricingRule
+	}
+	switch p.Category {
+	case FULL:
+		if p.PayPercent != 100 {
+			return ErrInvalidPricingRule
+		}
+	case FREE:
+		if p.PayPercent != 0 {
+			return ErrInvalidPricingRule
+		}
+	case SUBSIDISED:
+		if p.PayPercent <= 0 || p.PayPercent >= 100 {
+			return ErrInvalidPricingRule
+		}
+	default:
+		return ErrInvalidPricingRule
+	}
+	return nil
+}
+
+func (p PricingRule) Matches(status StudentStatus) bool {
+	return (!p.Scholarship || status.Scholarship) && (!p.DormResident || status.DormResident)
+}
+
+// StudentStatus je ono sto university-service i dorm-service znaju o studentu
+type StudentStatus struct {
+	Scholarship  bool `bson:"scholarship" json:"scholarship"`
+	DormResident bool `bson:"dormResident" json:"dormResident"`
+}
+
+// AppliedPricing je pravilo primenjeno na porudzbinu, sa statusom studenta u tom trenutku
+type AppliedPricing struct {
+	Rule       string        `bson:"rule" json:"rule"`
+	Category   PriceCategory `bson:"category" json:"category"`
+	BasePrice  float64       `bson:"basePrice" json:"basePrice"`
+	PayPercent float64       `bson:"payPercent" json:"payPercent"`
+	Status     StudentStatus `bson:"status" json:"status"`
+}
+
+// Price racuna cenu koju student placa, zaokruzenu na dve decimale
+func (a AppliedPricing) Price() float64 {
+	return math.Round(a.BasePrice*a.PayPercent) / 100
+}
+
+// Apply bira prvo pravilo koje odgovara studentu
+func (rules *PricingRules) Apply(basePrice float64, status StudentStatus) AppliedPricing {
+	rule := FullPriceRule
+	for _, r := range rules.Rules {
+		if r.Matches(status) {
+			rule = r
+			break
+		}
+	}
+	return AppliedPricing{
+		Rule:       rule.Name,
+		Category:   rule.Category,
+		BasePrice:  basePrice,
+		PayPercent: rule.PayPercent,
+		Status:     status,
+	}
+}
@@ -0,0 +1,156 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===== PRICING =====
+
+const pricingRulesID = "default"
+
+// GetPricingRules vraca vazeca pravila; dok ih niko ne sacuva vaze DefaultPricingRules
+func (rr *FoodServiceRepo) GetPricingRules() (*PricingRules, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var rules PricingRules
+	err := rr.getCollection("pricing_rules").FindOne(ctx, bson.M{"_id": pricingRulesID}).Decode(&rules)
+	if err == mongo.ErrNoDocuments {
+		return &PricingRules{Rules: DefaultPricingRules}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
+
+func (rr *FoodServiceRepo) SavePricingRules(rules []PricingRule) (*PricingRules, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	saved := PricingRules{Rules: rules, UpdatedAt: time.Now()}
+	_, err := rr.getCollection("pricing_rules").ReplaceOne(ctx, bson.M{"_id": pricingRulesID}, saved, options.Replace().SetUpsert(true))
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+func serviceURL(hostEnv, portEnv, defaultHost, defaultPort string) string {
+	host := os.Getenv(hostEnv)
+	port := os.Getenv(portEnv)
+	if host == "" {
+		host = defaultHost
+	}
+	if port == "" {
+		port = defaultPort
+	}
+	return fmt.Sprintf("http://%s:%s", host, port)
+}
+
+// ResolveStudentStatus pita university-service za stipendiju i dorm-service za boravak u domu.
+// userID i authorization dolaze iz tokena koji je proverio AuthRequired, pa dorm-service
+// odgovara za istog studenta koji placa porudzbinu.
+// Ako neki servis nije dostupan, vraca ErrStudentStatusUnavailable umesto da pogadja kategoriju.
+func (rr *FoodServiceRepo) ResolveStudentStatus(userID primitive.ObjectID, authorization string) (StudentStatus, error) {
+	var status StudentStatus
+
+	scholarship, err := rr.hasScholarship(userID)
+	if err != nil {
+		rr.logger.Printf("Cannot resolve scholarship for %s: %v", userID.Hex(), err)
+		return status, fmt.Errorf("%w: %v", ErrStudentStatusUnavailable, err)
+	}
+	status.Scholarship = scholarship
+
+	resident, err := rr.isDormResident(userID, authorization)
+	if err != nil {
+		rr.logger.Printf("Cannot resolve dorm residency for %s: %v", userID.Hex(), err)
+		return status, fmt.Errorf("%w: %v", ErrStudentStatusUnavailable, err)
+	}
+	status.DormResident = resident
+
+	return status, nil
+}
+
+func (rr *FoodServiceRepo) hasScholarship(userID primitive.ObjectID) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/students/%s", serviceURL("UNIVERSITY_SERVICE_HOST", "UNIVERSITY_SERVICE_PORT", "university-service", "8088"), userID.Hex())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := rr.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("university service returned status code: %d", resp.StatusCode)
+	}
+
+	var student struct {
+		Scholarship bool `json:"scholarship"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&student); err != nil {
+		return false, err
+	}
+	return student.Scholarship, nil
+}
+
+func (rr *FoodServiceRepo) isDormResident(userID primitive.ObjectID, authorization string) (bool, error) {
+	if authorization == "" {
+		return false, fmt.Errorf("no Authorization header to forward to dorm service")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	url := fmt.Sprintf("%s/residencies?studentId=%s", serviceURL("DORM_SERVICE_HOST", "DORM_SERVICE_PORT", "dorm_service", "8002"), userID.Hex())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := rr.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("dorm service returned status code: %d", resp.StatusCode)
+	}
+
+	var residencies []struct {
+		StudentID string `json:"studentId"`
+		Active    bool   `json:"active"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&residencies); err != nil {
+		return false, err
+	}
+	for _, r := range residencies {
+		// dorm-service za studenta vraca njegove boravke bez obzira na studentId iz upita
+		if r.Active && r.StudentID == userID.Hex() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
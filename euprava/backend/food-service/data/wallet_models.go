@@ -0,0 +1,56 @@
+package data
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Wallet struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID    primitive.ObjectID `bson:"userId" json:"userId"`
+	Balance   float64            `bson:"balance" json:"balance"`
+	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	UpdatedAt time.Time          `bson:"updatedAt" json:"updatedAt"`
+}
+
+type TransactionType string
+
+const (
+	TOPUP  TransactionType = "TOPUP"
+	DEBIT  TransactionType = "DEBIT"
+	REFUND TransactionType = "REFUND"
+)
+
+// WalletTransaction je stavka knjige promena novcanika; iznos je uvek pozitivan, smer odredjuje tip
+type WalletTransaction struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID      primitive.ObjectID `bson:"userId" json:"userId"`
+	Type        TransactionType    `bson:"type" json:"type"`
+	Amount      float64            `bson:"amount" json:"amount"`
+	OrderID     primitive.ObjectID `bson:"orderId,omitempty" json:"orderId,omitempty"`
+	Provider    string             `bson:"provider,omitempty" json:"provider,omitempty"`
+	Reference   string             `bson:"reference,omitempty" json:"reference,omitempty"`
+	Description string             `bson:"description,omitempty" json:"description,omitempty"`
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+type WalletView struct {
+	Balance      float64              `json:"balance"`
+	Transactions []*WalletTransaction `json:"transactions"`
+}
+
+type TopUpRequest struct {
+	Amount float64 `json:"amount"`
+	// Token je token nacina placanja koji vraca checkout provajdera
+	Token string `json:"token"`
+}
+
+const MaxTopUpAmount = 20000
+
+var (
+	ErrInsufficientFunds    = errors.New("insufficient funds")
+	ErrOrderAlreadyCanceled = errors.New("order already canceled")
+	ErrOrderAccepted        = errors.New("order already accepted")
+)
@@ -0,0 +1,185 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ===== WALLETS =====
+//
+// Stanje i knjiga promena se menjaju u istoj transakciji (vidi inTransaction).
+// Stanje se menja uslovnim $inc (bez odlaska u minus), a knjiga promena je jedinstvena
+// po (orderId, type) da se porudzbina ne bi naplatila ili refundirala dva puta.
+
+func (rr *FoodServiceRepo) EnsureWalletIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	wallets := rr.getCollection("wallets")
+	transactions := rr.getCollection("wallet_transactions")
+
+	_, err := wallets.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "userId", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("uniq_wallet_user"),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = transactions.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}},
+		Options: options.Index().SetName("transactions_by_user_createdAt"),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = transactions.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "orderId", Value: 1}, {Key: "type", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("uniq_order_transaction").
+			SetPartialFilterExpression(bson.M{"orderId": bson.M{"$exists": true}}),
+	})
+	return err
+}
+
+// GetWallet vraca novcanik korisnika; korisnik bez novcanika ima stanje 0
+func (rr *FoodServiceRepo) GetWallet(userID primitive.ObjectID) (*Wallet, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var wallet Wallet
+	err := rr.getCollection("wallets").FindOne(ctx, bson.M{"userId": userID}).Decode(&wallet)
+	if err == mongo.ErrNoDocuments {
+		return &Wallet{UserID: userID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+func (rr *FoodServiceRepo) ListWalletTransactions(userID primitive.ObjectID, limit int64) ([]*WalletTransaction, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := rr.getCollection("wallet_transactions").Find(ctx, bson.M{"userId": userID},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	transactions := []*WalletTransaction{}
+	if err := cursor.All(ctx, &transactions); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// TopUp upisuje uplatu koju je provajder vec naplatio i vraca novo stanje
+func (rr *FoodServiceRepo) TopUp(userID primitive.ObjectID, amount float64, provider, reference string) (*Wallet, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var wallet *Wallet
+	err := rr.inTransaction(ctx, func(sc mongo.SessionContext) error {
+		var err error
+		wallet, err = rr.credit(sc, userID, amount)
+		if err != nil {
+			return err
+		}
+
+		tx := WalletTransaction{
+			ID:          primitive.NewObjectID(),
+			UserID:      userID,
+			Type:        TOPUP,
+			Amount:      amount,
+			Provider:    provider,
+			Reference:   reference,
+			Description: "Wallet top-up",
+			CreatedAt:   time.Now(),
+		}
+		_, err = rr.getCollection("wallet_transactions").InsertOne(sc, tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return wallet, nil
+}
+
+func (rr *FoodServiceRepo) credit(ctx context.Context, userID primitive.ObjectID, amount float64) (*Wallet, error) {
+	now := time.Now()
+	update := bson.M{
+		"$inc":         bson.M{"balance": amount},
+		"$set":         bson.M{"updatedAt": now},
+		"$setOnInsert": bson.M{"userId": userID, "createdAt": now},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var wallet Wallet
+	if err := rr.getCollection("wallets").FindOneAndUpdate(ctx, bson.M{"userId": userID}, update, opts).Decode(&wallet); err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// debitOrder skida cenu porudzbine sa novcanika samo ako je stanje dovoljno.
+// Poziva se u transakciji porudzbine.
+func (rr *FoodServiceRepo) debitOrder(ctx context.Context, order *Order) error {
+	filter := bson.M{"userId": order.UserID, "balance": bson.M{"$gte": order.Price}}
+	update := bson.M{
+		"$inc": bson.M{"balance": -order.Price},
+		"$set": bson.M{"updatedAt": time.Now()},
+	}
+
+	result, err := rr.getCollection("wallets").UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrInsufficientFunds
+	}
+
+	tx := WalletTransaction{
+		ID:          primitive.NewObjectID(),
+		UserID:      order.UserID,
+		Type:        DEBIT,
+		Amount:      order.Price,
+		OrderID:     order.ID,
+		Description: order.Food.FoodName,
+		CreatedAt:   time.Now(),
+	}
+	_, err = rr.getCollection("wallet_transactions").InsertOne(ctx, tx)
+	return err
+}
+
+// refundOrder vraca cenu porudzbine na novcanik. Poziva se u transakciji otkazivanja.
+func (rr *FoodServiceRepo) refundOrder(ctx context.Context, order *Order) (*WalletTransaction, error) {
+	tx := WalletTransaction{
+		ID:          primitive.NewObjectID(),
+		UserID:      order.UserID,
+		Type:        REFUND,
+		Amount:      order.Price,
+		OrderID:     order.ID,
+		Description: order.Food.FoodName,
+		CreatedAt:   time.Now(),
+	}
+	if _, err := rr.getCollection("wallet_transactions").InsertOne(ctx, tx); err != nil {
+		return nil, err
+	}
+	if _, err := rr.credit(ctx, order.UserID, order.Price); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
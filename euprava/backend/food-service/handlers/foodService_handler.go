@@ -3,8 +3,10 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"food-service/data"
+	"food-service/payments"
 	"io"
 	"log"
 	"net/http"
@@ -19,14 +21,15 @@ import (
 type FoodServiceHandler struct {
 	logger          *log.Logger
 	foodServiceRepo *data.FoodServiceRepo
+	paymentProvider payments.Provider
 }
 
 type KeyProduct struct{}
 type KeyFood struct{}
 type KeyOrder struct{}
 
-func NewFoodServiceHandler(l *log.Logger, r *data.FoodServiceRepo) *FoodServiceHandler {
-	return &FoodServiceHandler{l, r}
+func NewFoodServiceHandler(l *log.Logger, r *data.FoodServiceRepo, p payments.Provider) *FoodServiceHandler {
+	return &FoodServiceHandler{l, r, p}
 }
 
 // GetListFoodHandler vraća sve unose hrane iz baze
@@ -241,6 +244,11 @@ func (h *FoodServiceHandler) GetAcceptedOrdersHandler(rw http.ResponseWriter, r
 }
 
 func (h *FoodServiceHandler) CancelOrderHandler(rw http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserType(rw, r, "student")
+	if !ok {
+		return
+	}
+
 	vars := mux.Vars(r)
 	orderIDStr := vars["id"]
 
@@ -251,20 +259,36 @@ func (h *FoodServiceHandler) CancelOrderHandler(rw http.ResponseWriter, r *http.
 		return
 	}
 
-	err = h.foodServiceRepo.CancelOrder(orderID)
+	refund, err := h.foodServiceRepo.CancelOrder(orderID, userID)
 	if err != nil {
 		if err.Error() == "order not found" {
 			h.logger.Printf("Order not found: %v", orderIDStr)
 			http.Error(rw, "Order not found", http.StatusNotFound)
 			return
 		}
+		if errors.Is(err, data.ErrOrderAlreadyCanceled) {
+			http.Error(rw, "Order is already canceled", http.StatusConflict)
+			return
+		}
+		if errors.Is(err, data.ErrOrderAccepted) {
+			http.Error(rw, "Order is already accepted", http.StatusConflict)
+			return
+		}
 		h.logger.Printf("Error updating order status: %v", err)
 		http.Error(rw, "Error updating order status", http.StatusInternalServerError)
 		return
 	}
 
+	response := map[string]interface{}{
+		"message":  "Order canceled successfully",
+		"refunded": 0.0,
+	}
+	if refund != nil {
+		response["refunded"] = refund.Amount
+	}
+	rw.Header().Set("Content-Type", "application/json")
 	rw.WriteHeader(http.StatusOK)
-	rw.Write([]byte(`{"message": "Order canceled successfully"}`))
+	json.NewEncoder(rw).Encode(response)
 }
 
 func (h *FoodServiceHandler) GetAllOrdersHandler(rw http.ResponseWriter, r *http.Request) {
@@ -281,18 +305,9 @@ func (h *FoodServiceHandler) GetAllOrdersHandler(rw http.ResponseWriter, r *http
 }
 
 func (h *FoodServiceHandler) CreateOrderHandler(rw http.ResponseWriter, r *http.Request) {
-	// Izvuci userId iz query stringa (analogno cookId za hranu)
-	userID := r.URL.Query().Get("userId")
-	if userID == "" {
-		http.Error(rw, "userId is required", http.StatusBadRequest)
-		return
-	}
-
-	// Konvertuj userID u ObjectID
-	oid, err := primitive.ObjectIDFromHex(userID)
-	if err != nil {
-		h.logger.Println("Invalid userId:", err)
-		http.Error(rw, "Invalid userId", http.StatusBadRequest)
+	// Porudzbina se placa iz novcanika ulogovanog studenta
+	oid, ok := requireUserType(rw, r, "student")
+	if !ok {
 		return
 	}
 
@@ -307,9 +322,50 @@ func (h *FoodServiceHandler) CreateOrderHandler(rw http.ResponseWriter, r *http.
 	// Postavi UserID
 	orderData.UserID = oid
 
+	// Porucuje se samo jelo sa danasnjeg ili buduceg objavljenog menija
+	if orderData.Food.ID.IsZero() {
+		http.Error(rw, "food.id is required", http.StatusBadRequest)
+		return
+	}
+	menu, err := h.foodServiceRepo.FindOrderableMenu(orderData.Food.ID, orderData.MenuID)
+	if err != nil {
+		if errors.Is(err, data.ErrFoodNotOnMenu) {
+			http.Error(rw, "Food is not on today's or a future menu", http.StatusBadRequest)
+			return
+		}
+		h.logger.Print("Database exception: ", err)
+		http.Error(rw, "Error creating order.", http.StatusInternalServerError)
+		return
+	}
+	orderData.MenuID = menu.ID
+	orderData.Canteen = menu.Canteen
+	orderData.Date = menu.Date
+	orderData.Slot = menu.Slot
+
+	// Cena zavisi od statusa studenta (stipendija, dom) i vazecih pravila
+	rules, err := h.foodServiceRepo.GetPricingRules()
+	if err != nil {
+		h.logger.Print("Database exception: ", err)
+		http.Error(rw, "Error creating order.", http.StatusInternalServerError)
+		return
+	}
+	// AuthRequired je vec proverio ovaj token, pa se status trazi za studenta iz tokena
+	status, err := h.foodServiceRepo.ResolveStudentStatus(oid, r.Header.Get("Authorization"))
+	if err != nil {
+		http.Error(rw, "Cannot determine student pricing status, try again later", http.StatusServiceUnavailable)
+		return
+	}
+	pricing := rules.Apply(menu.Item(orderData.Food.ID).Price, status)
+	orderData.Pricing = &pricing
+	orderData.Price = pricing.Price()
+
 	// Kreiraj porudžbinu kroz repo sloj
 	err = h.foodServiceRepo.CreateOrderEntry(r, orderData)
 	if err != nil {
+		if errors.Is(err, data.ErrInsufficientFunds) {
+			http.Error(rw, "Insufficient wallet balance", http.StatusPaymentRequired)
+			return
+		}
 		h.logger.Print("Database exception: ", err)
 		http.Error(rw, "Error creating order.", http.StatusInternalServerError)
 		return
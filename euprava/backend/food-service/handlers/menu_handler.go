@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"food-service/data"
+	"food-service/middleware"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// requireUserType proverava tip ulogovanog korisnika (student, cook) i vraca njegov ID
+func requireUserType(rw http.ResponseWriter, r *http.Request, want string) (primitive.ObjectID, bool) {
+	userType, _ := middleware.GetUserType(r)
+	if userType != want {
+		http.Error(rw, "Forbidden", http.StatusForbidden)
+		return primitive.NilObjectID, false
+	}
+
+	uidStr, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(rw, "Unauthorized", http.StatusUnauthorized)
+		return primitive.NilObjectID, false
+	}
+	userID, err := primitive.ObjectIDFromHex(uidStr)
+	if err != nil {
+		http.Error(rw, "Invalid user id", http.StatusUnauthorized)
+		return primitive.NilObjectID, false
+	}
+	return userID, true
+}
+
+// menuRange cita from/to iz query stringa; podrazumevano od danas narednih 7 dana
+func menuRange(r *http.Request) (string, string, error) {
+	from := r.URL.Query().Get("from")
+	if from == "" {
+		from = data.Today()
+	}
+	start, err := data.ParseMenuDate(from)
+	if err != nil {
+		return "", "", err
+	}
+
+	to := r.URL.Query().Get("to")
+	if to == "" {
+		return from, start.AddDate(0, 0, 6).Format(data.MenuDateLayout), nil
+	}
+	if _, err := data.ParseMenuDate(to); err != nil {
+		return "", "", err
+	}
+	return from, to, nil
+}
+
+// GET /menus?canteen=&from=YYYY-MM-DD&to=YYYY-MM-DD (samo objavljeni meniji)
+func (h *FoodServiceHandler) GetMenusHandler(rw http.ResponseWriter, r *http.Request) {
+	from, to, err := menuRange(r)
+	if err != nil {
+		http.Error(rw, "Invalid date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	menus, err := h.foodServiceRepo.GetMenus(r.URL.Query().Get("canteen"), from, to, true)
+	if err != nil {
+		h.logger.Println("Error retrieving menus:", err)
+		http.Error(rw, "Error retrieving menus.", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(menus)
+}
+
+// GET /menus/manage?canteen=&from=&to= (kuvar, ukljucuje i neobjavljene)
+func (h *FoodServiceHandler) GetManagedMenusHandler(rw http.ResponseWriter, r *http.Request) {
+	if _, ok := requireUserType(rw, r, "cook"); !ok {
+		return
+	}
+
+	from, to, err := menuRange(r)
+	if err != nil {
+		http.Error(rw, "Invalid date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	menus, err := h.foodServiceRepo.GetMenus(r.URL.Query().Get("canteen"), from, to, false)
+	if err != nil {
+		h.logger.Println("Error retrieving menus:", err)
+		http.Error(rw, "Error retrieving menus.", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(menus)
+}
+
+// GET /canteens
+func (h *FoodServiceHandler) GetCanteensHandler(rw http.ResponseWriter, r *http.Request) {
+	canteens, err := h.foodServiceRepo.GetCanteens()
+	if err != nil {
+		h.logger.Println("Error retrieving canteens:", err)
+		http.Error(rw, "Error retrieving canteens.", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(canteens)
+}
+
+// PUT /menu body: { "canteen": "...", "date": "YYYY-MM-DD", "slot": "LUNCH", "items": [{ "foodId": "hex", "price": 250 }] }
+func (h *FoodServiceHandler) SaveMenuHandler(rw http.ResponseWriter, r *http.Request) {
+	cookID, ok := requireUserType(rw, r, "cook")
+	if !ok {
+		return
+	}
+
+	var req data.SaveMenuRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(rw, "Unable to decode JSON", http.StatusBadRequest)
+		return
+	}
+
+	req.Canteen = strings.TrimSpace(req.Canteen)
+	if req.Canteen == "" {
+		http.Error(rw, "canteen is required", http.StatusBadRequest)
+		return
+	}
+	if !req.Slot.Valid() {
+		http.Error(rw, "slot is required (BREAKFAST|LUNCH|DINNER)", http.StatusBadRequest)
+		return
+	}
+	if _, err := data.ParseMenuDate(req.Date); err != nil {
+		http.Error(rw, "Invalid date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if req.Date < data.Today() {
+		http.Error(rw, "Cannot change a menu in the past", http.StatusBadRequest)
+		return
+	}
+	for _, item := range req.Items {
+		if item.Price < 0 {
+			http.Error(rw, "price cannot be negative", http.StatusBadRequest)
+			return
+		}
+	}
+
+	menu, err := h.foodServiceRepo.SaveMenu(&req, cookID)
+	if err != nil {
+		if errors.Is(err, data.ErrMenuFoodNotFound) {
+			http.Error(rw, "Unknown food on menu", http.StatusBadRequest)
+			return
+		}
+		h.logger.Println("Error saving menu:", err)
+		http.Error(rw, "Error saving menu.", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(menu)
+}
+
+// DELETE /menu/{id}
+func (h *FoodServiceHandler) DeleteMenuHandler(rw http.ResponseWriter, r *http.Request) {
+	if _, ok := requireUserType(rw, r, "cook"); !ok {
+		return
+	}
+
+	menuID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(rw, "Invalid menu id", http.StatusBadRequest)
+		return
+	}
+
+	err = h.foodServiceRepo.DeleteMenu(menuID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrMenuNotFound):
+			http.Error(rw, "Menu not found", http.StatusNotFound)
+		case errors.Is(err, data.ErrMenuInPast):
+			http.Error(rw, "Cannot delete a menu in the past", http.StatusBadRequest)
+		default:
+			h.logger.Println("Error deleting menu:", err)
+			http.Error(rw, "Error deleting menu.", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// decodeWeekRequest cita menzu i nedelju iz tela zahteva; nedelja se svodi na ponedeljak
+func decodeWeekRequest(rw http.ResponseWriter, r *http.Request) (*data.WeekRequest, bool) {
+	var req data.WeekRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(rw, "Unable to decode JSON", http.StatusBadRequest)
+		return nil, false
+	}
+
+	req.Canteen = strings.TrimSpace(req.Canteen)
+	if req.Canteen == "" {
+		http.Error(rw, "canteen is required", http.StatusBadRequest)
+		return nil, false
+	}
+	date, err := data.ParseMenuDate(req.WeekStart)
+	if err != nil {
+		http.Error(rw, "Invalid weekStart, expected YYYY-MM-DD", http.StatusBadRequest)
+		return nil, false
+	}
+	req.WeekStart = data.WeekStart(date).Format(data.MenuDateLayout)
+	return &req, true
+}
+
+// POST /menus/publish body: { "canteen": "...", "weekStart": "YYYY-MM-DD" }
+func (h *FoodServiceHandler) PublishWeekHandler(rw http.ResponseWriter, r *http.Request) {
+	if _, ok := requireUserType(rw, r, "cook"); !ok {
+		return
+	}
+	req, ok := decodeWeekRequest(rw, r)
+	if !ok {
+		return
+	}
+
+	weekStart, _ := data.ParseMenuDate(req.WeekStart)
+	if weekStart.AddDate(0, 0, 6).Format(data.MenuDateLayout) < data.Today() {
+		http.Error(rw, "Cannot publish a week in the past", http.StatusBadRequest)
+		return
+	}
+
+	count, err := h.foodServiceRepo.PublishWeek(req.Canteen, weekStart)
+	if err != nil {
+		h.logger.Println("Error publishing menus:", err)
+		http.Error(rw, "Error publishing menus.", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(data.WeekResult{Canteen: req.Canteen, WeekStart: req.WeekStart, Count: count})
+}
+
+// POST /menus/copy-week body: { "canteen": "...", "weekStart": "YYYY-MM-DD" }
+// Kopira meni prethodne nedelje u zadatu nedelju kao neobjavljen.
+func (h *FoodServiceHandler) CopyWeekHandler(rw http.ResponseWriter, r *http.Request) {
+	cookID, ok := requireUserType(rw, r, "cook")
+	if !ok {
+		return
+	}
+	req, ok := decodeWeekRequest(rw, r)
+	if !ok {
+		return
+	}
+
+	weekStart, _ := data.ParseMenuDate(req.WeekStart)
+	if weekStart.AddDate(0, 0, 6).Format(data.MenuDateLayout) < data.Today() {
+		http.Error(rw, "Cannot copy into a week in the past", http.StatusBadRequest)
+		return
+	}
+
+	count, err := h.foodServiceRepo.CopyWeek(req.Canteen, weekStart, cookID)
+	if err != nil {
+		h.logger.Println("Error copying menus:", err)
+		http.Error(rw, "Error copying menus.", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(rw).Encode(data.WeekResult{Canteen: req.Canteen, WeekStart: req.WeekStart, Count: count})
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"food-service/data"
+)
+
+// GET /pricing-rules
+func (h *FoodServiceHandler) GetPricingRulesHandler(rw http.ResponseWriter, r *http.Request) {
+	rules, err := h.foodServiceRepo.GetPricingRules()
+	if err != nil {
+		h.logger.Println("Error retrieving pricing rules:", err)
+		http.Error(rw, "Error retrieving pricing rules.", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(rules)
+}
+
+// PUT /pricing-rules body: { "rules": [{ "name": "...", "category": "SUBSIDISED", "payPercent": 50, "scholarship": true, "dormResident": false }] }
+// Pravila se proveravaju redom kojim su poslata; student koji ne ispuni nijedno placa punu cenu.
+func (h *FoodServiceHandler) SavePricingRulesHandler(rw http.ResponseWriter, r *http.Request) {
+	if _, ok := requireUserType(rw, r, "cook"); !ok {
+		return
+	}
+
+	var req data.PricingRules
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(rw, "Unable to decode JSON", http.StatusBadRequest)
+		return
+	}
+	for i := range req.Rules {
+		req.Rules[i].Name = strings.TrimSpace(req.Rules[i].Name)
+		if err := req.Rules[i].Validate(); err != nil {
+			http.Error(rw, "Invalid pricing rule: name is required and payPercent must be 100 for FULL, 0 for FREE and between them for SUBSIDISED", http.StatusBadRequest)
+			return
+		}
+	}
+
+	rules, err := h.foodServiceRepo.SavePricingRules(req.Rules)
+	if err != nil {
+		h.logger.Println("Error saving pricing rules:", err)
+		http.Error(rw, "Error saving pricing rules.", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(rules)
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"food-service/data"
+	"food-service/payments"
+)
+
+// GET /wallet?limit=50 (student) — stanje i poslednje promene
+func (h *FoodServiceHandler) GetWalletHandler(rw http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserType(rw, r, "student")
+	if !ok {
+		return
+	}
+
+	limit, _ := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
+
+	wallet, err := h.foodServiceRepo.GetWallet(userID)
+	if err != nil {
+		h.logger.Println("Error retrieving wallet:", err)
+		http.Error(rw, "Error retrieving wallet.", http.StatusInternalServerError)
+		return
+	}
+	transactions, err := h.foodServiceRepo.ListWalletTransactions(userID, limit)
+	if err != nil {
+		h.logger.Println("Error retrieving wallet transactions:", err)
+		http.Error(rw, "Error retrieving wallet.", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(data.WalletView{Balance: wallet.Balance, Transactions: transactions})
+}
+
+// POST /wallet/top-up body: { "amount": 1000, "token": "..." } (student)
+func (h *FoodServiceHandler) TopUpWalletHandler(rw http.ResponseWriter, r *http.Request) {
+	userID, ok := requireUserType(rw, r, "student")
+	if !ok {
+		return
+	}
+
+	var req data.TopUpRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(rw, "Unable to decode JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Amount <= 0 || req.Amount > data.MaxTopUpAmount {
+		http.Error(rw, "amount must be between 0 and "+strconv.Itoa(data.MaxTopUpAmount), http.StatusBadRequest)
+		return
+	}
+
+	charge, err := h.paymentProvider.Charge(r.Context(), payments.ChargeRequest{
+		PayerId:     userID.Hex(),
+		Amount:      req.Amount,
+		Description: "Canteen wallet top-up",
+		Token:       req.Token,
+	})
+	if err != nil {
+		if errors.Is(err, payments.ErrPaymentDeclined) {
+			http.Error(rw, "Payment declined", http.StatusPaymentRequired)
+			return
+		}
+		h.logger.Println("Payment provider error:", err)
+		http.Error(rw, "Payment failed", http.StatusBadGateway)
+		return
+	}
+
+	wallet, err := h.foodServiceRepo.TopUp(userID, req.Amount, charge.Provider, charge.Reference)
+	if err != nil {
+		h.logger.Printf("Top-up %s charged but not credited: %v", charge.Reference, err)
+		http.Error(rw, "Error crediting wallet.", http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(rw).Encode(wallet)
+}
@@ -6,11 +6,13 @@ import (
 	"food-service/data"
 	"food-service/handlers"
 	"food-service/middleware"
+	"food-service/payments"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"time"
+	_ "time/tzdata"
 
 	"syscall"
 
@@ -42,7 +44,20 @@ func main() {
 		logger.Println("Warning: cannot ensure review indexes:", err)
 	}
 
-	foodServiceHandler := handlers.NewFoodServiceHandler(logger, store)
+	if err := store.EnsureMenuIndexes(); err != nil {
+		logger.Println("Warning: cannot ensure menu indexes:", err)
+	}
+
+	if err := store.EnsureWalletIndexes(); err != nil {
+		logger.Println("Warning: cannot ensure wallet indexes:", err)
+	}
+
+	paymentProvider, err := payments.NewProvider()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	foodServiceHandler := handlers.NewFoodServiceHandler(logger, store, paymentProvider)
 
 	// Router + middleware
 	router := mux.NewRouter()
@@ -87,6 +102,7 @@ router.PathPrefix("/uploads/").
 
 	// Orders
 	cancelOrder := router.Methods(http.MethodPut).Subrouter()
+	cancelOrder.Use(middleware.AuthRequired)
 	cancelOrder.HandleFunc("/order/{id}/cancel", foodServiceHandler.CancelOrderHandler)
 
 	getMyOrders := router.Methods(http.MethodGet).Subrouter()
@@ -100,6 +116,7 @@ router.PathPrefix("/uploads/").
 
 	createOrder := router.Methods(http.MethodPost).Subrouter()
 	createOrder.HandleFunc("/order", foodServiceHandler.CreateOrderHandler)
+	createOrder.Use(middleware.AuthRequired)
 	createOrder.Use(foodServiceHandler.MiddlewareOrderDeserialization)
 
 	updateOrderStatus := router.Methods(http.MethodPut).Subrouter()
@@ -163,6 +180,56 @@ router.PathPrefix("/uploads/").
 	batchSummaries.HandleFunc("/foods/reviews/summaries", foodServiceHandler.BatchFoodSummaries)
 
 
+	// =========================
+	// MENUS
+	// =========================
+
+	// Objavljeni meniji (javno)
+	getMenus := router.Methods(http.MethodGet).Subrouter()
+	getMenus.HandleFunc("/menus", foodServiceHandler.GetMenusHandler)
+	getMenus.HandleFunc("/canteens", foodServiceHandler.GetCanteensHandler)
+
+	// Upravljanje menijima (kuvar)
+	getManagedMenus := router.Methods(http.MethodGet).Subrouter()
+	getManagedMenus.Use(middleware.AuthRequired)
+	getManagedMenus.HandleFunc("/menus/manage", foodServiceHandler.GetManagedMenusHandler)
+
+	saveMenu := router.Methods(http.MethodPut).Subrouter()
+	saveMenu.Use(middleware.AuthRequired)
+	saveMenu.HandleFunc("/menu", foodServiceHandler.SaveMenuHandler)
+
+	deleteMenu := router.Methods(http.MethodDelete).Subrouter()
+	deleteMenu.Use(middleware.AuthRequired)
+	deleteMenu.HandleFunc("/menu/{id}", foodServiceHandler.DeleteMenuHandler)
+
+	publishMenus := router.Methods(http.MethodPost).Subrouter()
+	publishMenus.Use(middleware.AuthRequired)
+	publishMenus.HandleFunc("/menus/publish", foodServiceHandler.PublishWeekHandler)
+	publishMenus.HandleFunc("/menus/copy-week", foodServiceHandler.CopyWeekHandler)
+
+	// =========================
+	// WALLET
+	// =========================
+
+	getWallet := router.Methods(http.MethodGet).Subrouter()
+	getWallet.Use(middleware.AuthRequired)
+	getWallet.HandleFunc("/wallet", foodServiceHandler.GetWalletHandler)
+
+	topUpWallet := router.Methods(http.MethodPost).Subrouter()
+	topUpWallet.Use(middleware.AuthRequired)
+	topUpWallet.HandleFunc("/wallet/top-up", foodServiceHandler.TopUpWalletHandler)
+
+	// =========================
+	// PRICING
+	// =========================
+
+	getPricingRules := router.Methods(http.MethodGet).Subrouter()
+	getPricingRules.HandleFunc("/pricing-rules", foodServiceHandler.GetPricingRulesHandler)
+
+	savePricingRules := router.Methods(http.MethodPut).Subrouter()
+	savePricingRules.Use(middleware.AuthRequired)
+	savePricingRules.HandleFunc("/pricing-rules", foodServiceHandler.SavePricingRulesHandler)
+
 	getRecommendations := router.Methods(http.MethodGet).Subrouter()
 	getRecommendations.HandleFunc("/recommendations", foodServiceHandler.GetRecommendationsHandler)
 
@@ -0,0 +1,65 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrPaymentDeclined = errors.New("payment declined")
+
+type ChargeRequest struct {
+	PayerId     string
+	Amount      float64
+	Description string
+	// Token identifies the payment method, e.g. a card token from the provider's checkout.
+	Token string
+}
+
+type ChargeResult struct {
+	Provider  string
+	Reference string
+	ChargedAt time.Time
+}
+
+// Provider is implemented by every payment provider wallet top-ups can go through.
+type Provider interface {
+	Name() string
+	Charge(ctx context.Context, req ChargeRequest) (*ChargeResult, error)
+}
+
+// FakeProvider accepts every payment except those made with the "decline" token.
+// It is meant for local development and demos.
+type FakeProvider struct{}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{}
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) Charge(ctx context.Context, req ChargeRequest) (*ChargeResult, error) {
+	if req.Amount <= 0 {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+	if req.Token == "decline" {
+		return nil, ErrPaymentDeclined
+	}
+	return &ChargeResult{Provider: p.Name(), Reference: "fake_" + primitive.NewObjectID().Hex(), ChargedAt: time.Now()}, nil
+}
+
+// NewProvider returns the provider selected by the PAYMENT_PROVIDER environment variable.
+func NewProvider() (Provider, error) {
+	switch name := os.Getenv("PAYMENT_PROVIDER"); name {
+	case "", "fake":
+		return NewFakeProvider(), nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider: %s", name)
+	}
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
@@ -18,14 +19,36 @@ func NewControllers(repo *repositories.Repository) *Controllers {
 	return &Controllers{Repo: repo}
 }
 
-func (ctrl *Controllers) CreateStudent(c *gin.Context) {
+// bindStudent reads a student from the request body and reports whether the body
+// set "enrolled", so that a missing field is not mistaken for an unenrolled student.
+func bindStudent(c *gin.Context) (*repositories.Student, *bool, error) {
+	body, err := c.GetRawData()
+	if err != nil {
+		return nil, nil, err
+	}
 	var student repositories.Student
-	if err := c.BindJSON(&student); err != nil {
+	if err := json.Unmarshal(body, &student); err != nil {
+		return nil, nil, err
+	}
+	var fields struct {
+		Enrolled *bool `json:"enrolled"`
+	}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, nil, err
+	}
+	return &student, fields.Enrolled, nil
+}
+
+func (ctrl *Controllers) CreateStudent(c *gin.Context) {
+	student, enrolled, err := bindStudent(c)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	// A student record is created on enrolment unless stated otherwise.
+	student.Enrolled = enrolled == nil || *enrolled
 
-	err := ctrl.Repo.CreateStudent(&student)
+	err = ctrl.Repo.CreateStudent(student)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -48,8 +71,8 @@ func (ctrl *Controllers) GetStudentByID(c *gin.Context) {
 
 func (ctrl *Controllers) UpdateStudent(c *gin.Context) {
 	id := c.Param("id")
-	var student repositories.Student
-	if err := c.BindJSON(&student); err != nil {
+	student, enrolled, err := bindStudent(c)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -62,7 +85,16 @@ func (ctrl *Controllers) UpdateStudent(c *gin.Context) {
 
 	student.ID = objectID
 
-	err = ctrl.Repo.UpdateStudent(&student)
+	// The update replaces the whole document, so keep the enrolment unless it is changed.
+	if enrolled != nil {
+		student.Enrolled = *enrolled
+	} else if existing, err := ctrl.Repo.GetStudentByID(id); err == nil {
+		student.Enrolled = existing.Enrolled
+	} else {
+		student.Enrolled = true
+	}
+
+	err = ctrl.Repo.UpdateStudent(student)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
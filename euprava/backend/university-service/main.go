@@ -27,6 +27,10 @@ func main() {
 		logger.Fatalf("Failed to initialize repository: %v", err)
 	}
 
+	if err := repo.MigrateEnrollment(); err != nil {
+		logger.Printf("Warning: enrollment migration failed: %v", err)
+	}
+
 	ctrl := controllers.NewControllers(repo)
 
 	router := gin.New()
@@ -95,6 +95,7 @@ type Student struct {
 	HighschoolGPA float64 `bson:"highschool_gpa" json:"highschool_gpa,omitempty"`
 	GPA           float64 `bson:"gpa" json:"gpa,omitempty"`
 	ESBP          int     `bson:"esbp" json:"esbp,omitempty"`
+	Enrolled      bool    `bson:"enrolled" json:"enrolled"`
 }
 
 type Exam struct {
@@ -62,6 +62,23 @@ func (r *Repository) CreateStudent(student *Student) error {
 	return nil
 }
 
+// MigrateEnrollment marks students stored before the enrolled flag existed as enrolled;
+// without it they would read as not enrolled and be refused by dorm-service.
+func (r *Repository) MigrateEnrollment() error {
+	collection := r.getCollection("student")
+	result, err := collection.UpdateMany(context.TODO(),
+		bson.M{"enrolled": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"enrolled": true}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount > 0 {
+		r.logger.Printf("Marked %d existing students as enrolled", result.ModifiedCount)
+	}
+	return nil
+}
+
 func (r *Repository) GetStudentByID(userID string) (*Student, error) {
 	collection := r.getCollection("student")
 	objectID, err := primitive.ObjectIDFromHex(userID)
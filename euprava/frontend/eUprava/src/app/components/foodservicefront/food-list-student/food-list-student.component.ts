@@ -134,7 +134,7 @@ export class FoodListStudentComponent implements OnInit {
 
     const orderData: OrderData = { food: { id: foodId } };
 
-    this.foodService.createOrder(orderData, userId).subscribe(
+    this.foodService.createOrder(orderData).subscribe(
       () => {
         alert('Porudžbina je uspešno kreirana!');
         this.router.navigate(['/my-orders']);
@@ -9,4 +9,13 @@ export interface OrderData {
   userId?: string; // ID korisnika koji je kreirao porudžbinu
   statusO?: string; // Status porudžbine ('Prihvacena' ili 'Neprihvacena')
   statusO2?: string; // Status porudžbine ('Otkazana' ili 'Neotkazana')
+  menuId?: string; // meni sa kog je jelo poruceno
+  price?: number; // placena cena
+  pricing?: {
+    rule: string;
+    category: 'FULL' | 'SUBSIDISED' | 'FREE';
+    basePrice: number;
+    payPercent: number;
+    status: { scholarship: boolean; dormResident: boolean };
+  };
 }
@@ -59,8 +59,9 @@ export class FoodService {
     return this.http.delete<void>(`${environment.baseApiUrl}/${this.url}/food/${foodId}`);
   }
 
-  createOrder(orderData: OrderData, userId: string): Observable<any> {
-    return this.http.post<any>(`${environment.baseApiUrl}/${this.url}/order?userId=${userId}`, orderData);
+  createOrder(orderData: OrderData): Observable<any> {
+    // porudzbina se naplacuje ulogovanom studentu iz tokena
+    return this.http.post<any>(`${environment.baseApiUrl}/${this.url}/order`, orderData, { headers: this.authHeaders() });
   }
 
   createFood(foodData: FoodData, userId: string): Observable<any> {
@@ -94,7 +95,7 @@ export class FoodService {
 
 
   cancelOrder(orderId: string): Observable<void> {
-    return this.http.put<void>(`${environment.baseApiUrl}/${this.url}/order/${orderId}/cancel`, {});
+    return this.http.put<void>(`${environment.baseApiUrl}/${this.url}/order/${orderId}/cancel`, {}, { headers: this.authHeaders() });
   }
 
   getAcceptedOrders(): Observable<OrderData[]> {